@@ -34,6 +34,7 @@ Example:
 }
 
 func init() {
+	addReasonFlag(addCmd)
 	rootCmd.AddCommand(addCmd)
 }
 
@@ -47,6 +48,7 @@ func runAdd(ctx context.Context, path, value string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
 
 	if err := client.Add(ctx, path, value); err != nil {
 		return err
@@ -0,0 +1,446 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethanadams/vlt/pkg/config"
+	"github.com/ethanadams/vlt/pkg/vault"
+	"github.com/spf13/cobra"
+)
+
+var (
+	backupDest        string
+	backupKeepDaily   int
+	backupKeepWeekly  int
+	backupKeepMonthly int
+	backupKeepYearly  int
+	backupForce       bool
+	backupDaemon      bool
+	backupInterval    string
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Take scheduled backups with a retention policy",
+	Long: `Take snapshots on a schedule, keeping them by a retention policy.
+
+Examples:
+  vlt backup run secret/myapp --dest /backups/myapp --keep-daily 7 --keep-weekly 4 --encrypt --age age1...
+  vlt backup run secret/myapp --dest s3://backups/myapp/ --keep-daily 7 --daemon --interval 1h --encrypt --kms arn:...`,
+}
+
+var backupRunCmd = &cobra.Command{
+	Use:   "run <path>",
+	Short: "Snapshot a path into a backup directory and prune old backups",
+	Long: `Snapshot all secrets under a path into a backup directory, then remove
+backups the retention policy no longer keeps.
+
+Backups are named after the path, the time they were taken (UTC) and a
+digest of the versions and metadata of every secret they hold, e.g.
+secret_myapp-20240130T140000Z-3f9a1c0d2b7e.enc.yaml. If nothing changed
+since the newest backup, no new backup is written; use --force to write
+one anyway.
+
+The retention policy is grandfather-father-son: --keep-daily 7 keeps the
+newest backup of each of the last 7 days that have one, and likewise for
+--keep-weekly (ISO weeks), --keep-monthly and --keep-yearly. A backup is
+kept if any rule keeps it, and the newest backup is always kept. Without
+any --keep flag, no backups are removed. Only files named like backups of
+the same path are considered, so one directory can hold several paths.
+
+--dest is a local directory or an s3:// prefix. Encryption, signing and
+object lock work as for 'vlt snapshot'.
+
+With --daemon, a backup is taken every --interval until interrupted, and
+each outcome is logged to stdout as a JSON line (msg "backup written",
+"backup skipped", "backups pruned", "backup failed" or "backup prune
+failed") for monitoring. A failed run is logged and retried at the next
+interval.
+
+Examples:
+  vlt backup run secret/myapp --dest /backups/myapp --keep-daily 7 --keep-weekly 4 --encrypt --age age1...
+  vlt backup run secret/myapp --dest s3://backups/myapp/ --keep-daily 7 --keep-monthly 12 --retain 30d --encrypt --kms arn:...
+  vlt backup run secret/myapp --dest /backups/myapp --keep-daily 7 --daemon --interval 1h --encrypt --age age1...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBackup(cmd.Context(), args[0])
+	},
+}
+
+func init() {
+	backupRunCmd.Flags().StringVar(&backupDest, "dest", "", "backup directory or s3:// prefix (required)")
+	_ = backupRunCmd.MarkFlagRequired("dest")
+	backupRunCmd.Flags().IntVar(&backupKeepDaily, "keep-daily", 0, "keep the newest backup of this many days")
+	backupRunCmd.Flags().IntVar(&backupKeepWeekly, "keep-weekly", 0, "keep the newest backup of this many weeks")
+	backupRunCmd.Flags().IntVar(&backupKeepMonthly, "keep-monthly", 0, "keep the newest backup of this many months")
+	backupRunCmd.Flags().IntVar(&backupKeepYearly, "keep-yearly", 0, "keep the newest backup of this many years")
+	backupRunCmd.Flags().BoolVar(&backupForce, "force", false, "write a backup even if nothing changed")
+	backupRunCmd.Flags().BoolVar(&backupDaemon, "daemon", false, "keep running, taking a backup every --interval")
+	backupRunCmd.Flags().StringVar(&backupInterval, "interval", "1h", "time between backups with --daemon")
+
+	// Backups are written like snapshots, with the same options
+	backupRunCmd.Flags().BoolVar(&snapshotEncrypt, "encrypt", false, "encrypt backups with SOPS")
+	backupRunCmd.Flags().StringSliceVar(&snapshotAge, "age", nil, "age recipient to encrypt for (repeatable)")
+	backupRunCmd.Flags().StringSliceVar(&snapshotPGP, "pgp", nil, "PGP fingerprint to encrypt for (repeatable)")
+	backupRunCmd.Flags().StringSliceVar(&snapshotKMS, "kms", nil, "AWS KMS key ARN to encrypt for (repeatable)")
+	backupRunCmd.Flags().BoolVar(&snapshotAllowPlaintext, "allow-plaintext", false, "write unencrypted backups")
+	backupRunCmd.Flags().BoolVar(&snapshotAllVersions, "all-versions", false, "capture every version, not only the current one")
+	backupRunCmd.Flags().BoolVar(&snapshotGzip, "gzip", false, "compress backup files")
+	backupRunCmd.Flags().StringVar(&snapshotSignKey, "sign-key", "", "sign manifests with this ed25519 private key (PEM file)")
+	backupRunCmd.Flags().StringVar(&snapshotRetain, "retain", "", "lock S3 objects against deletion for this long (e.g. 30d)")
+	backupRunCmd.Flags().StringVar(&snapshotRetentionMode, "retention-mode", "governance", "object lock mode for --retain: governance or compliance")
+
+	backupCmd.AddCommand(backupRunCmd)
+	rootCmd.AddCommand(backupCmd)
+}
+
+// backupJob is a configured backup of one path, run once or on every interval
+type backupJob struct {
+	client     *vault.Client
+	path       string
+	dest       string
+	policy     vault.RetentionPolicy
+	enc        *vault.SnapshotEncryption
+	signingKey ed25519.PrivateKey
+	retain     time.Duration
+}
+
+// backupOutcome is what a single run of a backup job did
+type backupOutcome struct {
+	File     string // Backup written, or the unchanged newest backup if skipped
+	Skipped  bool
+	State    string
+	Secrets  int
+	Pruned   []string
+	Duration time.Duration
+}
+
+func runBackup(ctx context.Context, path string) error {
+	job := &backupJob{
+		path: path,
+		dest: backupDest,
+		policy: vault.RetentionPolicy{
+			Daily:   backupKeepDaily,
+			Weekly:  backupKeepWeekly,
+			Monthly: backupKeepMonthly,
+			Yearly:  backupKeepYearly,
+		},
+	}
+	if job.policy.Daily < 0 || job.policy.Weekly < 0 || job.policy.Monthly < 0 || job.policy.Yearly < 0 {
+		return fmt.Errorf("--keep-daily, --keep-weekly, --keep-monthly and --keep-yearly must not be negative")
+	}
+
+	interval, err := vault.ParseDuration(backupInterval)
+	if err != nil {
+		return fmt.Errorf("invalid --interval: %w", err)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+
+	if job.enc, err = snapshotEncryption(); err != nil {
+		return err
+	}
+
+	if snapshotRetain != "" {
+		if !vault.IsS3URL(job.dest) {
+			return fmt.Errorf("--retain requires an s3:// destination")
+		}
+		if job.retain, err = vault.ParseDuration(snapshotRetain); err != nil {
+			return fmt.Errorf("invalid --retain: %w", err)
+		}
+	}
+
+	if snapshotSignKey != "" {
+		keyData, err := os.ReadFile(snapshotSignKey)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key: %w", err)
+		}
+		if job.signingKey, err = vault.ParseSigningKey(keyData); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	if job.client, err = vault.NewClient(cfg); err != nil {
+		return err
+	}
+
+	if !backupDaemon {
+		outcome, err := job.run(ctx)
+		if outcome != nil {
+			printBackupOutcome(outcome)
+		}
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	return job.daemon(ctx, interval, slog.New(slog.NewJSONHandler(os.Stdout, nil)))
+}
+
+// daemon runs the job now and on every interval until ctx is done, logging each outcome
+func (j *backupJob) daemon(ctx context.Context, interval time.Duration, logger *slog.Logger) error {
+	logger = logger.With("path", j.path, "dest", j.dest)
+	logger.Info("backup daemon started", "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		outcome, err := j.run(ctx)
+		if ctx.Err() != nil {
+			// Interrupted mid-run, which is not a failure worth alerting on
+			logger.Info("backup daemon stopped")
+			return nil
+		}
+		logBackupOutcome(logger, outcome, err)
+
+		select {
+		case <-ctx.Done():
+			logger.Info("backup daemon stopped")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// run takes a backup unless nothing changed since the newest one, then prunes. The
+// outcome is returned even on error, as long as the backup itself was handled.
+func (j *backupJob) run(ctx context.Context) (*backupOutcome, error) {
+	start := time.Now()
+
+	state, err := j.client.BackupState(ctx, j.path)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := listBackups(ctx, j.dest, j.path)
+	if err != nil {
+		return nil, err
+	}
+
+	outcome := &backupOutcome{State: state}
+	newest := newestBackup(backups)
+	if newest != nil && newest.State == state && !backupForce {
+		outcome.File = backupLocation(j.dest, newest.Name)
+		outcome.Skipped = true
+	} else {
+		snapshot, err := j.client.CreateSnapshotWithOptions(ctx, j.path, vault.SnapshotOptions{AllVersions: snapshotAllVersions})
+		if err != nil {
+			return nil, err
+		}
+		data, err := vault.MarshalSnapshot(snapshot, vault.SnapshotFileOptions{
+			Encryption: j.enc,
+			Gzip:       snapshotGzip,
+			SigningKey: j.signingKey,
+			VltVersion: rootCmd.Version,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		name := vault.BackupFileName(j.path, snapshot.CreatedAt, state, j.extension())
+		outcome.File = backupLocation(j.dest, name)
+		opts := vault.S3PutOptions{
+			Info:          vault.NewSnapshotObjectInfo(snapshot, vault.BackupFormatVlt, j.enc != nil, j.signingKey != nil, rootCmd.Version),
+			RetentionMode: snapshotRetentionMode,
+		}
+		if j.retain > 0 {
+			opts.RetainUntil = time.Now().Add(j.retain)
+		}
+		if !vault.IsS3URL(j.dest) {
+			if err := os.MkdirAll(j.dest, 0700); err != nil {
+				return nil, fmt.Errorf("failed to create backup directory: %w", err)
+			}
+		}
+		if err := writeSnapshotData(ctx, outcome.File, data, opts); err != nil {
+			return nil, err
+		}
+
+		outcome.Secrets = len(snapshot.Secrets)
+		backups = append(backups, vault.BackupFile{Name: name, CreatedAt: snapshot.CreatedAt, State: state})
+	}
+
+	_, prune := j.policy.Select(backups)
+	outcome.Pruned, err = deleteBackups(ctx, j.dest, prune)
+	outcome.Duration = time.Since(start)
+	return outcome, err
+}
+
+// extension returns the file extension of backups, following the snapshot conventions
+func (j *backupJob) extension() string {
+	ext := ".yaml"
+	if j.enc != nil {
+		ext = ".enc.yaml"
+	}
+	if snapshotGzip {
+		ext += ".gz"
+	}
+	return ext
+}
+
+// newestBackup returns the most recent backup, nil if there are none
+func newestBackup(backups []vault.BackupFile) *vault.BackupFile {
+	var newest *vault.BackupFile
+	for i := range backups {
+		if newest == nil || backups[i].CreatedAt.After(newest.CreatedAt) {
+			newest = &backups[i]
+		}
+	}
+	return newest
+}
+
+// listBackups returns the backups of path in a local directory or under an s3:// prefix.
+// Files in subdirectories are not included.
+func listBackups(ctx context.Context, dest, path string) ([]vault.BackupFile, error) {
+	var names []string
+	if vault.IsS3URL(dest) {
+		loc, err := vault.ParseS3URL(dest)
+		if err != nil {
+			return nil, err
+		}
+		loc.Key = s3Directory(loc.Key)
+		store, err := newS3Store(ctx)
+		if err != nil {
+			return nil, err
+		}
+		objects, err := store.List(ctx, loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if name := strings.TrimPrefix(obj.Location.Key, loc.Key); !strings.Contains(name, "/") {
+				names = append(names, name)
+			}
+		}
+	} else {
+		entries, err := os.ReadDir(dest)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read backup directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+
+	var backups []vault.BackupFile
+	for _, name := range names {
+		if backup, ok := vault.ParseBackupFileName(path, name); ok {
+			backups = append(backups, backup)
+		}
+	}
+	return backups, nil
+}
+
+// deleteBackups removes backups from dest, returning the locations removed. Every
+// backup is attempted; the errors of those that could not be removed are joined.
+func deleteBackups(ctx context.Context, dest string, backups []vault.BackupFile) ([]string, error) {
+	if len(backups) == 0 {
+		return nil, nil
+	}
+
+	var store *vault.S3Store
+	if vault.IsS3URL(dest) {
+		var err error
+		if store, err = newS3Store(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var deleted []string
+	var errs []error
+	for _, backup := range backups {
+		location := backupLocation(dest, backup.Name)
+		var err error
+		if store != nil {
+			var loc vault.S3Location
+			if loc, err = vault.ParseS3URL(location); err == nil {
+				err = store.Delete(ctx, loc)
+			}
+		} else if err = os.Remove(location); err != nil {
+			err = fmt.Errorf("failed to delete backup: %w", err)
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, location)
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// backupLocation returns the location of a backup file in dest
+func backupLocation(dest, name string) string {
+	if !vault.IsS3URL(dest) {
+		return filepath.Join(dest, name)
+	}
+	loc, err := vault.ParseS3URL(dest)
+	if err != nil {
+		return dest + name
+	}
+	loc.Key = s3Directory(loc.Key) + name
+	return loc.String()
+}
+
+// s3Directory turns an S3 key into a prefix ending in a slash, so a destination
+// written with or without a trailing slash is the same directory
+func s3Directory(key string) string {
+	if key == "" {
+		return key
+	}
+	return strings.TrimSuffix(key, "/") + "/"
+}
+
+func printBackupOutcome(outcome *backupOutcome) {
+	if outcome.Skipped {
+		fmt.Printf("No changes since %s, backup skipped\n", outcome.File)
+	} else {
+		fmt.Printf("Backup written: %s\n", outcome.File)
+		fmt.Printf("  Secrets: %d\n", outcome.Secrets)
+		fmt.Printf("  State: %s\n", outcome.State)
+	}
+	for _, pruned := range outcome.Pruned {
+		fmt.Printf("Pruned: %s\n", pruned)
+	}
+}
+
+// logBackupOutcome logs a run of the backup daemon as structured records
+func logBackupOutcome(logger *slog.Logger, outcome *backupOutcome, err error) {
+	if outcome == nil {
+		logger.Error("backup failed", "error", err.Error())
+		return
+	}
+
+	if outcome.Skipped {
+		logger.Info("backup skipped", "file", outcome.File, "state", outcome.State, "reason", "unchanged")
+	} else {
+		logger.Info("backup written", "file", outcome.File, "state", outcome.State, "secrets", outcome.Secrets, "duration_ms", outcome.Duration.Milliseconds())
+	}
+	if len(outcome.Pruned) > 0 {
+		logger.Info("backups pruned", "files", outcome.Pruned, "count", len(outcome.Pruned))
+	}
+	if err != nil {
+		logger.Error("backup prune failed", "error", err.Error())
+	}
+}
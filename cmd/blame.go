@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var blameCmd = &cobra.Command{
+	Use:   "blame <path>",
+	Short: "Show when each key was last changed",
+	Long: `Show, for every key in a secret or directory, the version and time
+of its last modification and how many times it has changed.
+
+Recursively traverses all subdirectories.
+
+Examples:
+  vlt blame secret/myapp
+  vlt blame secret/myapp/config
+
+Use 'vlt history <path> --key <key>' to follow a single key.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runBlame(cmd.Context(), args[0])
+	},
+	Annotations: map[string]string{offlineAnnotation: "true"},
+}
+
+func init() {
+	rootCmd.AddCommand(blameCmd)
+}
+
+func runBlame(ctx context.Context, path string) error {
+	client, err := newReadClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	blames, err := client.Blame(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if len(blames) == 0 {
+		return fmt.Errorf("no keys found at %s", path)
+	}
+
+	width := len("KEY")
+	for _, b := range blames {
+		if len(b.Key) > width {
+			width = len(b.Key)
+		}
+	}
+
+	fmt.Printf("%-*s  %-7s  %-19s  %-7s  %s\n", width, "KEY", "VERSION", "CHANGED", "CHANGES", "BY")
+	for _, b := range blames {
+		by := "-"
+		if b.Provenance != nil {
+			by = strings.TrimPrefix(b.Provenance.String(), "by ")
+		}
+		fmt.Printf("%-*s  %-7s  %-19s  %-7d  %s\n",
+			width,
+			b.Key,
+			fmt.Sprintf("v%d", b.Version),
+			b.Time.Local().Format("2006-01-02 15:04:05"),
+			b.Changes,
+			by,
+		)
+	}
+
+	return nil
+}
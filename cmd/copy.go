@@ -9,7 +9,11 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var copyRecursive bool
+var (
+	copyRecursive     bool
+	copyResetMetadata bool
+	copyWithHistory   bool
+)
 
 var copyCmd = &cobra.Command{
 	Use:     "copy <source> <destination>",
@@ -18,6 +22,9 @@ var copyCmd = &cobra.Command{
 	Long: `Copy a secret or directory from one path to another.
 
 Never overwrites existing secrets at the destination path.
+Custom metadata and settings (max_versions, cas_required,
+delete_version_after) are copied unless --reset-metadata is given.
+Only the latest version is copied unless --with-history is given.
 
 Example:
   vlt copy secret/myapp/config secret/myapp/config-backup
@@ -31,6 +38,9 @@ Example:
 
 func init() {
 	copyCmd.Flags().BoolVarP(&copyRecursive, "recursive", "r", false, "recursively copy all secrets under the path")
+	copyCmd.Flags().BoolVar(&copyWithHistory, "with-history", false, "copy all versions, not only the latest")
+	copyCmd.Flags().BoolVar(&copyResetMetadata, "reset-metadata", false, "don't copy custom metadata and settings")
+	addReasonFlag(copyCmd)
 	rootCmd.AddCommand(copyCmd)
 }
 
@@ -44,9 +54,12 @@ func runCopy(ctx context.Context, src, dst string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
+
+	opts := vault.CopyOptions{ResetMetadata: copyResetMetadata, WithHistory: copyWithHistory}
 
 	if copyRecursive {
-		count, err := client.CopyRecursive(ctx, src, dst)
+		count, err := client.CopyRecursiveWithOptions(ctx, src, dst, opts)
 		if err != nil {
 			return err
 		}
@@ -54,7 +67,7 @@ func runCopy(ctx context.Context, src, dst string) error {
 		return nil
 	}
 
-	if err := client.Copy(ctx, src, dst); err != nil {
+	if err := client.CopyWithOptions(ctx, src, dst, opts); err != nil {
 		return err
 	}
 	fmt.Printf("Copied %s -> %s\n", src, dst)
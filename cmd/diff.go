@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
-	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/getsops/sops/v3/decrypt"
 	"github.com/spf13/cobra"
@@ -19,27 +19,47 @@ var (
 	diffQuiet      bool
 	diffSops       bool
 	diffShowValues bool
+	diffMetadata   bool
+	diffSince      string
+	diffBetween    string
 )
 
 var diffCmd = &cobra.Command{
-	Use:   "diff <path1> <path2>",
+	Use:   "diff <path1> <path2> | diff <path> --since <duration> | diff <path> --between <t1> <t2>",
 	Short: "Compare secrets between two paths",
 	Long: `Compare secrets between two Vault paths or a Vault path and a local file.
 
 Shows keys that exist only in one path, keys with different values,
 and a count of unchanged keys. Use --show-values to display actual values.
 
-If a path exists as a local file, it will be read as YAML. Use --sops
-to decrypt SOPS-encrypted files.
+If a path exists as a local file, it will be read as YAML. SOPS-encrypted
+files are decrypted (--sops forces decryption). Snapshot files created by
+'vlt snapshot' are recognised: their secret values are compared, and the
+version each secret was at is compared against the other snapshot or the
+live Vault path and reported as version drift.
 
 Version comparison:
   @N    - Compare specific version (single secrets only)
   @prev - Compare previous version (works for both single secrets and directories)
   @-N   - Compare state N changes ago (directories only, based on change timeline)
+  @<time> - Compare state at a point in time (e.g. @2024-01-30T14:00:00Z, @{2h ago})
+
+The global --at flag applies a point in time to every Vault path
+without its own @ suffix.
+
+Time windows (directories only):
+  --since <duration>   - Compare the state <duration> ago with now
+  --between <t1> <t2>  - Compare the state at t1 with the state at t2
+Both list which secrets changed in the window and how many versions
+each went through, followed by the usual diff.
 
 For directories:
   @prev compares the previous version of each secret
-  @-N builds a timeline of all changes and shows the state N changes ago
+  @-N builds a timeline of all changes and shows the state N changes ago.
+      Every version written counts as a change, including the one that
+      created a secret, so secrets created within the last N changes are
+      left out. If a version a secret was at has since been deleted or
+      destroyed, the diff fails rather than leaving the secret out.
 
 Exit codes:
   0 - paths are identical
@@ -65,21 +85,49 @@ Example:
   vlt diff secret/myapp@-3 secret/myapp
   # See cumulative changes from the last 3 changes
 
+  vlt diff secret/myapp@{1d ago} secret/myapp
+  # See what changed in the last day
+
+  vlt diff secret/staging/app secret/prod/app --at 2024-01-30T14:00:00Z
+  # Compare environments as they were at a point in time
+
+  vlt diff secret/myapp --since 24h
+  # Review everything that changed in the last day
+
+  vlt diff secret/myapp --between 2024-01-30T10:00Z 2024-01-30T12:00Z
+  # Review changes within a window
+
   vlt diff secret/myapp config.yaml
   # Compare Vault secrets with a local YAML file
 
   vlt diff --sops secrets.enc.yaml secret/myapp
   # Compare SOPS-encrypted file with Vault
 
+  vlt diff backup-mon.enc.yaml backup-tue.enc.yaml
+  # Compare two snapshots, including version drift
+
+  vlt diff backup.enc.yaml secret/myapp
+  # See how Vault has changed since a snapshot
+
   vlt diff secret/myapp secret/myapp-backup --summary
   # Show only counts
 
   vlt diff secret/v1 secret/v2 --quiet
-  # Exit code only, for scripting`,
-	Args: cobra.ExactArgs(2),
+  # Exit code only, for scripting
+
+  vlt diff secret/staging/app secret/prod/app --metadata
+  # Compare custom metadata and settings instead of values`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffSince != "" || diffBetween != "" {
+			return runDiffWindow(cmd.Context(), args)
+		}
+		if len(args) != 2 {
+			return fmt.Errorf("diff requires two paths, or one path with --since or --between")
+		}
 		return runDiff(cmd.Context(), args[0], args[1])
 	},
+	Annotations: map[string]string{pointInTimeAnnotation: "true", offlineAnnotation: "true"},
 }
 
 func init() {
@@ -88,6 +136,9 @@ func init() {
 	diffCmd.Flags().BoolVarP(&diffQuiet, "quiet", "q", false, "exit code only, no output")
 	diffCmd.Flags().BoolVar(&diffSops, "sops", false, "decrypt SOPS-encrypted files")
 	diffCmd.Flags().BoolVar(&diffShowValues, "show-values", false, "show actual secret values (use with caution)")
+	diffCmd.Flags().BoolVar(&diffMetadata, "metadata", false, "compare metadata instead of secret values")
+	diffCmd.Flags().StringVar(&diffSince, "since", "", "compare the state this long ago with now (e.g. 24h, 7d)")
+	diffCmd.Flags().StringVar(&diffBetween, "between", "", "compare the state between two times: --between <t1> <t2>")
 	rootCmd.AddCommand(diffCmd)
 }
 
@@ -95,29 +146,114 @@ func init() {
 
 func runDiff(ctx context.Context, path1, path2 string) error {
 	// Check if either path is a local file
-	path1IsFile := isLocalFile(path1)
-	path2IsFile := isLocalFile(path2)
+	path1IsFile := isLocalFile(path1) || vault.IsS3URL(path1)
+	path2IsFile := isLocalFile(path2) || vault.IsS3URL(path2)
 
 	// Only need Vault client if at least one path is a Vault path
 	var client *vault.Client
 	if !path1IsFile || !path2IsFile {
-		cfg, err := config.Load()
+		var err error
+		client, err = newReadClient(ctx)
 		if err != nil {
 			return err
 		}
-		client, err = vault.NewClient(cfg)
+	}
+
+	var result *vault.DiffResult
+	var err error
+	if diffMetadata {
+		result, err = compareMetadata(ctx, client, path1, path2, path1IsFile || path2IsFile)
+		// Metadata is not secret, always show it
+		diffShowValues = true
+	} else {
+		result, err = comparePaths(ctx, client, path1, path2, path1IsFile, path2IsFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !diffQuiet {
+		printDiffResult(path1, path2, result)
+	}
+
+	if result.HasDifferences() {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// runDiffWindow compares a directory at the start and end of a time window and lists
+// the secrets that changed within it
+func runDiffWindow(ctx context.Context, args []string) error {
+	if globalAt != "" || diffMetadata {
+		return fmt.Errorf("--since and --between cannot be combined with --at or --metadata")
+	}
+
+	between := diffBetween != ""
+	path := args[0]
+
+	var start, end time.Time
+	var err error
+	switch {
+	case diffSince != "" && between:
+		return fmt.Errorf("use either --since or --between, not both")
+	case diffSince != "":
+		if len(args) != 1 {
+			return fmt.Errorf("--since takes a single path")
+		}
+		d, err := vault.ParseDuration(diffSince)
 		if err != nil {
 			return err
 		}
+		end = time.Now()
+		start = end.Add(-d)
+	default:
+		if path, end, err = betweenArgs(args); err != nil {
+			return err
+		}
+		if start, err = vault.ParseTimeSpec(diffBetween); err != nil {
+			return err
+		}
+		if end.Before(start) {
+			return fmt.Errorf("--between end time is before start time")
+		}
 	}
 
-	result, err := comparePaths(ctx, client, path1, path2, path1IsFile, path2IsFile)
+	client, err := newReadClient(ctx)
 	if err != nil {
 		return err
 	}
 
+	changes, err := client.GetChangesBetween(ctx, path, start, end)
+	if err != nil {
+		return err
+	}
+
+	before, err := client.GetAt(ctx, path, start)
+	if err != nil {
+		return err
+	}
+	var after map[string]any
+	if between {
+		after, err = client.GetAt(ctx, path, end)
+	} else {
+		after, err = client.Get(ctx, path)
+	}
+	if err != nil {
+		return err
+	}
+
+	result := vault.CompareSecrets(vault.Flatten(before), vault.Flatten(after))
+
 	if !diffQuiet {
-		printDiffResult(path1, path2, result)
+		label1 := fmt.Sprintf("%s@%s", path, start.Local().Format("2006-01-02 15:04:05"))
+		label2 := path
+		if between {
+			label2 = fmt.Sprintf("%s@%s", path, end.Local().Format("2006-01-02 15:04:05"))
+		}
+
+		printWindowChanges(changes, start, end)
+		printDiffResult(label1, label2, result)
 	}
 
 	if result.HasDifferences() {
@@ -126,6 +262,48 @@ func runDiff(ctx context.Context, path1, path2 string) error {
 	return nil
 }
 
+// betweenArgs splits the arguments of --between <t1> <t2> into the path and the end
+// time t2, which is given as an argument of its own before or after the path
+func betweenArgs(args []string) (string, time.Time, error) {
+	if len(args) == 2 {
+		first, err1 := vault.ParseTimeSpec(args[0])
+		second, err2 := vault.ParseTimeSpec(args[1])
+		switch {
+		case err1 != nil && err2 == nil:
+			return args[0], second, nil
+		case err1 == nil && err2 != nil:
+			return args[1], first, nil
+		}
+	}
+	return "", time.Time{}, fmt.Errorf("--between takes two times and a single path: diff <path> --between <t1> <t2>")
+}
+
+// printWindowChanges lists the secrets that changed within a time window
+func printWindowChanges(changes []vault.WindowChange, start, end time.Time) {
+	fmt.Printf("Changes between %s and %s:\n\n",
+		start.Local().Format("2006-01-02 15:04:05"), end.Local().Format("2006-01-02 15:04:05"))
+
+	if len(changes) == 0 {
+		fmt.Println("  (no secrets changed)")
+		fmt.Println()
+		return
+	}
+
+	for _, change := range changes {
+		versions := "1 version"
+		if change.Versions != 1 {
+			versions = fmt.Sprintf("%d versions", change.Versions)
+		}
+
+		if change.FromVersion == 0 {
+			fmt.Printf("  + %s  created → v%d  (%s)\n", change.SecretPath, change.ToVersion, versions)
+		} else {
+			fmt.Printf("  ~ %s  v%d → v%d  (%s)\n", change.SecretPath, change.FromVersion, change.ToVersion, versions)
+		}
+	}
+	fmt.Println()
+}
+
 // isLocalFile checks if the path exists as a local file
 func isLocalFile(path string) bool {
 	// Quick heuristic: if it contains common YAML extensions, check if file exists
@@ -140,36 +318,113 @@ func isLocalFile(path string) bool {
 
 func comparePaths(ctx context.Context, client *vault.Client, path1, path2 string, path1IsFile, path2IsFile bool) (*vault.DiffResult, error) {
 	// Get secrets from both paths
-	secrets1, err := getSecretsFromSource(ctx, client, path1, path1IsFile)
+	secrets1, versions1, err := getSecretsFromSource(ctx, client, path1, path1IsFile)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", path1, err)
 	}
 
-	secrets2, err := getSecretsFromSource(ctx, client, path2, path2IsFile)
+	secrets2, versions2, err := getSecretsFromSource(ctx, client, path2, path2IsFile)
 	if err != nil {
 		return nil, fmt.Errorf("reading %s: %w", path2, err)
 	}
 
-	return vault.CompareSecrets(secrets1, secrets2), nil
+	result := vault.CompareSecrets(secrets1, secrets2)
+
+	// Versions are only known for snapshots; compare a snapshot with the live versions
+	if versions1 == nil && versions2 != nil && !path1IsFile {
+		versions1, err = getVersionsFromVault(ctx, client, path1)
+	} else if versions2 == nil && versions1 != nil && !path2IsFile {
+		versions2, err = getVersionsFromVault(ctx, client, path2)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if versions1 != nil && versions2 != nil {
+		result.Drift = vault.CompareSecretVersions(versions1, versions2)
+	}
+
+	return result, nil
 }
 
-// getSecretsFromSource retrieves secrets from either a Vault path or a local file
-func getSecretsFromSource(ctx context.Context, client *vault.Client, path string, isFile bool) (map[string]any, error) {
+// compareMetadata compares the metadata of all secrets under two Vault paths
+func compareMetadata(ctx context.Context, client *vault.Client, path1, path2 string, hasFile bool) (*vault.DiffResult, error) {
+	if hasFile {
+		return nil, fmt.Errorf("--metadata only compares Vault paths, not local files")
+	}
+	if globalAt != "" {
+		return nil, fmt.Errorf("--metadata cannot be combined with --at")
+	}
+
+	var flat [2]map[string]any
+	for i, path := range []string{path1, path2} {
+		if _, spec := vault.ParseVersionedPath(path); spec.HasVersion() {
+			return nil, fmt.Errorf("--metadata does not support version suffixes: %s", path)
+		}
+
+		tree, err := client.GetMetadataTree(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		flat[i] = vault.FlattenMetadata(tree)
+	}
+
+	return vault.CompareSecrets(flat[0], flat[1]), nil
+}
+
+// getSecretsFromSource retrieves secrets from either a Vault path or a local file.
+// Secret versions are returned for snapshot files only.
+func getSecretsFromSource(ctx context.Context, client *vault.Client, path string, isFile bool) (map[string]any, map[string]int, error) {
 	if isFile {
-		return getSecretsFromFile(path)
+		return getSecretsFromFile(ctx, path)
+	}
+	secrets, err := getSecretsFromVault(ctx, client, path)
+	return secrets, nil, err
+}
+
+// getVersionsFromVault returns the current version of each secret under a Vault path,
+// or nil if the path reads an older state (@ suffix or --at)
+func getVersionsFromVault(ctx context.Context, client *vault.Client, path string) (map[string]int, error) {
+	if _, spec := vault.ParseVersionedPath(path); spec.HasVersion() || globalAt != "" {
+		return nil, nil
+	}
+	versions, err := client.CurrentVersions(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("reading versions of %s: %w", path, err)
 	}
-	return getSecretsFromVault(ctx, client, path)
+	return versions, nil
 }
 
 // getSecretsFromVault retrieves all secrets under a Vault path as a flat key->value map
 // Supports @version suffix for reading specific versions (e.g., secret/myapp/config@1)
 // Supports @prev alias for the previous version (works for both single secrets and directories)
 // Supports @-N for N changes ago (directories only, based on change timeline)
+// Supports @<time> for the state at a point in time (directories and single secrets)
 // Note: Numeric version (@N) is only supported for single secrets, not directories
 func getSecretsFromVault(ctx context.Context, client *vault.Client, path string) (map[string]any, error) {
 	// Parse @version suffix using vault package
 	basePath, spec := vault.ParseVersionedPath(path)
 
+	// Without a suffix, the global --at flag applies
+	if !spec.HasVersion() && globalAt != "" {
+		t, err := vault.ParseTimeSpec(globalAt)
+		if err != nil {
+			return nil, err
+		}
+		spec = vault.VersionSpec{At: t, IsAt: true}
+	}
+
+	// Point in time works the same for directories and single secrets
+	if spec.IsAt {
+		secrets, err := client.GetAt(ctx, basePath, spec.At)
+		if err != nil {
+			return nil, err
+		}
+		if len(secrets) == 0 {
+			return nil, fmt.Errorf("no secrets existed at %s at %s", basePath, spec.At.Format(time.RFC3339))
+		}
+		return vault.Flatten(secrets), nil
+	}
+
 	// If no version specified, use the simple recursive Get
 	if !spec.HasVersion() {
 		secrets, err := client.Get(ctx, basePath)
@@ -217,30 +472,50 @@ func getSecretsFromVault(ctx context.Context, client *vault.Client, path string)
 	return vault.FlattenAndExtractValues(secrets, false), nil
 }
 
-// getSecretsFromFile reads and parses a YAML file, returning a flat key->value map
-func getSecretsFromFile(path string) (map[string]any, error) {
+// getSecretsFromFile reads and parses a YAML file, returning a flat key->value map.
+// For snapshot files, the version of each secret is returned as well.
+func getSecretsFromFile(ctx context.Context, path string) (map[string]any, map[string]int, error) {
 	var content []byte
 	var err error
+	if vault.IsS3URL(path) {
+		content, err = readSnapshotData(ctx, path)
+	} else if content, err = os.ReadFile(path); err != nil {
+		err = fmt.Errorf("failed to read file: %w", err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
 	if diffSops {
-		content, err = decrypt.File(path, "yaml")
+		content, err = decrypt.Data(content, "yaml")
 		if err != nil {
-			return nil, fmt.Errorf("failed to decrypt SOPS file: %w", err)
+			return nil, nil, fmt.Errorf("failed to decrypt SOPS file: %w", err)
 		}
-	} else {
-		content, err = os.ReadFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read file: %w", err)
+	} else if content, _, err = vault.DecodeSnapshotFile(content); err != nil {
+		// Decrypts SOPS files and decompresses gzipped snapshots
+		return nil, nil, err
+	}
+
+	if snapshot, ok := vault.ParseSnapshot(content); ok {
+		if err := snapshot.VerifyManifest(nil); err != nil {
+			return nil, nil, err
+		}
+		// Incremental snapshots are compared in their full state
+		if snapshot.Base != nil {
+			if snapshot, err = LoadSnapshot(ctx, path, true, ""); err != nil {
+				return nil, nil, err
+			}
 		}
+		return vault.Flatten(snapshot.Data()), snapshot.SecretVersions(), nil
 	}
 
 	var data map[string]any
 	if err := yaml.Unmarshal(content, &data); err != nil {
-		return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse YAML: %w", err)
 	}
 
 	// Flatten nested structure to dot-notation keys
-	return vault.Flatten(data), nil
+	return vault.Flatten(data), nil, nil
 }
 
 func printDiffResult(path1, path2 string, result *vault.DiffResult) {
@@ -251,6 +526,8 @@ func printDiffResult(path1, path2 string, result *vault.DiffResult) {
 
 	fmt.Printf("Comparing %s → %s\n\n", path1, path2)
 
+	printVersionDrift(result.Drift)
+
 	if !result.HasDifferences() {
 		fmt.Println("Paths are identical")
 		return
@@ -306,6 +583,22 @@ func printSummary(result *vault.DiffResult) {
 	fmt.Printf("  Only in second: %d\n", len(result.OnlyInSecond))
 	fmt.Printf("  Changed:        %d\n", len(result.Changed))
 	fmt.Printf("  Unchanged:      %d\n", result.Unchanged)
+	if len(result.Drift) > 0 {
+		fmt.Printf("  Version drift:  %d secrets\n", len(result.Drift))
+	}
+}
+
+// printVersionDrift lists the secrets whose version differs between the two sources
+func printVersionDrift(drift []vault.VersionDrift) {
+	if len(drift) == 0 {
+		return
+	}
+
+	fmt.Println("Version drift:")
+	for _, d := range drift {
+		fmt.Printf("  ~ %s  v%d → v%d\n", d.Path, d.FirstVersion, d.SecondVersion)
+	}
+	fmt.Println()
 }
 
 // truncateValue truncates long values for display
@@ -4,8 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/ethanadams/vlt/pkg/config"
-	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
 )
 
@@ -24,6 +22,7 @@ Example:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runDuplicates(cmd.Context(), args[0])
 	},
+	Annotations: map[string]string{offlineAnnotation: "true"},
 }
 
 func init() {
@@ -31,12 +30,7 @@ func init() {
 }
 
 func runDuplicates(ctx context.Context, path string) error {
-	cfg, err := config.Load()
-	if err != nil {
-		return err
-	}
-
-	client, err := vault.NewClient(cfg)
+	client, err := newReadClient(ctx)
 	if err != nil {
 		return err
 	}
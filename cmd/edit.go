@@ -44,6 +44,7 @@ Example:
 }
 
 func init() {
+	addReasonFlag(editCmd)
 	rootCmd.AddCommand(editCmd)
 }
 
@@ -57,6 +58,7 @@ func runEdit(ctx context.Context, path string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
 
 	// Check if path is a directory (has children)
 	isDir, err := client.IsDirectory(ctx, path)
@@ -219,6 +221,7 @@ func runEditRecursive(ctx context.Context, client *vault.Client, path string) er
 	} else {
 		fmt.Printf("\nUpdated %d secrets.\n", total)
 	}
+	printTrashHint(client)
 	return nil
 }
 
@@ -6,11 +6,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -28,43 +27,62 @@ The output file is named after the last component of the path.
 With --recursive, traverses all subdirectories and creates a local
 directory structure mirroring Vault, with YAML files for each path.
 
+--format writes json, dotenv, properties, toml or hcl files instead,
+named with the extension of the format (.env for dotenv). Nested keys
+are flattened for dotenv and properties, or in any format with
+--key-style dot, underscore or upper-snake; --key-prefix prepends a
+prefix to the flattened names. See 'vlt get --help'.
+
 Example:
   vlt export secret/myapp
   # Creates myapp.yaml
 
   vlt export secret/myapp --recursive
-  # Creates myapp/ directory with nested structure`,
+  # Creates myapp/ directory with nested structure
+
+  vlt export secret/myapp/config --format dotenv --key-prefix MYAPP_
+  # Creates config.env with MYAPP_API_KEY=... lines
+
+  vlt export secret/myapp --at 2024-01-30T14:00:00Z
+  # Exports secrets as they were at that time`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runExport(cmd.Context(), args[0])
 	},
+	Annotations: map[string]string{pointInTimeAnnotation: "true", offlineAnnotation: "true"},
 }
 
 func init() {
-	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file path (default: <name>.yaml)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "output file path (default: <name>.yaml, or the extension of --format)")
 	exportCmd.Flags().BoolVarP(&exportRecursive, "recursive", "r", false, "recursively export all subdirectories")
+	addOutputFlags(exportCmd)
 	rootCmd.AddCommand(exportCmd)
 }
 
 func runExport(ctx context.Context, path string) error {
-	cfg, err := config.Load()
+	opts, err := outputOptions()
 	if err != nil {
 		return err
 	}
 
-	client, err := vault.NewClient(cfg)
+	client, err := newReadClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	path, at, err := resolvePointInTime(path)
 	if err != nil {
 		return err
 	}
 
 	if exportRecursive {
-		return runRecursiveExport(ctx, client, path, ".")
+		return runRecursiveExport(ctx, client, path, ".", at, opts)
 	}
 
-	return exportPath(ctx, client, path, exportOutput)
+	return exportPath(ctx, client, path, exportOutput, at, opts)
 }
 
-func runRecursiveExport(ctx context.Context, client *vault.Client, vaultPath, localDir string) error {
+func runRecursiveExport(ctx context.Context, client *vault.Client, vaultPath, localDir string, at time.Time, opts vault.EncodeOptions) error {
 	dirs, hasSecrets, err := client.ListDirectories(ctx, vaultPath)
 	if err != nil {
 		return err
@@ -72,8 +90,8 @@ func runRecursiveExport(ctx context.Context, client *vault.Client, vaultPath, lo
 
 	// If this path has secrets, export them
 	if hasSecrets {
-		outputFile := filepath.Join(localDir, getParentKey(vaultPath)+".yaml")
-		if err := exportPath(ctx, client, vaultPath, outputFile); err != nil {
+		outputFile := filepath.Join(localDir, getParentKey(vaultPath)+opts.Format.Extension())
+		if err := exportPath(ctx, client, vaultPath, outputFile, at, opts); err != nil {
 			return err
 		}
 	}
@@ -93,7 +111,7 @@ func runRecursiveExport(ctx context.Context, client *vault.Client, vaultPath, lo
 			return fmt.Errorf("failed to create directory %s: %w", subLocalDir, err)
 		}
 
-		if err := runRecursiveExport(ctx, client, subVaultPath, subLocalDir); err != nil {
+		if err := runRecursiveExport(ctx, client, subVaultPath, subLocalDir, at, opts); err != nil {
 			return err
 		}
 	}
@@ -101,26 +119,37 @@ func runRecursiveExport(ctx context.Context, client *vault.Client, vaultPath, lo
 	return nil
 }
 
-func exportPath(ctx context.Context, client *vault.Client, path, outputFile string) error {
-	secrets, err := client.Export(ctx, path)
+func exportPath(ctx context.Context, client *vault.Client, path, outputFile string, at time.Time, opts vault.EncodeOptions) error {
+	var secrets map[string]any
+	var err error
+	if at.IsZero() {
+		secrets, err = client.Export(ctx, path)
+	} else {
+		secrets, err = client.GetAt(ctx, path, at)
+	}
 	if err != nil {
 		return err
 	}
 
 	if len(secrets) == 0 {
+		if !at.IsZero() && exportRecursive {
+			// Directory was created after the requested time
+			fmt.Printf("Skipped %s (no secrets at that time)\n", path)
+			return nil
+		}
 		return fmt.Errorf("no secrets found at %s", path)
 	}
 
-	yamlData, err := yaml.Marshal(secrets)
+	data, err := vault.Encode(secrets, opts)
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+		return fmt.Errorf("%s: %w", path, err)
 	}
 
 	if outputFile == "" {
-		outputFile = getParentKey(path) + ".yaml"
+		outputFile = getParentKey(path) + opts.Format.Extension()
 	}
 
-	if err := os.WriteFile(outputFile, yamlData, 0600); err != nil {
+	if err := os.WriteFile(outputFile, data, 0600); err != nil {
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
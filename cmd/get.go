@@ -3,11 +3,10 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"time"
 
-	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var getCmd = &cobra.Command{
@@ -18,6 +17,15 @@ var getCmd = &cobra.Command{
 Recursively traverses all subdirectories by default.
 Outputs YAML. Optionally specify a key to get just that value.
 
+--format prints json, dotenv, properties, toml or hcl instead. dotenv and
+properties have no nesting, so nested keys are flattened: to UPPER_SNAKE
+names for dotenv (db.apiKey -> DB_API_KEY) and dotted names for properties.
+--key-style dot, underscore or upper-snake flattens keys in any format,
+and --key-prefix prepends a prefix to the flattened names. Values a format
+can't hold, such as lists in dotenv, are reported as errors. A single key
+is printed as plain text, or as a quoted value with --format json, toml
+or hcl.
+
 Example:
   vlt get secret/myapp
   # Prints all secrets under myapp as YAML
@@ -26,7 +34,18 @@ Example:
   # Prints all keys in the config secret as YAML
 
   vlt get secret/myapp/config apiKey
-  # Prints just the value of apiKey`,
+  # Prints just the value of apiKey
+
+  vlt get secret/myapp/config apiKey --format json
+  # Prints the value as JSON, e.g. "abc123"
+
+  vlt get secret/myapp --format json | jq .config.apiKey
+  vlt get secret/myapp/config --format dotenv --key-prefix MYAPP_ > .env
+  vlt get secret/myapp --format properties > application.properties
+
+  vlt get secret/myapp@2024-01-30T14:00:00Z
+  vlt get secret/myapp --at "2h ago"
+  # Prints secrets as they were at that time`,
 	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		key := ""
@@ -35,32 +54,45 @@ Example:
 		}
 		return runGet(cmd.Context(), args[0], key)
 	},
+	Annotations: map[string]string{pointInTimeAnnotation: "true", offlineAnnotation: "true"},
 }
 
 func init() {
+	addOutputFlags(getCmd)
 	rootCmd.AddCommand(getCmd)
 }
 
 func runGet(ctx context.Context, path, key string) error {
-	cfg, err := config.Load()
+	opts, err := outputOptions()
+	if err != nil {
+		return err
+	}
+
+	client, err := newReadClient(ctx)
 	if err != nil {
 		return err
 	}
 
-	client, err := vault.NewClient(cfg)
+	path, at, err := resolvePointInTime(path)
 	if err != nil {
 		return err
 	}
 
 	if key != "" {
-		return getKeyValue(ctx, client, path, key)
+		return getKeyValue(ctx, client, path, key, at, opts)
 	}
 
-	return getPath(ctx, client, path)
+	return getPath(ctx, client, path, at, opts)
 }
 
-func getPath(ctx context.Context, client *vault.Client, path string) error {
-	secrets, err := client.Get(ctx, path)
+func getPath(ctx context.Context, client *vault.Client, path string, at time.Time, opts vault.EncodeOptions) error {
+	var secrets map[string]any
+	var err error
+	if at.IsZero() {
+		secrets, err = client.Get(ctx, path)
+	} else {
+		secrets, err = client.GetAt(ctx, path, at)
+	}
 	if err != nil {
 		return err
 	}
@@ -69,33 +101,32 @@ func getPath(ctx context.Context, client *vault.Client, path string) error {
 		return fmt.Errorf("no secrets found at %s", path)
 	}
 
-	yamlData, err := yaml.Marshal(secrets)
+	data, err := vault.Encode(secrets, opts)
 	if err != nil {
-		return fmt.Errorf("failed to marshal YAML: %w", err)
+		return err
 	}
 
-	fmt.Print(string(yamlData))
+	fmt.Print(string(data))
 	return nil
 }
 
-func getKeyValue(ctx context.Context, client *vault.Client, path, key string) error {
-	value, err := client.GetValue(ctx, path, key)
+func getKeyValue(ctx context.Context, client *vault.Client, path, key string, at time.Time, opts vault.EncodeOptions) error {
+	var value any
+	var err error
+	if at.IsZero() {
+		value, err = client.GetValue(ctx, path, key)
+	} else {
+		value, err = client.GetValueAt(ctx, path, key, at)
+	}
 	if err != nil {
 		return err
 	}
 
-	switch v := value.(type) {
-	case string:
-		fmt.Println(v)
-	case map[string]any, []any:
-		yamlData, err := yaml.Marshal(v)
-		if err != nil {
-			return fmt.Errorf("failed to marshal YAML: %w", err)
-		}
-		fmt.Print(string(yamlData))
-	default:
-		fmt.Println(v)
+	data, err := vault.EncodeValue(value, key, opts)
+	if err != nil {
+		return err
 	}
 
+	fmt.Print(string(data))
 	return nil
 }
@@ -1,11 +1,20 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"time"
+
+	"github.com/ethanadams/vlt/pkg/config"
+	"github.com/ethanadams/vlt/pkg/vault"
+	"github.com/spf13/cobra"
 )
 
+// writeReason is the -m/--reason message recorded with writes
+var writeReason string
+
 // readValueFromArgs reads a value from command args or stdin.
 // If args has a value and it's "-", reads from stdin.
 // If args has no value, reads from stdin.
@@ -27,3 +36,108 @@ func readStdin() (string, error) {
 	}
 	return string(data), nil
 }
+
+// printTrashHint tells the user where deleted secrets went, if anything was trashed
+func printTrashHint(client *vault.Client) {
+	if id := client.TrashID(); id != "" {
+		fmt.Printf("Moved to trash as %s (undo with 'vlt trash restore %s')\n", id, id)
+	}
+}
+
+// newReadClient returns a client for read commands: one serving the --from-snapshot
+// file if given, else one connected to Vault
+func newReadClient(ctx context.Context) (*vault.Client, error) {
+	if fromSnapshot != "" {
+		snapshot, err := LoadSnapshot(ctx, fromSnapshot, true, "")
+		if err != nil {
+			return nil, err
+		}
+		return vault.NewSnapshotClient(snapshot)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	return vault.NewClient(cfg)
+}
+
+// resolvePointInTime splits an @<time> suffix off a path, falling back to the global
+// --at flag. Returns a zero time when the current state is wanted.
+func resolvePointInTime(path string) (string, time.Time, error) {
+	basePath, spec := vault.ParseVersionedPath(path)
+	if spec.IsAt {
+		return basePath, spec.At, nil
+	}
+
+	if globalAt == "" {
+		return path, time.Time{}, nil
+	}
+
+	t, err := vault.ParseTimeSpec(globalAt)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return path, t, nil
+}
+
+// parseTimeOrAgo parses a point in time (see vault.ParseTimeSpec) or a duration
+// before now (e.g. "7d")
+func parseTimeOrAgo(s string) (time.Time, error) {
+	if d, err := vault.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return vault.ParseTimeSpec(s)
+}
+
+// addReasonFlag adds the -m/--reason flag to a command that writes secrets
+func addReasonFlag(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&writeReason, "reason", "m", "", "why the change is made, recorded with each written version")
+}
+
+// recordProvenance makes the client stamp each version it writes with the token
+// identity, hostname, command and -m/--reason, unless disabled by VLT_PROVENANCE.
+// Stamping is best-effort: the first failure is reported on stderr, the writes go on.
+func recordProvenance(ctx context.Context, client *vault.Client, cfg *config.Config) {
+	if !cfg.Provenance {
+		return
+	}
+	client.SetProvenance(client.LookupProvenance(ctx, commandPath, writeReason))
+
+	warned := false
+	client.SetProvenanceWarning(func(err error) {
+		if !warned {
+			fmt.Fprintf(os.Stderr, "Warning: writes succeed, but who made them can't be recorded: %v\n(set VLT_PROVENANCE=false to stop recording it)\n", err)
+			warned = true
+		}
+	})
+}
+
+// Output format flags of commands that print or export secrets
+var (
+	outputFormat    string
+	outputKeyStyle  string
+	outputKeyPrefix string
+)
+
+// addOutputFlags adds the --format, --key-style and --key-prefix flags
+func addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&outputFormat, "format", string(vault.OutputYAML), "output format: yaml, json, dotenv, properties, toml or hcl")
+	cmd.Flags().StringVar(&outputKeyStyle, "key-style", "", "flatten nested keys: dot, underscore or upper-snake (default depends on the format)")
+	cmd.Flags().StringVar(&outputKeyPrefix, "key-prefix", "", "prefix for flattened keys, e.g. APP_")
+}
+
+// outputOptions returns the encoding chosen with the output flags
+func outputOptions() (vault.EncodeOptions, error) {
+	format, err := vault.ParseOutputFormat(outputFormat)
+	if err != nil {
+		return vault.EncodeOptions{}, err
+	}
+	opts := vault.EncodeOptions{Format: format, Prefix: outputKeyPrefix}
+	if outputKeyStyle != "" {
+		if opts.Keys, err = vault.ParseKeyStyle(outputKeyStyle); err != nil {
+			return vault.EncodeOptions{}, err
+		}
+	}
+	return opts, nil
+}
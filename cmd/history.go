@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
 
-	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
 )
@@ -14,6 +18,13 @@ var (
 	historyLimit      int
 	historyAll        bool
 	historyShowValues bool
+	historyKey        string
+	historySince      string
+	historyUntil      string
+	historyPaths      []string
+	historyOutput     string
+	historyChangelog  bool
+	historyFollow     bool
 )
 
 var historyCmd = &cobra.Command{
@@ -24,17 +35,44 @@ var historyCmd = &cobra.Command{
 For a single secret, shows all versions with timestamps.
 For a directory, shows a timeline of all changes across secrets.
 
+--since and --until take a time (2024-01-30, "2024-01-30 14:00")
+or a duration before now (24h, 7d). --path-filter takes glob patterns
+matched against secret paths relative to the directory; a pattern
+matching a directory selects every secret below it.
+
+--changelog renders a Markdown summary of added, changed and removed
+keys grouped by day. It only lists key names, never values.
+
+--key follows a key as 'vlt blame' names it: nested keys are flattened
+(db.password), and in a directory prefixed with the secret's relative
+path (config.db.password for key db.password of secret config).
+
+--follow includes versions written before a secret was moved with
+'vlt mv', as long as the old history was kept in the trash or the move
+used --with-history. Otherwise those versions were purged, and --follow
+warns that the history stops at the move.
+
+When --since, --until or --changelog is given, all matching entries
+are shown unless -n is passed.
+
 Examples:
   vlt history secret/myapp/config
-  vlt history secret/myapp/config -v            # show what changed
-  vlt history secret/myapp/config --show-values # show actual values
-  vlt history secret/myapp                      # directory timeline
-  vlt history secret/myapp -n 5                 # last 5 entries
-  vlt history secret/myapp --all                # no limit`,
+  vlt history secret/myapp/config -v             # show what changed
+  vlt history secret/myapp/config --show-values  # show actual values
+  vlt history secret/myapp/config --key password # follow a single key
+  vlt history secret/myapp --key config.db.host  # keys as blame names them
+  vlt history secret/myapp                       # directory timeline
+  vlt history secret/myapp -n 5                  # last 5 entries
+  vlt history secret/myapp --all                 # no limit
+  vlt history secret/myapp/config --follow       # include history before moves
+  vlt history secret/myapp --since 7d --path-filter 'db/*'
+  vlt history secret/myapp --since 2024-01-01 --output ndjson -v
+  vlt history secret/myapp --since 2024-01-01 --until 2024-01-31 --changelog`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return runHistory(cmd.Context(), args[0])
+		return runHistory(cmd.Context(), args[0], cmd.Flags().Changed("limit"))
 	},
+	Annotations: map[string]string{offlineAnnotation: "true"},
 }
 
 func init() {
@@ -42,16 +80,72 @@ func init() {
 	historyCmd.Flags().IntVarP(&historyLimit, "limit", "n", 10, "limit number of entries shown")
 	historyCmd.Flags().BoolVar(&historyAll, "all", false, "show all versions (no limit)")
 	historyCmd.Flags().BoolVar(&historyShowValues, "show-values", false, "show actual secret values (use with caution)")
+	historyCmd.Flags().StringVar(&historyKey, "key", "", "follow a single key, named as blame names it, across all versions")
+	historyCmd.Flags().StringVar(&historySince, "since", "", "only show changes at or after this time or duration ago (e.g. 7d, 2024-01-30)")
+	historyCmd.Flags().StringVar(&historyUntil, "until", "", "only show changes at or before this time or duration ago")
+	historyCmd.Flags().StringSliceVar(&historyPaths, "path-filter", nil, "only show secrets matching these globs (directories only)")
+	historyCmd.Flags().StringVar(&historyOutput, "output", "text", "output format: text, json or ndjson")
+	historyCmd.Flags().BoolVar(&historyChangelog, "changelog", false, "render a Markdown changelog grouped by day (key names only)")
+	historyCmd.Flags().BoolVar(&historyFollow, "follow", false, "continue the history across moves")
 	rootCmd.AddCommand(historyCmd)
 }
 
-func runHistory(ctx context.Context, path string) error {
-	cfg, err := config.Load()
+// historyOptions selects which history entries are shown
+type historyOptions struct {
+	filter    vault.TimelineFilter
+	unlimited bool
+}
+
+// limit returns how many of total entries to show
+func (o historyOptions) limit(total int) int {
+	if o.unlimited || historyLimit > total {
+		return total
+	}
+	return historyLimit
+}
+
+func newHistoryOptions(limitSet bool) (historyOptions, error) {
+	opts := historyOptions{
+		filter: vault.TimelineFilter{Paths: historyPaths},
+	}
+
+	var err error
+	if historySince != "" {
+		if opts.filter.Since, err = parseTimeOrAgo(historySince); err != nil {
+			return opts, err
+		}
+	}
+	if historyUntil != "" {
+		if opts.filter.Until, err = parseTimeOrAgo(historyUntil); err != nil {
+			return opts, err
+		}
+	}
+	if err := opts.filter.Validate(); err != nil {
+		return opts, err
+	}
+
+	windowed := historySince != "" || historyUntil != "" || historyChangelog
+	opts.unlimited = historyAll || (windowed && !limitSet)
+
+	return opts, nil
+}
+
+func runHistory(ctx context.Context, path string, limitSet bool) error {
+	switch historyOutput {
+	case "text", "json", "ndjson":
+	default:
+		return fmt.Errorf("invalid output format %q (use text, json or ndjson)", historyOutput)
+	}
+	if historyChangelog && historyOutput != "text" {
+		return fmt.Errorf("--changelog cannot be combined with --output %s", historyOutput)
+	}
+
+	opts, err := newHistoryOptions(limitSet)
 	if err != nil {
 		return err
 	}
 
-	client, err := vault.NewClient(cfg)
+	client, err := newReadClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -63,13 +157,71 @@ func runHistory(ctx context.Context, path string) error {
 		isDir = false
 	}
 
-	if isDir {
-		return showDirectoryHistory(ctx, client, path)
+	if !isDir && len(historyPaths) > 0 {
+		return fmt.Errorf("--path-filter selects secrets in a directory, %s is a single secret", path)
+	}
+
+	if historyKey != "" {
+		if historyFollow {
+			return fmt.Errorf("--key cannot be combined with --follow")
+		}
+		return showKeyHistory(ctx, client, path, historyKey, opts)
+	}
+
+	if historyChangelog || historyOutput != "text" {
+		entries, err := historyEntries(ctx, client, path, isDir, opts)
+		if err != nil {
+			return err
+		}
+		if historyChangelog {
+			return printChangelog(ctx, client, path, entries)
+		}
+		return printHistoryRecords(ctx, client, entries)
+	}
+
+	if isDir || historyFollow {
+		return showDirectoryHistory(ctx, client, path, opts)
+	}
+	return showSecretHistory(ctx, client, path, opts)
+}
+
+func showKeyHistory(ctx context.Context, client *vault.Client, path, key string, opts historyOptions) error {
+	changes, err := client.GetKeyHistory(ctx, path, key)
+	if err != nil {
+		return err
+	}
+
+	var filtered []vault.KeyVersionChange
+	for _, c := range changes {
+		if opts.filter.InWindow(c.Version.CreatedTime) {
+			filtered = append(filtered, c)
+		}
 	}
-	return showSecretHistory(ctx, client, path)
+	changes = filtered
+
+	limit := opts.limit(len(changes))
+
+	fmt.Printf("History of %s in %s:\n\n", key, path)
+
+	for _, c := range changes[:limit] {
+		fmt.Printf("v%-3d  %s  %s\n",
+			c.Version.Version,
+			c.Version.CreatedTime.Local().Format("2006-01-02 15:04:05"),
+			formatVersionChange(c.Change, historyShowValues),
+		)
+		if c.Version.Provenance != nil {
+			fmt.Printf("      %s\n", c.Version.Provenance)
+		}
+	}
+
+	if limit < len(changes) {
+		fmt.Printf("\n... and %d more changes (use --all to see all)\n", len(changes)-limit)
+	}
+
+	return nil
 }
 
-func showSecretHistory(ctx context.Context, client *vault.Client, path string) error {
+func showSecretHistory(ctx context.Context, client *vault.Client, path string, opts historyOptions) error {
 	versions, err := client.GetVersionHistory(ctx, path)
 	if err != nil {
 		return err
@@ -79,24 +231,35 @@ func showSecretHistory(ctx context.Context, client *vault.Client, path string) e
 		return fmt.Errorf("no versions found at %s", path)
 	}
 
-	// Apply limit
-	limit := historyLimit
-	if historyAll {
-		limit = len(versions)
-	}
-	if limit > len(versions) {
-		limit = len(versions)
+	var filtered []vault.VersionInfo
+	for _, v := range versions {
+		if opts.filter.InWindow(v.CreatedTime) {
+			filtered = append(filtered, v)
+		}
 	}
+	latest := versions[0].Version
+	versions = filtered
+
+	// Apply limit
+	limit := opts.limit(len(versions))
 
 	fmt.Printf("History for %s:\n\n", path)
 
 	for i, v := range versions[:limit] {
 		current := ""
-		if i == 0 {
+		if v.Version == latest {
 			current = "  (current)"
 		}
 
-		fmt.Printf("v%-3d  %s%s\n", v.Version, v.CreatedTime.Local().Format("2006-01-02 15:04:05"), current)
+		original := ""
+		if !v.OriginalTime.IsZero() {
+			original = fmt.Sprintf("  (originally %s)", v.OriginalTime.Local().Format("2006-01-02 15:04:05"))
+		}
+
+		fmt.Printf("v%-3d  %s%s%s\n", v.Version, v.CreatedTime.Local().Format("2006-01-02 15:04:05"), original, current)
+		if v.Provenance != nil {
+			fmt.Printf("      %s\n", v.Provenance)
+		}
 
 		// Verbose mode: show what changed
 		if (historyVerbose || historyShowValues) && i < limit-1 {
@@ -120,21 +283,15 @@ func showSecretHistory(ctx context.Context, client *vault.Client, path string) e
 	return nil
 }
 
-func showDirectoryHistory(ctx context.Context, client *vault.Client, path string) error {
-	// Use vault.GetTimeline to get the timeline
-	timeline, err := client.GetTimeline(ctx, path)
+func showDirectoryHistory(ctx context.Context, client *vault.Client, path string, opts historyOptions) error {
+	timeline, err := loadTimeline(ctx, client, path)
 	if err != nil {
 		return err
 	}
+	timeline = vault.FilterTimeline(timeline, opts.filter)
 
 	// Apply limit
-	limit := historyLimit
-	if historyAll {
-		limit = len(timeline)
-	}
-	if limit > len(timeline) {
-		limit = len(timeline)
-	}
+	limit := opts.limit(len(timeline))
 
 	fmt.Printf("History for %s:\n\n", path)
 
@@ -143,12 +300,18 @@ func showDirectoryHistory(ctx context.Context, client *vault.Client, path string
 		if entry.IsCreation {
 			action = "v1 (created)"
 		}
+		if entry.FormerPath != "" {
+			action += "  (as " + entry.FormerPath + ")"
+		}
 
 		fmt.Printf("%s  %-20s  %s\n",
 			entry.Time.Local().Format("2006-01-02 15:04:05"),
 			entry.SecretPath,
 			action,
 		)
+		if entry.Provenance != nil {
+			fmt.Printf("                             %s\n", entry.Provenance)
+		}
 
 		// Verbose mode for directories
 		if (historyVerbose || historyShowValues) && !entry.IsCreation {
@@ -190,3 +353,260 @@ func formatVersionChange(change vault.VersionChange, showValues bool) string {
 		return change.Key
 	}
 }
+
+// historyEntries returns the filtered history of a secret or directory as timeline entries,
+// newest first, with the limit applied
+func historyEntries(ctx context.Context, client *vault.Client, path string, isDir bool, opts historyOptions) ([]vault.TimelineEntry, error) {
+	var entries []vault.TimelineEntry
+
+	if isDir || historyFollow {
+		timeline, err := loadTimeline(ctx, client, path)
+		if err != nil {
+			return nil, err
+		}
+		entries = vault.FilterTimeline(timeline, opts.filter)
+	} else {
+		versions, err := client.GetVersionHistory(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("no versions found at %s", path)
+		}
+		for _, v := range versions {
+			if opts.filter.InWindow(v.CreatedTime) {
+				entries = append(entries, vault.TimelineEntry{
+					Time:       v.CreatedTime,
+					SecretPath: path,
+					FullPath:   path,
+					Version:    v.Version,
+					IsCreation: v.Version == 1,
+					Provenance: v.Provenance,
+				})
+			}
+		}
+	}
+
+	return entries[:opts.limit(len(entries))], nil
+}
+
+// loadTimeline returns the directory timeline, followed across moves with --follow
+func loadTimeline(ctx context.Context, client *vault.Client, path string) ([]vault.TimelineEntry, error) {
+	if historyFollow {
+		timeline, gaps, err := client.GetTimelineFollow(ctx, path)
+		for _, gap := range gaps {
+			moved := ""
+			if !gap.MovedAt.IsZero() {
+				moved = " on " + gap.MovedAt.Local().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(os.Stderr, "Warning: %s was moved from %s%s without keeping its versions (the trash was disabled), so its history stops there\n", gap.SecretPath, gap.From, moved)
+		}
+		return timeline, err
+	}
+	return client.GetTimeline(ctx, path)
+}
+
+// historyRecord is the JSON representation of a history entry
+type historyRecord struct {
+	Time       time.Time         `json:"time"`
+	Path       string            `json:"path"`
+	FormerPath string            `json:"former_path,omitempty"`
+	Version    int               `json:"version"`
+	Created    bool              `json:"created,omitempty"`
+	Changes    []historyChange   `json:"changes,omitempty"`
+	Provenance *vault.Provenance `json:"provenance,omitempty"`
+}
+
+// historyChange is the JSON representation of a key change.
+// Values are only included with --show-values.
+type historyChange struct {
+	Key       string `json:"key"`
+	Type      string `json:"type"`
+	OldValue  string `json:"old_value,omitempty"`
+	NewValue  string `json:"new_value,omitempty"`
+	OldLength int    `json:"old_length,omitempty"`
+	NewLength int    `json:"new_length,omitempty"`
+}
+
+// printHistoryRecords prints history entries as a JSON array or as one JSON object per line
+func printHistoryRecords(ctx context.Context, client *vault.Client, entries []vault.TimelineEntry) error {
+	records := make([]historyRecord, 0, len(entries))
+	for _, entry := range entries {
+		record := historyRecord{
+			Time:       entry.Time,
+			Path:       entry.FullPath,
+			Version:    entry.Version,
+			Created:    entry.IsCreation,
+			Provenance: entry.Provenance,
+			FormerPath: entry.FormerPath,
+		}
+
+		if historyVerbose || historyShowValues {
+			changes, err := client.GetEntryChanges(ctx, entry)
+			if err != nil {
+				return err
+			}
+			for _, change := range changes {
+				c := historyChange{
+					Key:       change.Key,
+					Type:      changeTypeName(change.Type),
+					OldLength: change.OldLength,
+					NewLength: change.NewLength,
+				}
+				if historyShowValues {
+					c.OldValue = change.OldValue
+					c.NewValue = change.NewValue
+				}
+				record.Changes = append(record.Changes, c)
+			}
+		}
+
+		records = append(records, record)
+	}
+
+	if historyOutput == "ndjson" {
+		enc := json.NewEncoder(os.Stdout)
+		for _, record := range records {
+			if err := enc.Encode(record); err != nil {
+				return fmt.Errorf("failed to marshal JSON: %w", err)
+			}
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// changeTypeName returns the name of a change type used in machine output
+func changeTypeName(t vault.ChangeType) string {
+	switch t {
+	case vault.ChangeAdded:
+		return "added"
+	case vault.ChangeModified:
+		return "modified"
+	case vault.ChangeDeleted:
+		return "deleted"
+	default:
+		return "unknown"
+	}
+}
+
+// changelogSecret collects the key names changed in a secret on one day
+type changelogSecret struct {
+	created bool
+	added   map[string]bool
+	changed map[string]bool
+	removed map[string]bool
+}
+
+// printChangelog prints history entries as a Markdown summary grouped by day, newest first.
+// Only key names are included, never values.
+func printChangelog(ctx context.Context, client *vault.Client, path string, entries []vault.TimelineEntry) error {
+	var days []string
+	byDay := make(map[string]map[string]*changelogSecret)
+
+	for _, entry := range entries {
+		day := entry.Time.Local().Format("2006-01-02")
+		secrets, ok := byDay[day]
+		if !ok {
+			secrets = make(map[string]*changelogSecret)
+			byDay[day] = secrets
+			days = append(days, day)
+		}
+
+		secret, ok := secrets[entry.SecretPath]
+		if !ok {
+			secret = &changelogSecret{
+				added:   make(map[string]bool),
+				changed: make(map[string]bool),
+				removed: make(map[string]bool),
+			}
+			secrets[entry.SecretPath] = secret
+		}
+		secret.created = secret.created || entry.IsCreation
+
+		changes, err := client.GetEntryChanges(ctx, entry)
+		if err != nil {
+			return err
+		}
+		for _, change := range changes {
+			switch change.Type {
+			case vault.ChangeAdded:
+				secret.added[change.Key] = true
+			case vault.ChangeModified:
+				secret.changed[change.Key] = true
+			case vault.ChangeDeleted:
+				secret.removed[change.Key] = true
+			}
+		}
+	}
+
+	fmt.Printf("## Changes to %s\n", path)
+
+	if len(days) == 0 {
+		fmt.Printf("\nNo changes.\n")
+		return nil
+	}
+
+	for _, day := range days {
+		fmt.Printf("\n### %s\n\n", day)
+
+		secrets := byDay[day]
+		names := make([]string, 0, len(secrets))
+		for name := range secrets {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("- **%s**: %s\n", name, secrets[name].summary())
+		}
+	}
+
+	return nil
+}
+
+// summary describes the changes to a secret, e.g. "added `port`; changed `password`"
+func (s *changelogSecret) summary() string {
+	// Keys added that day are reported as added, even if changed again later
+	for key := range s.added {
+		delete(s.changed, key)
+	}
+
+	var parts []string
+	if keys := changelogKeys(s.added); keys != "" {
+		if s.created {
+			parts = append(parts, "created with "+keys)
+		} else {
+			parts = append(parts, "added "+keys)
+		}
+	} else if s.created {
+		parts = append(parts, "created")
+	}
+	if keys := changelogKeys(s.changed); keys != "" {
+		parts = append(parts, "changed "+keys)
+	}
+	if keys := changelogKeys(s.removed); keys != "" {
+		parts = append(parts, "removed "+keys)
+	}
+	if len(parts) == 0 {
+		return "rewritten without changes"
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// changelogKeys formats a set of key names as a sorted, comma-separated code list
+func changelogKeys(keys map[string]bool) string {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, "`"+key+"`")
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
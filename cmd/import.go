@@ -64,6 +64,7 @@ func init() {
 	importCmd.Flags().BoolVar(&importUpdateCounterpart, "update-counterpart", false, "update counterpart YAML file with vault references")
 	importCmd.Flags().StringVar(&importMount, "mount", "", "KV v2 mount path (default: first path segment)")
 	importCmd.Flags().BoolVar(&importSops, "sops", false, "decrypt SOPS-encrypted file before importing")
+	addReasonFlag(importCmd)
 	rootCmd.AddCommand(importCmd)
 }
 
@@ -133,6 +134,7 @@ func runImport(ctx context.Context, yamlFile, vaultPath string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
 
 	// Import secrets (mount is auto-detected from path)
 	count, err := client.Import(ctx, fullPath, data)
@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
 )
@@ -26,6 +25,7 @@ Example:
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runLs(cmd.Context(), args[0])
 	},
+	Annotations: map[string]string{offlineAnnotation: "true"},
 }
 
 func init() {
@@ -34,12 +34,7 @@ func init() {
 }
 
 func runLs(ctx context.Context, path string) error {
-	cfg, err := config.Load()
-	if err != nil {
-		return err
-	}
-
-	client, err := vault.NewClient(cfg)
+	client, err := newReadClient(ctx)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethanadams/vlt/pkg/config"
+	"github.com/ethanadams/vlt/pkg/vault"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	metaRecursive          bool
+	metaMaxVersions        int
+	metaCASRequired        bool
+	metaDeleteVersionAfter string
+)
+
+var metaCmd = &cobra.Command{
+	Use:   "meta",
+	Short: "Manage secret metadata",
+	Long: `Manage secret metadata: custom metadata keys and the max_versions,
+cas_required and delete_version_after settings.
+
+Examples:
+  vlt meta get secret/myapp
+  vlt meta set secret/myapp/config owner=team-a ticket=OPS-123
+  vlt meta set secret/myapp --max-versions 20 -r
+  vlt meta unset secret/myapp/config ticket`,
+}
+
+var metaGetCmd = &cobra.Command{
+	Use:   "get <path>",
+	Short: "Show metadata for a secret or all secrets under a path",
+	Long: `Show metadata for a secret or all secrets under a path as YAML.
+
+Recursively traverses all subdirectories.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMetaGet(cmd.Context(), args[0])
+	},
+}
+
+var metaSetCmd = &cobra.Command{
+	Use:   "set <path> [key=value...]",
+	Short: "Set custom metadata and settings on secrets",
+	Long: `Set custom metadata keys and settings on a secret.
+
+Existing custom metadata keys not mentioned are kept.
+Use -r to apply to all secrets under a path.
+
+Examples:
+  vlt meta set secret/myapp/config owner=team-a
+  vlt meta set secret/myapp/config --cas-required
+  vlt meta set secret/myapp --delete-version-after 90d -r`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		update, err := metaUpdateFromFlags(cmd, args[1:])
+		if err != nil {
+			return err
+		}
+		return runMetaUpdate(cmd.Context(), args[0], update)
+	},
+}
+
+var metaUnsetCmd = &cobra.Command{
+	Use:   "unset <path> <key>...",
+	Short: "Remove custom metadata keys from secrets",
+	Long: `Remove custom metadata keys from a secret.
+
+Use -r to apply to all secrets under a path.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runMetaUpdate(cmd.Context(), args[0], vault.MetadataUpdate{UnsetCustom: args[1:]})
+	},
+}
+
+func init() {
+	metaSetCmd.Flags().BoolVarP(&metaRecursive, "recursive", "r", false, "apply to all secrets under the path")
+	metaSetCmd.Flags().IntVar(&metaMaxVersions, "max-versions", 0, "number of versions to keep (0 uses the mount default)")
+	metaSetCmd.Flags().BoolVar(&metaCASRequired, "cas-required", false, "require check-and-set on writes")
+	metaSetCmd.Flags().StringVar(&metaDeleteVersionAfter, "delete-version-after", "", "delete versions older than this duration (e.g. 90d, 0s disables)")
+	metaUnsetCmd.Flags().BoolVarP(&metaRecursive, "recursive", "r", false, "apply to all secrets under the path")
+
+	metaCmd.AddCommand(metaGetCmd)
+	metaCmd.AddCommand(metaSetCmd)
+	metaCmd.AddCommand(metaUnsetCmd)
+	rootCmd.AddCommand(metaCmd)
+}
+
+// metadataView is the YAML representation of a secret's metadata
+type metadataView struct {
+	CurrentVersion     int               `yaml:"current_version"`
+	MaxVersions        int               `yaml:"max_versions"`
+	CASRequired        bool              `yaml:"cas_required"`
+	DeleteVersionAfter string            `yaml:"delete_version_after"`
+	CustomMetadata     map[string]string `yaml:"custom_metadata,omitempty"`
+}
+
+func newMetadataView(m *vault.SecretMetadata) metadataView {
+	return metadataView{
+		CurrentVersion:     m.CurrentVersion,
+		MaxVersions:        m.MaxVersions,
+		CASRequired:        m.CASRequired,
+		DeleteVersionAfter: m.DeleteVersionAfter.String(),
+		CustomMetadata:     m.CustomMetadata,
+	}
+}
+
+func runMetaGet(ctx context.Context, path string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	tree, err := client.GetMetadataTree(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	var out any
+	if m, ok := tree[""]; ok && len(tree) == 1 {
+		out = newMetadataView(m)
+	} else {
+		views := make(map[string]metadataView, len(tree))
+		for relPath, m := range tree {
+			views[relPath] = newMetadataView(m)
+		}
+		out = views
+	}
+
+	yamlData, err := yaml.Marshal(out)
+	if err != nil {
+		return fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+
+	fmt.Print(string(yamlData))
+	return nil
+}
+
+// metaUpdateFromFlags builds a metadata update from key=value args and the set flags
+func metaUpdateFromFlags(cmd *cobra.Command, pairs []string) (vault.MetadataUpdate, error) {
+	update := vault.MetadataUpdate{}
+
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || key == "" {
+			return update, fmt.Errorf("invalid metadata %q, expected key=value", pair)
+		}
+		if update.SetCustom == nil {
+			update.SetCustom = make(map[string]string)
+		}
+		update.SetCustom[key] = value
+	}
+
+	if cmd.Flags().Changed("max-versions") {
+		update.MaxVersions = &metaMaxVersions
+	}
+	if cmd.Flags().Changed("cas-required") {
+		update.CASRequired = &metaCASRequired
+	}
+	if cmd.Flags().Changed("delete-version-after") {
+		d, err := vault.ParseDuration(metaDeleteVersionAfter)
+		if err != nil {
+			return update, err
+		}
+		update.DeleteVersionAfter = &d
+	}
+
+	if update.IsEmpty() {
+		return update, fmt.Errorf("nothing to set: pass key=value pairs or a setting flag")
+	}
+
+	return update, nil
+}
+
+func runMetaUpdate(ctx context.Context, path string, update vault.MetadataUpdate) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	if !metaRecursive {
+		exists, err := client.SecretExists(ctx, path)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if isDir, _ := client.IsDirectory(ctx, path); isDir {
+				return fmt.Errorf("cannot update %s: is a directory (use -r to update recursively)", path)
+			}
+		}
+
+		if err := client.UpdateMetadata(ctx, path, update); err != nil {
+			return err
+		}
+		fmt.Printf("Updated metadata of %s\n", path)
+		return nil
+	}
+
+	updated, err := client.UpdateMetadataRecursive(ctx, path, update)
+	for _, p := range updated {
+		fmt.Printf("Updated metadata of %s\n", p)
+	}
+	return err
+}
@@ -3,12 +3,18 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
 
 	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
 )
 
+var (
+	mvResetMetadata bool
+	mvWithHistory   bool
+)
+
 var mvCmd = &cobra.Command{
 	Use:   "mv <source> <destination>",
 	Short: "Move or rename a secret or directory",
@@ -16,11 +22,24 @@ var mvCmd = &cobra.Command{
 
 Never overwrites existing secrets at the destination path.
 When moving a directory, all secrets within it are moved.
+Custom metadata and settings move with the secret unless
+--reset-metadata is given.
+
+By default only the latest version is written to the destination.
+Use --with-history to replay all versions in order, so history
+continues at the new path. Original version timestamps are recorded
+in custom metadata (vlt_created_v1, vlt_created_v2, ...).
+
+'vlt history --follow' finds the versions written before a move in the
+trash. When the trash is disabled (VLT_TRASH_PATH not set), the source
+is purged, so without --with-history those versions are lost and mv
+warns about it.
 
 Examples:
   vlt mv secret/abc/123 secret/def/xyz/123
   vlt mv secret/old-name secret/new-name
-  vlt mv secret/myapp/config secret/myapp/config-backup`,
+  vlt mv secret/myapp/config secret/myapp/config-backup
+  vlt mv secret/app secret/app-v2 --with-history`,
 	Args: cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runMv(cmd.Context(), args[0], args[1])
@@ -28,6 +47,9 @@ Examples:
 }
 
 func init() {
+	mvCmd.Flags().BoolVar(&mvWithHistory, "with-history", false, "move all versions, not only the latest")
+	mvCmd.Flags().BoolVar(&mvResetMetadata, "reset-metadata", false, "don't carry over custom metadata and settings")
+	addReasonFlag(mvCmd)
 	rootCmd.AddCommand(mvCmd)
 }
 
@@ -41,6 +63,7 @@ func runMv(ctx context.Context, src, dst string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
 
 	// Check if source is a directory
 	isDir, err := client.IsDirectory(ctx, src)
@@ -48,18 +71,33 @@ func runMv(ctx context.Context, src, dst string) error {
 		return err
 	}
 
+	opts := vault.CopyOptions{ResetMetadata: mvResetMetadata, WithHistory: mvWithHistory}
+
 	if isDir {
-		count, err := client.MoveRecursive(ctx, src, dst)
+		count, err := client.MoveRecursiveWithOptions(ctx, src, dst, opts)
 		if err != nil {
 			return err
 		}
 		fmt.Printf("Moved %d secrets from %s -> %s\n", count, src, dst)
+		printTrashHint(client)
+		warnHistoryPurged(client, src)
 		return nil
 	}
 
-	if err := client.Move(ctx, src, dst); err != nil {
+	if err := client.MoveWithOptions(ctx, src, dst, opts); err != nil {
 		return err
 	}
 	fmt.Printf("Moved %s -> %s\n", src, dst)
+	printTrashHint(client)
+	warnHistoryPurged(client, src)
 	return nil
 }
+
+// warnHistoryPurged warns that the versions of a moved source were purged, as they
+// are neither in the trash nor replayed at the destination
+func warnHistoryPurged(client *vault.Client, src string) {
+	if client.TrashEnabled() || mvWithHistory {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: the trash is disabled, so the versions of %s before the move were purged and 'vlt history --follow' can't show them\n(use --with-history, or set VLT_TRASH_PATH, to keep them)\n", src)
+}
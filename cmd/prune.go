@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethanadams/vlt/pkg/config"
+	"github.com/ethanadams/vlt/pkg/vault"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneRecursive bool
+	pruneKeep      int
+	pruneOlderThan string
+	pruneDestroy   bool
+	pruneDelete    bool
+	pruneDryRun    bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune <path>",
+	Short: "Remove old secret versions by a retention policy",
+	Long: `Remove old versions of secrets that match a retention policy.
+
+--keep N keeps the N newest versions, --older-than only removes versions
+older than a duration. When both are given, a version is removed only if
+it matches both. The current version is never removed.
+
+By default versions are soft-deleted (--delete) and can still be recovered
+with the Vault undelete API. Use --destroy to remove their data permanently,
+including versions that were already soft-deleted.
+
+Examples:
+  vlt prune secret/myapp/config --keep 5 --dry-run
+  vlt prune secret/myapp -r --keep 5 --older-than 90d
+  vlt prune secret/myapp/leaked --keep 1 --destroy`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runPrune(cmd.Context(), args[0])
+	},
+}
+
+func init() {
+	pruneCmd.Flags().BoolVarP(&pruneRecursive, "recursive", "r", false, "prune all secrets under the path")
+	pruneCmd.Flags().IntVar(&pruneKeep, "keep", 0, "keep this many of the newest versions")
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "only remove versions older than this duration (e.g. 90d)")
+	pruneCmd.Flags().BoolVar(&pruneDestroy, "destroy", false, "permanently destroy versions")
+	pruneCmd.Flags().BoolVar(&pruneDelete, "delete", false, "soft-delete versions, recoverable with undelete (default)")
+	pruneCmd.Flags().BoolVar(&pruneDryRun, "dry-run", false, "list versions that would be removed without removing them")
+	rootCmd.AddCommand(pruneCmd)
+}
+
+func runPrune(ctx context.Context, path string) error {
+	if pruneDestroy && pruneDelete {
+		return fmt.Errorf("--destroy and --delete are mutually exclusive")
+	}
+
+	opts := vault.PruneOptions{
+		Keep:    pruneKeep,
+		Destroy: pruneDestroy,
+		DryRun:  pruneDryRun,
+	}
+	if pruneOlderThan != "" {
+		d, err := vault.ParseDuration(pruneOlderThan)
+		if err != nil {
+			return err
+		}
+		opts.OlderThan = d
+	}
+	if opts.Keep <= 0 && opts.OlderThan <= 0 {
+		return fmt.Errorf("specify a retention policy with --keep and/or --older-than")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	var pruned []vault.PrunedSecret
+	if pruneRecursive {
+		pruned, err = client.PruneRecursive(ctx, path, opts)
+	} else {
+		exists, existsErr := client.SecretExists(ctx, path)
+		if existsErr != nil {
+			return existsErr
+		}
+		if !exists {
+			if isDir, _ := client.IsDirectory(ctx, path); isDir {
+				return fmt.Errorf("cannot prune %s: is a directory (use -r to prune recursively)", path)
+			}
+		}
+
+		var versions []int
+		versions, err = client.PruneVersions(ctx, path, opts)
+		if len(versions) > 0 {
+			pruned = []vault.PrunedSecret{{Path: path, Versions: versions}}
+		}
+	}
+
+	if err != nil && len(pruned) == 0 {
+		return err
+	}
+
+	printPruneResult(pruned, opts)
+	return err
+}
+
+func printPruneResult(pruned []vault.PrunedSecret, opts vault.PruneOptions) {
+	action := "Deleted"
+	switch {
+	case opts.DryRun && opts.Destroy:
+		action = "Would destroy"
+	case opts.DryRun:
+		action = "Would delete"
+	case opts.Destroy:
+		action = "Destroyed"
+	}
+
+	total := 0
+	for _, p := range pruned {
+		versions := make([]string, len(p.Versions))
+		for i, v := range p.Versions {
+			versions[i] = fmt.Sprintf("v%d", v)
+		}
+		fmt.Printf("%s %s: %s\n", action, p.Path, strings.Join(versions, ", "))
+		total += len(p.Versions)
+	}
+
+	if total == 0 {
+		fmt.Println("No versions match the retention policy")
+		return
+	}
+
+	fmt.Printf("\n%d version(s) in %d secret(s)\n", total, len(pruned))
+	if opts.DryRun {
+		fmt.Println("\nRun without --dry-run to apply.")
+	}
+}
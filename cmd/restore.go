@@ -1,9 +1,18 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
 	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
@@ -11,43 +20,126 @@ import (
 )
 
 var (
-	restoreDryRun    bool
-	restoreVerify    bool
-	restoreNoDelete  bool
+	restoreDryRun         bool
+	restoreVerify         bool
+	restoreNoDelete       bool
+	restoreAllowPlaintext bool
+	restoreLatestOnly     bool
+	restoreVerifyKey      string
+	restoreKeepPartial    bool
+	restoreInclude        []string
+	restoreExclude        []string
+	restoreMap            []string
+	restoreOnConflict     string
+	restoreShowValues     bool
 )
 
+// errRestoreAborted is returned when the restore is quit at a conflict prompt
+var errRestoreAborted = errors.New("restore aborted, nothing was changed")
+
 var restoreCmd = &cobra.Command{
-	Use:   "restore <file> <path>",
-	Short: "Restore secrets from a snapshot",
+	Use:   "restore <file> <path> | restore <path>@<time>",
+	Short: "Restore secrets from a snapshot or from version history",
 	Long: `Restore secrets from a previously created snapshot.
 
+With a single path argument, secrets are restored to the state they
+were in at a point in time (path@<time> or --at) or N changes ago
+(path@-N), read from the KV version history. No snapshot file is needed.
+
 By default, secrets that exist in Vault but not in the snapshot will be deleted.
-Use --no-delete to preserve extra secrets.
+Use --no-delete to preserve extra secrets. When restoring from history,
+this applies to secrets created after the point being restored.
+
+A secret in Vault conflicts with the snapshot if it was modified after
+the snapshot recorded it: it is no longer at the recorded version, or was
+updated after the recorded update time. --on-conflict decides what
+happens to secrets that conflict and would be changed:
+  overwrite   restore the snapshot value anyway (default)
+  skip        keep the secret as it is in Vault
+  fail        refuse the restore, before anything is changed
+  prompt      show a diff of each conflicting secret and ask
+  newer-wins  keep whichever of the two was updated last
+Conflicts are checked, and with prompt answered, before the restore
+writes anything. --show-values shows values in the prompt diffs.
+
+Snapshots taken with --all-versions replay the full history of secrets
+that do not exist at the target. Use --latest-only to write only their
+current value.
 
-Use --verify to only restore if secret versions match the snapshot
-(fails if secrets were modified since the snapshot was taken).
+SOPS-encrypted snapshots are decrypted transparently with the usual SOPS
+key sources. Plaintext snapshots are refused unless --allow-plaintext is given.
+The snapshot is checked against its manifest before anything is written;
+use --verify-key to also require a valid signature.
+
+Backups written by medusa, or as a JSON object of 'vault kv get -format=json'
+outputs keyed by path (see 'vlt snapshot --format'), are detected and
+restored too. They are plaintext, carry no manifest and hold only current
+values; medusa files have no version numbers, so every existing secret
+they would change conflicts.
+
+--include and --exclude take glob patterns matched against paths relative
+to the snapshot root; a pattern matching a directory selects every secret
+below it. --map old/prefix=new/prefix restores secrets under a different
+relative path (the first matching mapping applies). With a selection,
+only secrets at the target that it covers are deleted.
+
+Restores are transactional: the prior state of every secret is recorded
+before it is changed, and if the restore fails or is interrupted (Ctrl-C)
+all changes are rolled back. Use --keep-partial to leave the changes made
+so far in place instead; they are listed exactly.
 
 Examples:
-  vlt restore backup.yaml secret/myapp
-  vlt restore backup.yaml secret/myapp --dry-run    # preview changes
-  vlt restore backup.yaml secret/myapp --verify     # fail if modified
-  vlt restore backup.yaml secret/myapp --no-delete  # don't delete extra secrets`,
-	Args: cobra.ExactArgs(2),
+  vlt restore backup.enc.yaml secret/myapp
+  vlt restore backup.enc.yaml secret/myapp --dry-run    # preview changes
+  vlt restore backup.enc.yaml secret/myapp --on-conflict fail     # refuse if modified since
+  vlt restore backup.enc.yaml secret/myapp --on-conflict prompt   # ask about each
+  vlt restore backup.enc.yaml secret/myapp --no-delete  # don't delete extra secrets
+  vlt restore backup.yaml secret/myapp --allow-plaintext
+  vlt restore medusa-export.yaml secret/myapp --allow-plaintext
+  vlt restore backup.enc.yaml.gz secret/myapp --verify-key backup-signing.pub
+  vlt restore backup.enc.yaml secret/myapp --keep-partial  # don't roll back on failure
+  vlt restore backup.enc.yaml secret/scratch --include 'database/*'
+  vlt restore backup.enc.yaml secret/myapp --exclude 'cache/*' --exclude legacy
+  vlt restore backup.enc.yaml secret/myapp --map database/old=database/new
+  vlt restore backup.enc.yaml secret/scratch --include database/password --map database/password=db-pw
+  vlt restore secret/myapp@2024-01-30T10:00Z --dry-run
+  vlt restore secret/myapp@-3                       # undo the last 3 changes
+  vlt restore secret/myapp --at "2h ago"`,
+	Args: cobra.RangeArgs(1, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			return runRestoreFromHistory(cmd.Context(), args[0])
+		}
 		return runRestore(cmd.Context(), args[0], args[1])
 	},
+	Annotations: map[string]string{pointInTimeAnnotation: "true"},
 }
 
 func init() {
 	restoreCmd.Flags().BoolVar(&restoreDryRun, "dry-run", false, "preview changes without applying")
-	restoreCmd.Flags().BoolVar(&restoreVerify, "verify", false, "only restore if versions match snapshot")
+	restoreCmd.Flags().StringVar(&restoreOnConflict, "on-conflict", string(vault.ConflictOverwrite), "secrets modified since the snapshot: skip, overwrite, fail, prompt or newer-wins")
+	restoreCmd.Flags().BoolVar(&restoreShowValues, "show-values", false, "show values in --on-conflict prompt diffs")
+	restoreCmd.Flags().BoolVar(&restoreVerify, "verify", false, "skip secrets modified since the snapshot")
+	_ = restoreCmd.Flags().MarkDeprecated("verify", "use --on-conflict skip")
 	restoreCmd.Flags().BoolVar(&restoreNoDelete, "no-delete", false, "don't delete secrets not in snapshot")
+	restoreCmd.Flags().BoolVar(&restoreLatestOnly, "latest-only", false, "don't replay version history from the snapshot")
+	restoreCmd.Flags().BoolVar(&restoreAllowPlaintext, "allow-plaintext", false, "load an unencrypted snapshot file")
+	restoreCmd.Flags().StringVar(&restoreVerifyKey, "verify-key", "", "require a valid signature by this ed25519 public key (PEM file)")
+	restoreCmd.Flags().BoolVar(&restoreKeepPartial, "keep-partial", false, "on failure, keep the changes applied so far instead of rolling back")
+	restoreCmd.Flags().StringSliceVar(&restoreInclude, "include", nil, "only restore secrets matching these globs")
+	restoreCmd.Flags().StringSliceVar(&restoreExclude, "exclude", nil, "don't restore secrets matching these globs")
+	restoreCmd.Flags().StringSliceVar(&restoreMap, "map", nil, "restore secrets under old/prefix to new/prefix instead (old/prefix=new/prefix)")
+	addReasonFlag(restoreCmd)
 	rootCmd.AddCommand(restoreCmd)
 }
 
 func runRestore(ctx context.Context, snapshotFile, targetPath string) error {
+	if globalAt != "" {
+		return fmt.Errorf("--at restores from version history, pass only a path instead of a snapshot file")
+	}
+
 	// Load snapshot
-	snapshot, err := LoadSnapshot(snapshotFile)
+	snapshot, err := LoadSnapshot(ctx, snapshotFile, restoreAllowPlaintext, restoreVerifyKey)
 	if err != nil {
 		return err
 	}
@@ -61,24 +153,162 @@ func runRestore(ctx context.Context, snapshotFile, targetPath string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
+
+	return applyRestore(ctx, client, snapshot, targetPath)
+}
+
+func runRestoreFromHistory(ctx context.Context, path string) error {
+	basePath, spec := vault.ParseVersionedPath(path)
+	if spec.IsChangesAgo {
+		if globalAt != "" {
+			return fmt.Errorf("cannot combine @-%d with --at", spec.ChangesAgo)
+		}
+		return restoreChangesAgo(ctx, basePath, spec.ChangesAgo)
+	}
+	if spec.Version > 0 || spec.IsPrev {
+		return fmt.Errorf("restore from history takes a time (@<time>) or a number of changes (@-N)")
+	}
+
+	path, at, err := resolvePointInTime(path)
+	if err != nil {
+		return err
+	}
+	if at.IsZero() {
+		return fmt.Errorf("specify a point in time with %s@<time>, %s@-N or --at, or pass a snapshot file", path, path)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	recordProvenance(ctx, client, cfg)
+
+	snapshot, err := client.CreateSnapshotAt(ctx, path, at)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring %s to its state at %s\n\n", path, at.Local().Format("2006-01-02 15:04:05"))
+	return applyRestore(ctx, client, snapshot, path)
+}
+
+func restoreChangesAgo(ctx context.Context, path string, changesAgo int) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	recordProvenance(ctx, client, cfg)
+
+	snapshot, err := client.CreateSnapshotAtChangesAgo(ctx, path, changesAgo)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restoring %s to its state %d change(s) ago\n\n", path, changesAgo)
+	return applyRestore(ctx, client, snapshot, path)
+}
+
+// applyRestore restores a snapshot to the target path and prints the result
+func applyRestore(ctx context.Context, client *vault.Client, snapshot *vault.Snapshot, targetPath string) error {
+	policy, err := vault.ParseConflictPolicy(restoreOnConflict)
+	if err != nil {
+		return err
+	}
+	if restoreVerify {
+		if policy != vault.ConflictOverwrite && policy != vault.ConflictSkip {
+			return fmt.Errorf("--verify and --on-conflict %s are mutually exclusive", policy)
+		}
+		policy = vault.ConflictSkip
+	}
 
 	opts := vault.RestoreOptions{
 		DryRun:      restoreDryRun,
-		Verify:      restoreVerify,
+		OnConflict:  policy,
 		DeleteExtra: !restoreNoDelete,
+		LatestOnly:  restoreLatestOnly,
+		KeepPartial: restoreKeepPartial,
+		Selection: vault.SnapshotSelection{
+			Include: restoreInclude,
+			Exclude: restoreExclude,
+		},
+	}
+	for _, m := range restoreMap {
+		mapping, err := vault.ParsePathMapping(m)
+		if err != nil {
+			return err
+		}
+		opts.Selection.Map = append(opts.Selection.Map, mapping)
+	}
+	if err := opts.Selection.Validate(); err != nil {
+		return err
+	}
+
+	// Interrupting stops the restore and rolls it back, rather than killing it halfway
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if policy == vault.ConflictPrompt {
+		opts.Prompt = newConflictPrompt(ctx, os.Stdin)
 	}
 
 	result, err := client.RestoreSnapshot(ctx, snapshot, targetPath, opts)
+	var restoreErr *vault.RestoreError
+	var conflictErr *vault.ConflictError
+	switch {
+	case errors.As(err, &restoreErr):
+		printRestoreFailure(restoreErr)
+		printTrashHint(client)
+	case errors.As(err, &conflictErr):
+		printConflicts(conflictErr.Conflicts)
+	}
 	if err != nil {
 		return err
 	}
 
 	// Print results
 	printRestoreResult(result, restoreDryRun)
+	printTrashHint(client)
 
 	return nil
 }
 
+// printRestoreFailure prints what a failed restore changed and whether it was rolled back
+func printRestoreFailure(e *vault.RestoreError) {
+	switch {
+	case e.RolledBack:
+		fmt.Printf("Restore failed, %d change(s) rolled back to the pre-restore state.\n\n", len(e.Applied))
+		return
+	case e.RollbackErr != nil:
+		fmt.Printf("Restore failed and could not be fully rolled back. Changes applied:\n\n")
+	default:
+		fmt.Printf("Restore failed, changes applied before the failure were kept:\n\n")
+	}
+
+	symbols := map[vault.RestoreAction]string{
+		vault.RestoreAdded:   "+",
+		vault.RestoreUpdated: "~",
+		vault.RestoreDeleted: "-",
+	}
+	for _, change := range e.Applied {
+		fmt.Printf("  %s %s\n", symbols[change.Action], change.Path)
+	}
+	if e.Failed != nil {
+		fmt.Printf("  ? %s (%s, may be partially applied)\n", e.Failed.Path, e.Failed.Action)
+	}
+	fmt.Println()
+}
+
 func printRestoreResult(result *vault.RestoreResult, dryRun bool) {
 	action := ""
 	if dryRun {
@@ -115,11 +345,23 @@ func printRestoreResult(result *vault.RestoreResult, dryRun bool) {
 		fmt.Println()
 	}
 
-	if len(result.Skipped) > 0 {
-		fmt.Printf("Skipped (version mismatch) (%d):\n", len(result.Skipped))
-		sort.Strings(result.Skipped)
+	if len(result.Conflicts) > 0 {
+		outcomes := make(map[string]string)
+		for _, p := range result.Updated {
+			outcomes[p] = "overwritten"
+		}
 		for _, p := range result.Skipped {
-			fmt.Printf("  ! %s\n", p)
+			outcomes[p] = "kept"
+		}
+		fmt.Printf("Modified since the snapshot (%d):\n", len(result.Conflicts))
+		sort.Strings(result.Conflicts)
+		for _, p := range result.Conflicts {
+			outcome, ok := outcomes[p]
+			if !ok {
+				// Dry runs don't prompt
+				outcome = "will ask"
+			}
+			fmt.Printf("  ! %s (%s)\n", p, outcome)
 		}
 		fmt.Println()
 	}
@@ -136,3 +378,136 @@ func printRestoreResult(result *vault.RestoreResult, dryRun bool) {
 		fmt.Println("\nRun without --dry-run to apply these changes.")
 	}
 }
+
+// newConflictPrompt returns a RestoreOptions.Prompt that shows a diff of each conflicting
+// secret and asks whether to restore it, reading answers from in. Interrupting a prompt
+// aborts the restore.
+func newConflictPrompt(ctx context.Context, in io.Reader) func(vault.RestoreConflict) (bool, error) {
+	type line struct {
+		text string
+		err  error
+	}
+	lines := make(chan line)
+	var startReading sync.Once
+	readLines := func() {
+		reader := bufio.NewReader(in)
+		for {
+			text, err := reader.ReadString('\n')
+			lines <- line{text, err}
+			if err != nil {
+				return
+			}
+		}
+	}
+
+	var all, none bool
+	return func(conflict vault.RestoreConflict) (bool, error) {
+		switch {
+		case all:
+			return true, nil
+		case none:
+			return false, nil
+		}
+
+		printConflictDiff(conflict)
+		for {
+			fmt.Print("Restore the snapshot value? [y]es, [n]o, [a]ll remaining, [s]kip all remaining, [q]uit: ")
+			startReading.Do(func() { go readLines() })
+			var answer line
+			select {
+			case <-ctx.Done():
+				fmt.Println()
+				return false, errRestoreAborted
+			case answer = <-lines:
+			}
+			if answer.err != nil && answer.text == "" {
+				return false, fmt.Errorf("no answer to the conflict prompt: %w", answer.err)
+			}
+			fmt.Println()
+
+			switch strings.ToLower(strings.TrimSpace(answer.text)) {
+			case "y", "yes":
+				return true, nil
+			case "n", "no":
+				return false, nil
+			case "a", "all":
+				all = true
+				return true, nil
+			case "s", "skip":
+				none = true
+				return false, nil
+			case "q", "quit":
+				return false, errRestoreAborted
+			}
+		}
+	}
+}
+
+// printConflictDiff shows how a conflicting secret in Vault differs from the snapshot
+func printConflictDiff(conflict vault.RestoreConflict) {
+	fmt.Printf("Conflict: %s\n", conflict.Path)
+	snapshotNewer := conflict.SnapshotNewer()
+	fmt.Printf("  Snapshot: %s%s\n", describeVersion(conflict.Snapshot.ExpectedVersion(), conflict.Snapshot.Updated), newerMark(snapshotNewer))
+	fmt.Printf("  Vault:    %s%s\n", describeVersion(conflict.CurrentVersion, conflict.CurrentUpdated), newerMark(!snapshotNewer))
+	fmt.Println()
+
+	result := vault.CompareSecrets(vault.Flatten(conflict.Current), vault.Flatten(conflict.Snapshot.SecretData()))
+	if !result.HasDifferences() {
+		fmt.Println("  Values are identical, settings or custom metadata differ")
+	}
+	for _, entry := range result.OnlyInFirst {
+		fmt.Printf("  - %s%s (removed)\n", entry.Key, conflictValue(entry.Value))
+	}
+	for _, entry := range result.OnlyInSecond {
+		fmt.Printf("  + %s%s (added)\n", entry.Key, conflictValue(entry.Value))
+	}
+	for _, entry := range result.Changed {
+		if restoreShowValues {
+			fmt.Printf("  ~ %s:\n", entry.Key)
+			fmt.Printf("      - %s\n", truncateValue(entry.FirstValue))
+			fmt.Printf("      + %s\n", truncateValue(entry.SecondValue))
+		} else {
+			fmt.Printf("  ~ %s (%d → %d chars)\n", entry.Key, entry.FirstLen, entry.SecondLen)
+		}
+	}
+	fmt.Println()
+}
+
+// conflictValue formats a value in a conflict diff, hidden unless --show-values is set
+func conflictValue(value string) string {
+	if !restoreShowValues {
+		return ""
+	}
+	return ": " + truncateValue(value)
+}
+
+// describeVersion describes a version of a secret, which may be unknown for snapshots
+// in other formats
+func describeVersion(version int, updated time.Time) string {
+	desc := "version unknown"
+	if version > 0 {
+		desc = fmt.Sprintf("version %d", version)
+	}
+	if !updated.IsZero() {
+		desc += ", updated " + updated.Local().Format("2006-01-02 15:04:05")
+	}
+	return desc
+}
+
+func newerMark(newer bool) string {
+	if newer {
+		return " (newer)"
+	}
+	return ""
+}
+
+// printConflicts lists the conflicts that made a restore with --on-conflict fail
+func printConflicts(conflicts []vault.RestoreConflict) {
+	fmt.Printf("Restore refused, %d secret(s) were modified since the snapshot was taken:\n\n", len(conflicts))
+	for _, conflict := range conflicts {
+		fmt.Printf("  ! %s (snapshot %s; Vault %s)\n", conflict.Path,
+			describeVersion(conflict.Snapshot.ExpectedVersion(), conflict.Snapshot.Updated),
+			describeVersion(conflict.CurrentVersion, conflict.CurrentUpdated))
+	}
+	fmt.Println()
+}
@@ -24,7 +24,10 @@ Example:
   # Deletes the secret at secret/myapp/config
 
   vlt rm secret/myapp -r
-  # Deletes all secrets under secret/myapp`,
+  # Deletes all secrets under secret/myapp
+
+If VLT_TRASH_PATH is set, deleted secrets are moved to the trash
+with their version history (see 'vlt trash').`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runRm(cmd.Context(), args[0])
@@ -58,6 +61,7 @@ func runRm(ctx context.Context, path string) error {
 			return err
 		}
 		fmt.Printf("Deleted %s\n", path)
+		printTrashHint(client)
 		return nil
 	}
 
@@ -84,5 +88,6 @@ func runRm(ctx context.Context, path string) error {
 	for _, deleted := range result.Deleted {
 		fmt.Printf("Deleted %s\n", deleted)
 	}
+	printTrashHint(client)
 	return nil
 }
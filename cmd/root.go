@@ -1,15 +1,52 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 )
 
+// globalAt is the point in time read commands operate at (--at), empty for the current state
+var globalAt string
+
+// pointInTimeAnnotation marks commands that honour --at
+const pointInTimeAnnotation = "vlt/point-in-time"
+
+// fromSnapshot is a snapshot file read commands serve secrets from (--from-snapshot),
+// empty to read from Vault
+var fromSnapshot string
+
+// offlineAnnotation marks commands that can read from a snapshot with --from-snapshot
+const offlineAnnotation = "vlt/offline"
+
+// commandPath is the running command (e.g. "vlt update"), recorded as write provenance
+var commandPath string
+
 var rootCmd = &cobra.Command{
 	Use:   "vlt",
 	Short: "vlt CLI tool",
 	Long:  `vlt is a command line tool for managing secrets and configuration.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		commandPath = cmd.CommandPath()
+		if globalAt != "" && cmd.Annotations[pointInTimeAnnotation] == "" {
+			return fmt.Errorf("--at is not supported by '%s'", cmd.CommandPath())
+		}
+		if fromSnapshot != "" && cmd.Annotations[offlineAnnotation] == "" {
+			return fmt.Errorf("--from-snapshot is not supported by '%s'", cmd.CommandPath())
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&globalAt, "at", "", "read secrets as they were at this time (e.g. 2024-01-30T14:00:00Z, \"2h ago\")")
+	rootCmd.PersistentFlags().StringVar(&fromSnapshot, "from-snapshot", "", "read secrets from a snapshot file instead of Vault")
+}
+
+// SetVersion sets the vlt version, shown by --version and recorded in snapshot manifests
+func SetVersion(version string) {
+	rootCmd.Version = version
 }
 
 func Execute() {
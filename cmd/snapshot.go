@@ -1,18 +1,40 @@
 package cmd
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
 )
 
 var (
-	snapshotOutput string
+	snapshotOutput          string
+	snapshotEncrypt         bool
+	snapshotAge             []string
+	snapshotPGP             []string
+	snapshotKMS             []string
+	snapshotAllowPlaintext  bool
+	snapshotAllVersions     bool
+	snapshotGzip            bool
+	snapshotSignKey         string
+	snapshotIncrementalFrom string
+	snapshotFormat          string
+	snapshotRetain          string
+	snapshotRetentionMode   string
+
+	snapshotVerifyAllowPlaintext bool
+	snapshotVerifyQuiet          bool
+	snapshotVerifyKey            string
 )
 
 var snapshotCmd = &cobra.Command{
@@ -23,22 +45,179 @@ var snapshotCmd = &cobra.Command{
 The snapshot includes secret values, version numbers, and timestamps.
 Use 'vlt restore' to restore secrets from a snapshot.
 
+Snapshots contain every secret value, so they must be encrypted with SOPS:
+use --encrypt with one or more --age, --pgp or --kms keys. Anyone holding
+one of the keys can restore the snapshot; decryption uses the usual SOPS
+key sources (SOPS_AGE_KEY_FILE, gpg-agent, AWS credentials).
+Writing a plaintext snapshot requires --allow-plaintext.
+
+With --all-versions, every version that was not destroyed is captured with
+its creation time and deletion state. Restoring such a snapshot to a path
+where a secret does not exist replays its versions in order, so the history
+of the secret is kept.
+
+Every snapshot carries a manifest with the SHA-256 digest of each secret,
+the number of secrets and versions, and where it was taken from. Restore
+checks it before touching Vault, so truncated or edited snapshots are
+refused. Use --sign-key to sign the manifest with an ed25519 key (PEM,
+e.g. from 'openssl genpkey -algorithm ed25519') and --verify-key on
+restore to require that signature. --gzip compresses the file.
+
+With --incremental-from, only secrets whose version changed since a base
+snapshot of the same path are read, and removed secrets are recorded. The
+incremental snapshot references its base file (which may itself be
+incremental); restore follows the chain back to the full snapshot, so all
+files of a chain must be kept together.
+
+--format medusa or --format vault-json writes the current secrets in a
+format other tools read: a medusa export tree, or a JSON object mapping
+each secret path to its 'vault kv get -format=json' output. These hold
+no version history, settings or manifest and are written in plaintext
+(with --allow-plaintext). 'vlt restore' detects them automatically.
+--format only takes these backup formats (vlt, medusa, vault-json); to
+write secrets as yaml, json, dotenv, properties, toml or hcl, use
+'vlt get' or 'vlt export' with --format.
+
+-o also accepts s3://bucket/key URLs, as does every command that reads a
+snapshot file. Credentials come from the usual AWS sources; set
+VLT_S3_ENDPOINT for MinIO, Ceph and other S3-compatible stores, and
+VLT_S3_SSE (AES256 or aws:kms, with VLT_S3_SSE_KMS_KEY_ID) for server-side
+encryption. --retain locks the object against deletion for a duration,
+in a bucket with object lock enabled. Use 'vlt snapshot ls' to list the
+snapshots under a prefix.
+
 Examples:
-  vlt snapshot secret/myapp -o backup.yaml
-  vlt snapshot secret/myapp -o backup-$(date +%Y%m%d).yaml`,
+  vlt snapshot secret/myapp -o backup.enc.yaml --encrypt --age age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+  vlt snapshot secret/myapp -o backup.enc.yaml --encrypt --kms arn:aws:kms:us-east-1:111122223333:key/1234abcd
+  vlt snapshot secret/myapp -o backup-$(date +%Y%m%d).enc.yaml --encrypt --pgp 85D77543B3D624B63CEA9E6DBC17301B491B3F21
+  vlt snapshot secret/myapp --at "2024-01-30 14:00" -o before-incident.enc.yaml --encrypt --age age1...
+  vlt snapshot secret/myapp -o migrate.enc.yaml --all-versions --encrypt --age age1...
+  vlt snapshot secret/myapp -o backup.enc.yaml.gz --encrypt --age age1... --gzip --sign-key backup-signing.pem
+  vlt snapshot secret/myapp -o mon.enc.yaml --incremental-from sun.enc.yaml --encrypt --age age1...
+  vlt snapshot secret/myapp -o backup.yaml --allow-plaintext
+  vlt snapshot secret/myapp -o backup.json --format vault-json --allow-plaintext
+  vlt snapshot secret/myapp -o s3://backups/myapp/$(date +%Y%m%d).enc.yaml --encrypt --age age1... --retain 30d`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runSnapshot(cmd.Context(), args[0])
 	},
+	Annotations: map[string]string{pointInTimeAnnotation: "true"},
+}
+
+var snapshotVerifyCmd = &cobra.Command{
+	Use:   "verify <file> [path]",
+	Short: "Check whether Vault has diverged from a snapshot",
+	Long: `Compare a snapshot with the live secrets in Vault.
+
+The snapshot is first checked against its manifest (and signature, with
+--verify-key). Secrets are then compared at the path the snapshot was
+taken from, or at the given path. Reports secrets whose values differ and
+secrets whose version changed since the snapshot was taken, even if the
+value is the same.
+
+Exits with status 0 if Vault matches the snapshot, and 1 if it has
+diverged or an error occurred.
+
+Examples:
+  vlt snapshot verify backup.enc.yaml
+  vlt snapshot verify backup.enc.yaml secret/myapp-restored
+  vlt snapshot verify backup.enc.yaml --quiet || alert "secrets changed"
+  vlt snapshot verify backup.enc.yaml.gz --verify-key backup-signing.pub`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := ""
+		if len(args) == 2 {
+			path = args[1]
+		}
+		return runSnapshotVerify(cmd.Context(), args[0], path)
+	},
+}
+
+var snapshotLsCmd = &cobra.Command{
+	Use:   "ls <s3://bucket/prefix>",
+	Short: "List snapshots stored in object storage",
+	Long: `List the snapshots under an S3 prefix, oldest first.
+
+The path, creation time and content of each snapshot are read from the
+metadata vlt stores with the object, so nothing is downloaded or decrypted.
+Objects not written by vlt are listed without details.
+
+Examples:
+  vlt snapshot ls s3://backups/myapp/
+  vlt snapshot ls s3://backups/myapp/2024-`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSnapshotLs(cmd.Context(), args[0])
+	},
 }
 
 func init() {
+	snapshotCmd.AddCommand(snapshotLsCmd)
+
+	snapshotVerifyCmd.Flags().BoolVar(&snapshotVerifyAllowPlaintext, "allow-plaintext", false, "load an unencrypted snapshot file")
+	snapshotVerifyCmd.Flags().BoolVarP(&snapshotVerifyQuiet, "quiet", "q", false, "exit code only, no output")
+	snapshotVerifyCmd.Flags().StringVar(&snapshotVerifyKey, "verify-key", "", "require a valid signature by this ed25519 public key (PEM file)")
+	snapshotCmd.AddCommand(snapshotVerifyCmd)
+
 	snapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "output file path (required)")
 	_ = snapshotCmd.MarkFlagRequired("output")
+	snapshotCmd.Flags().BoolVar(&snapshotEncrypt, "encrypt", false, "encrypt the snapshot with SOPS")
+	snapshotCmd.Flags().StringSliceVar(&snapshotAge, "age", nil, "age recipient to encrypt for (repeatable)")
+	snapshotCmd.Flags().StringSliceVar(&snapshotPGP, "pgp", nil, "PGP fingerprint to encrypt for (repeatable)")
+	snapshotCmd.Flags().StringSliceVar(&snapshotKMS, "kms", nil, "AWS KMS key ARN to encrypt for (repeatable)")
+	snapshotCmd.Flags().BoolVar(&snapshotAllowPlaintext, "allow-plaintext", false, "write an unencrypted snapshot")
+	snapshotCmd.Flags().BoolVar(&snapshotAllVersions, "all-versions", false, "capture every version, not only the current one")
+	snapshotCmd.Flags().BoolVar(&snapshotGzip, "gzip", false, "compress the snapshot file")
+	snapshotCmd.Flags().StringVar(&snapshotSignKey, "sign-key", "", "sign the manifest with this ed25519 private key (PEM file)")
+	snapshotCmd.Flags().StringVar(&snapshotIncrementalFrom, "incremental-from", "", "only capture changes since this base snapshot file")
+	snapshotCmd.Flags().StringVar(&snapshotFormat, "format", string(vault.BackupFormatVlt), "backup file format: vlt, medusa or vault-json (see get and export for yaml, json, dotenv and others)")
+	snapshotCmd.Flags().StringVar(&snapshotRetain, "retain", "", "lock the S3 object against deletion for this long (e.g. 30d)")
+	snapshotCmd.Flags().StringVar(&snapshotRetentionMode, "retention-mode", "governance", "object lock mode for --retain: governance or compliance")
 	rootCmd.AddCommand(snapshotCmd)
 }
 
 func runSnapshot(ctx context.Context, path string) error {
+	format, err := vault.ParseBackupFormat(snapshotFormat)
+	if err != nil {
+		return err
+	}
+	if format != vault.BackupFormatVlt {
+		switch {
+		case snapshotEncrypt, snapshotSignKey != "":
+			return fmt.Errorf("--encrypt and --sign-key are only supported by the vlt format")
+		case snapshotAllVersions, snapshotIncrementalFrom != "":
+			return fmt.Errorf("%s files only hold current values, --all-versions and --incremental-from need the vlt format", format)
+		}
+	}
+
+	enc, err := snapshotEncryption()
+	if err != nil {
+		return err
+	}
+
+	var retainUntil time.Time
+	if snapshotRetain != "" {
+		if !vault.IsS3URL(snapshotOutput) {
+			return fmt.Errorf("--retain requires an s3:// output")
+		}
+		d, err := vault.ParseDuration(snapshotRetain)
+		if err != nil {
+			return fmt.Errorf("invalid --retain: %w", err)
+		}
+		retainUntil = time.Now().Add(d)
+	}
+
+	var signingKey ed25519.PrivateKey
+	if snapshotSignKey != "" {
+		keyData, err := os.ReadFile(snapshotSignKey)
+		if err != nil {
+			return fmt.Errorf("failed to read signing key: %w", err)
+		}
+		if signingKey, err = vault.ParseSigningKey(keyData); err != nil {
+			return err
+		}
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		return err
@@ -49,42 +228,477 @@ func runSnapshot(ctx context.Context, path string) error {
 		return err
 	}
 
+	path, at, err := resolvePointInTime(path)
+	if err != nil {
+		return err
+	}
+
+	if (snapshotAllVersions || snapshotIncrementalFrom != "") && !at.IsZero() {
+		return fmt.Errorf("--all-versions and --incremental-from cannot be combined with a point in time")
+	}
+
 	// Create snapshot
-	snapshot, err := client.CreateSnapshot(ctx, path)
+	var snapshot *vault.Snapshot
+	opts := vault.SnapshotOptions{AllVersions: snapshotAllVersions}
+	switch {
+	case snapshotIncrementalFrom != "":
+		snapshot, err = createIncrementalSnapshot(ctx, client, path, opts)
+	case at.IsZero():
+		snapshot, err = client.CreateSnapshotWithOptions(ctx, path, opts)
+	default:
+		snapshot, err = client.CreateSnapshotAt(ctx, path, at)
+	}
 	if err != nil {
 		return err
 	}
 
-	// Marshal to YAML
-	data, err := yaml.Marshal(snapshot)
+	// Marshal to YAML, encrypted unless plaintext was allowed
+	var data []byte
+	if format == vault.BackupFormatVlt {
+		data, err = vault.MarshalSnapshot(snapshot, vault.SnapshotFileOptions{
+			Encryption: enc,
+			Gzip:       snapshotGzip,
+			SigningKey: signingKey,
+			VltVersion: rootCmd.Version,
+		})
+	} else {
+		data, err = marshalBackup(snapshot, format)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to marshal snapshot: %w", err)
+		return err
 	}
 
-	// Write to file
-	if err := os.WriteFile(snapshotOutput, data, 0600); err != nil {
-		return fmt.Errorf("failed to write snapshot file: %w", err)
+	// Write to file or object storage
+	if err := writeSnapshotData(ctx, snapshotOutput, data, vault.S3PutOptions{
+		Info:          vault.NewSnapshotObjectInfo(snapshot, format, enc != nil, signingKey != nil, rootCmd.Version),
+		RetainUntil:   retainUntil,
+		RetentionMode: snapshotRetentionMode,
+	}); err != nil {
+		return err
 	}
 
 	fmt.Printf("Snapshot created: %s\n", snapshotOutput)
 	fmt.Printf("  Path: %s\n", snapshot.Path)
-	fmt.Printf("  Secrets: %d\n", len(snapshot.Secrets))
+	if format != vault.BackupFormatVlt {
+		fmt.Printf("  Format: %s\n", format)
+	}
+	if snapshot.Base != nil {
+		fmt.Printf("  Incremental from: %s\n", snapshotIncrementalFrom)
+		fmt.Printf("  Changed: %d\n", len(snapshot.Secrets))
+		fmt.Printf("  Removed: %d\n", len(snapshot.Removed))
+	} else {
+		fmt.Printf("  Secrets: %d\n", len(snapshot.Secrets))
+	}
+	if snapshotAllVersions {
+		versions := 0
+		for _, secret := range snapshot.Secrets {
+			versions += len(secret.Versions)
+		}
+		fmt.Printf("  Versions: %d\n", versions)
+	}
 	fmt.Printf("  Created: %s\n", snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if !snapshot.At.IsZero() {
+		fmt.Printf("  As of: %s\n", snapshot.At.Local().Format("2006-01-02 15:04:05"))
+	}
+	if enc == nil {
+		fmt.Println("  Encrypted: no")
+	} else {
+		fmt.Println("  Encrypted: yes (SOPS)")
+	}
+	if signingKey != nil {
+		fmt.Println("  Signed: yes")
+	}
+	if !retainUntil.IsZero() {
+		fmt.Printf("  Retained until: %s\n", retainUntil.Local().Format("2006-01-02 15:04:05"))
+	}
 
 	return nil
 }
 
-// LoadSnapshot loads a snapshot from a YAML file
-func LoadSnapshot(path string) (*vault.Snapshot, error) {
-	data, err := os.ReadFile(path)
+// marshalBackup writes a snapshot in another tool's format, compressed with --gzip
+func marshalBackup(snapshot *vault.Snapshot, format vault.BackupFormat) ([]byte, error) {
+	data, err := vault.MarshalBackup(snapshot, format)
+	if err != nil || !snapshotGzip {
+		return data, err
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// createIncrementalSnapshot snapshots the secrets that changed since the --incremental-from
+// base, and references the base file from the snapshot
+func createIncrementalSnapshot(ctx context.Context, client *vault.Client, path string, opts vault.SnapshotOptions) (*vault.Snapshot, error) {
+	baseData, err := readSnapshotData(ctx, snapshotIncrementalFrom)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base snapshot: %w", err)
+	}
+
+	// The base only has to be readable here; restore applies the usual checks
+	base, err := LoadSnapshot(ctx, snapshotIncrementalFrom, true, "")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := client.CreateIncrementalSnapshot(ctx, path, base, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reference the base relative to the new snapshot, so the chain can be moved as a whole
+	snapshot.Base.File = relativeSnapshotRef(snapshotIncrementalFrom, snapshotOutput)
+	snapshot.Base.Digest = vault.Digest(baseData)
+
+	return snapshot, nil
+}
+
+func runSnapshotVerify(ctx context.Context, file, path string) error {
+	snapshot, err := LoadSnapshot(ctx, file, snapshotVerifyAllowPlaintext, snapshotVerifyKey)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+		return err
+	}
+	if path == "" {
+		if snapshot.Path == "" {
+			return fmt.Errorf("%s does not record the path it was taken from, pass the path to compare with", file)
+		}
+		path = snapshot.Path
 	}
 
-	var snapshot vault.Snapshot
-	if err := yaml.Unmarshal(data, &snapshot); err != nil {
-		return nil, fmt.Errorf("failed to parse snapshot file: %w", err)
+	cfg, err := config.Load()
+	if err != nil {
+		return err
 	}
 
-	return &snapshot, nil
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	live, err := client.Get(ctx, path)
+	if err != nil {
+		return err
+	}
+	versions, err := client.CurrentVersions(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	result := vault.CompareSecrets(vault.Flatten(snapshot.Data()), vault.Flatten(live))
+	result.Drift = vault.CompareSecretVersions(snapshot.SecretVersions(), versions)
+	diverged := result.HasDifferences() || len(result.Drift) > 0
+
+	if !snapshotVerifyQuiet {
+		printManifestStatus(snapshot, snapshotVerifyKey != "")
+		printDiffResult(file, path, result)
+		fmt.Println()
+		if diverged {
+			fmt.Printf("%s has diverged from the snapshot taken %s\n", path, snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			fmt.Printf("%s matches the snapshot taken %s\n", path, snapshot.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+		}
+	}
+
+	if diverged {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// snapshotEncryption returns the SOPS keys to encrypt the snapshot for,
+// or nil if a plaintext snapshot was explicitly allowed
+func snapshotEncryption() (*vault.SnapshotEncryption, error) {
+	enc := &vault.SnapshotEncryption{
+		Age: snapshotAge,
+		PGP: snapshotPGP,
+		KMS: snapshotKMS,
+	}
+
+	switch {
+	case snapshotEncrypt && snapshotAllowPlaintext:
+		return nil, fmt.Errorf("--encrypt and --allow-plaintext are mutually exclusive")
+	case snapshotEncrypt && enc.IsEmpty():
+		return nil, fmt.Errorf("--encrypt requires at least one key (--age, --pgp or --kms)")
+	case snapshotEncrypt:
+		return enc, nil
+	case !enc.IsEmpty():
+		return nil, fmt.Errorf("--age, --pgp and --kms require --encrypt")
+	case !snapshotAllowPlaintext:
+		return nil, fmt.Errorf("refusing to write secrets unencrypted: use --encrypt with --age, --pgp or --kms, or --allow-plaintext")
+	}
+	return nil, nil
+}
+
+// maxSnapshotChain limits how many incremental snapshots LoadSnapshot follows
+const maxSnapshotChain = 100
+
+// LoadSnapshot loads a snapshot file, decompressing and decrypting it as needed, and
+// checks it against its manifest. Plaintext snapshots are refused unless allowPlaintext
+// is set. If verifyKeyFile is set, the snapshot must be signed by that public key.
+// Incremental snapshots are resolved to their full state through their chain of bases,
+// each of which is checked the same way. path may be an s3:// URL.
+func LoadSnapshot(ctx context.Context, path string, allowPlaintext bool, verifyKeyFile string) (*vault.Snapshot, error) {
+	opts := vault.SnapshotLoadOptions{AllowPlaintext: allowPlaintext}
+	if verifyKeyFile != "" {
+		keyData, err := os.ReadFile(verifyKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read verification key: %w", err)
+		}
+		if opts.VerifyKey, err = vault.ParseVerifyKey(keyData); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := readSnapshotData(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []*vault.Snapshot
+	for {
+		snapshot, err := loadSnapshotFile(path, data, opts)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, snapshot)
+
+		if snapshot.Base == nil {
+			break
+		}
+		if len(chain) > maxSnapshotChain {
+			return nil, fmt.Errorf("%s: more than %d incremental snapshots in the chain", path, maxSnapshotChain)
+		}
+
+		basePath, err := resolveSnapshotRef(path, snapshot.Base.File)
+		if err != nil {
+			return nil, err
+		}
+		if data, err = readSnapshotData(ctx, basePath); err != nil {
+			return nil, fmt.Errorf("%s: failed to read base snapshot: %w", path, err)
+		}
+		if vault.Digest(data) != snapshot.Base.Digest {
+			return nil, fmt.Errorf("%s: base snapshot %s has changed since the incremental snapshot was taken", path, basePath)
+		}
+		path = basePath
+	}
+
+	// Apply the incremental snapshots to the full base, oldest first
+	snapshot := chain[len(chain)-1]
+	for i := len(chain) - 2; i >= 0; i-- {
+		snapshot = vault.ApplyIncremental(snapshot, chain[i])
+	}
+
+	return snapshot, nil
+}
+
+// loadSnapshotFile decodes and checks a single snapshot file
+func loadSnapshotFile(path string, data []byte, opts vault.SnapshotLoadOptions) (*vault.Snapshot, error) {
+	snapshot, err := vault.UnmarshalSnapshot(data, opts)
+	if errors.Is(err, vault.ErrPlaintextSnapshot) {
+		return nil, fmt.Errorf("%s: %w (use --allow-plaintext to load it anyway)", path, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if snapshot.Manifest == nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s has no manifest, its integrity cannot be checked\n", path)
+	}
+
+	return snapshot, nil
+}
+
+// printManifestStatus describes the integrity checks a loaded snapshot passed
+func printManifestStatus(snapshot *vault.Snapshot, signatureVerified bool) {
+	m := snapshot.Manifest
+	if m == nil {
+		return
+	}
+
+	signature := "not signed"
+	switch {
+	case signatureVerified:
+		signature = "signature verified"
+	case m.Signature != "":
+		signature = "signed, signature not checked (use --verify-key)"
+	}
+	fmt.Printf("Manifest: ok (%d secrets, %s)\n", m.Secrets, signature)
+	if merged := len(snapshot.Secrets); merged != m.Secrets {
+		// An incremental snapshot's manifest covers the secrets it holds itself
+		fmt.Printf("  Merged with its base snapshots: %d secrets\n", merged)
+	}
+	if m.Cluster != "" {
+		fmt.Printf("  Taken from: %s (mount %s)", m.Cluster, m.Mount)
+		if m.VltVersion != "" {
+			fmt.Printf(" by vlt %s", m.VltVersion)
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+func runSnapshotLs(ctx context.Context, prefix string) error {
+	loc, err := vault.ParseS3URL(prefix)
+	if err != nil {
+		return err
+	}
+	store, err := newS3Store(ctx)
+	if err != nil {
+		return err
+	}
+
+	objects, err := store.List(ctx, loc)
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		fmt.Printf("No snapshots under %s\n", loc)
+		return nil
+	}
+
+	for _, obj := range objects {
+		info := obj.Info
+		if info == nil {
+			fmt.Printf("%-19s  %-30s  %s\n", obj.LastModified.Local().Format("2006-01-02 15:04:05"), "(not written by vlt)", obj.Location)
+			continue
+		}
+
+		var flags []string
+		if info.Format != vault.BackupFormatVlt {
+			flags = append(flags, string(info.Format))
+		}
+		if info.Incremental {
+			flags = append(flags, "incremental")
+		}
+		if info.Encrypted {
+			flags = append(flags, "encrypted")
+		}
+		if info.Signed {
+			flags = append(flags, "signed")
+		}
+		content := fmt.Sprintf("%d secrets", info.Secrets)
+		if info.Versions > 0 {
+			content += fmt.Sprintf(", %d versions", info.Versions)
+		}
+		if len(flags) > 0 {
+			content += " (" + strings.Join(flags, ", ") + ")"
+		}
+
+		fmt.Printf("%-19s  %-30s  %s\n", info.CreatedAt.Local().Format("2006-01-02 15:04:05"), info.Path, obj.Location)
+		fmt.Printf("%-19s  %s", "", content)
+		if info.Cluster != "" {
+			fmt.Printf(", from %s", info.Cluster)
+		}
+		fmt.Println()
+	}
+
+	return nil
+}
+
+// newS3Store connects to object storage as configured by VLT_S3_* variables
+func newS3Store(ctx context.Context) (*vault.S3Store, error) {
+	cfg, err := config.LoadS3()
+	if err != nil {
+		return nil, err
+	}
+	return vault.NewS3Store(ctx, cfg)
+}
+
+// readSnapshotData reads a snapshot file from a local path or an s3:// URL
+func readSnapshotData(ctx context.Context, location string) ([]byte, error) {
+	if !vault.IsS3URL(location) {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read snapshot file: %w", err)
+		}
+		return data, nil
+	}
+
+	loc, err := vault.ParseS3URL(location)
+	if err != nil {
+		return nil, err
+	}
+	store, err := newS3Store(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return store.Get(ctx, loc)
+}
+
+// writeSnapshotData writes a snapshot file to a local path or an s3:// URL. opts only
+// apply to S3.
+func writeSnapshotData(ctx context.Context, location string, data []byte, opts vault.S3PutOptions) error {
+	if !vault.IsS3URL(location) {
+		if err := os.WriteFile(location, data, 0600); err != nil {
+			return fmt.Errorf("failed to write snapshot file: %w", err)
+		}
+		return nil
+	}
+
+	loc, err := vault.ParseS3URL(location)
+	if err != nil {
+		return err
+	}
+	store, err := newS3Store(ctx)
+	if err != nil {
+		return err
+	}
+	return store.Put(ctx, loc, data, opts)
+}
+
+// resolveSnapshotRef resolves a snapshot reference found in the snapshot at from,
+// such as the base of an incremental snapshot, which is relative to it unless absolute
+func resolveSnapshotRef(from, ref string) (string, error) {
+	if vault.IsS3URL(from) {
+		loc, err := vault.ParseS3URL(from)
+		if err != nil {
+			return "", err
+		}
+		resolved, err := loc.Resolve(ref)
+		if err != nil {
+			return "", err
+		}
+		return resolved.String(), nil
+	}
+
+	if vault.IsS3URL(ref) || filepath.IsAbs(ref) {
+		return ref, nil
+	}
+	return filepath.Join(filepath.Dir(from), ref), nil
+}
+
+// relativeSnapshotRef returns how the snapshot at from refers to the one at target:
+// relative to it when both are local or in the same bucket, absolute otherwise
+func relativeSnapshotRef(target, from string) string {
+	switch {
+	case vault.IsS3URL(target) && vault.IsS3URL(from):
+		targetLoc, err1 := vault.ParseS3URL(target)
+		fromLoc, err2 := vault.ParseS3URL(from)
+		if err1 != nil || err2 != nil {
+			return target
+		}
+		return targetLoc.RelativeTo(fromLoc)
+	case vault.IsS3URL(target):
+		return target
+	}
+
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return target
+	}
+	if vault.IsS3URL(from) {
+		return abs
+	}
+	if fromDir, err := filepath.Abs(filepath.Dir(from)); err == nil {
+		if rel, err := filepath.Rel(fromDir, abs); err == nil {
+			return rel
+		}
+	}
+	return target
 }
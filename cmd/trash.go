@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethanadams/vlt/pkg/config"
+	"github.com/ethanadams/vlt/pkg/vault"
+	"github.com/spf13/cobra"
+)
+
+var trashPurgeOlderThan string
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manage deleted secrets in the trash",
+	Long: `Manage deleted secrets in the trash.
+
+When VLT_TRASH_PATH is set (e.g. secret/.vlt-trash), every secret that vlt
+deletes - with rm, mv, edit or restore - is first moved to the trash with its
+full version history. Each vlt invocation creates one trash entry, named
+after the time of deletion.
+
+Examples:
+  export VLT_TRASH_PATH=secret/.vlt-trash
+
+  vlt trash ls
+  vlt trash restore 20240130T140000.000Z
+  vlt trash purge --older-than 30d
+  vlt trash purge 20240130T140000.000Z`,
+}
+
+var trashLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List entries in the trash",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTrashLs(cmd.Context())
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Restore a trash entry to its original paths",
+	Long: `Restore all secrets in a trash entry to their original paths,
+including their version history.
+
+Never overwrites existing secrets; fails without changes if any
+original path has been reused.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTrashRestore(cmd.Context(), args[0])
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge [id]",
+	Short: "Permanently delete entries from the trash",
+	Long: `Permanently delete entries from the trash.
+
+Deletes a single entry by ID, or all entries older than --older-than.
+Durations accept d and w units in addition to h, m and s.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id := ""
+		if len(args) == 1 {
+			id = args[0]
+		}
+		return runTrashPurge(cmd.Context(), id)
+	},
+}
+
+func init() {
+	trashPurgeCmd.Flags().StringVar(&trashPurgeOlderThan, "older-than", "", "purge entries older than this duration (e.g. 30d)")
+	trashCmd.AddCommand(trashLsCmd)
+	trashCmd.AddCommand(trashRestoreCmd)
+	trashCmd.AddCommand(trashPurgeCmd)
+	rootCmd.AddCommand(trashCmd)
+}
+
+func runTrashLs(ctx context.Context) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	entries, err := client.ListTrash(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("Trash is empty.")
+		return nil
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s  %s  (%d secrets)\n", entry.ID, entry.TrashedAt.Local().Format("2006-01-02 15:04:05"), len(entry.Secrets))
+		for _, secret := range entry.Secrets {
+			fmt.Printf("    %s  (%d versions)\n", secret.Origin, secret.Versions)
+		}
+	}
+
+	return nil
+}
+
+func runTrashRestore(ctx context.Context, id string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	restored, err := client.RestoreTrash(ctx, id)
+	for _, p := range restored {
+		fmt.Printf("Restored %s\n", p)
+	}
+	return err
+}
+
+func runTrashPurge(ctx context.Context, id string) error {
+	if (id == "") == (trashPurgeOlderThan == "") {
+		return fmt.Errorf("specify either an entry ID or --older-than")
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+
+	client, err := vault.NewClient(cfg)
+	if err != nil {
+		return err
+	}
+
+	if id != "" {
+		if err := client.PurgeTrash(ctx, id); err != nil {
+			return err
+		}
+		fmt.Printf("Purged %s\n", id)
+		return nil
+	}
+
+	age, err := vault.ParseDuration(trashPurgeOlderThan)
+	if err != nil {
+		return err
+	}
+
+	purged, err := client.PurgeTrashOlderThan(ctx, age)
+	for _, p := range purged {
+		fmt.Printf("Purged %s\n", p)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(purged) == 0 {
+		fmt.Println("Nothing to purge.")
+	}
+	return nil
+}
@@ -5,7 +5,6 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
 	"github.com/spf13/cobra"
 )
@@ -24,11 +23,13 @@ Use -l to include version and timestamp metadata.
 
 Examples:
   vlt tree secret/myapp
-  vlt tree secret/myapp -l    # include metadata`,
+  vlt tree secret/myapp -l    # include metadata
+  vlt tree secret/myapp -l --at "1d ago"`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runTree(cmd.Context(), args[0])
 	},
+	Annotations: map[string]string{pointInTimeAnnotation: "true", offlineAnnotation: "true"},
 }
 
 func init() {
@@ -37,18 +38,20 @@ func init() {
 }
 
 func runTree(ctx context.Context, path string) error {
-	cfg, err := config.Load()
+	client, err := newReadClient(ctx)
 	if err != nil {
 		return err
 	}
 
-	client, err := vault.NewClient(cfg)
+	path, at, err := resolvePointInTime(path)
 	if err != nil {
 		return err
 	}
 
 	var tree *vault.TreeNode
-	if treeLong {
+	if !at.IsZero() {
+		tree, err = client.GetTreeAt(ctx, path, at)
+	} else if treeLong {
 		tree, err = client.GetTreeWithMetadata(ctx, path)
 	} else {
 		tree, err = client.GetTree(ctx, path)
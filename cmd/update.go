@@ -31,6 +31,7 @@ Example:
 }
 
 func init() {
+	addReasonFlag(updateCmd)
 	rootCmd.AddCommand(updateCmd)
 }
 
@@ -44,6 +45,7 @@ func runUpdate(ctx context.Context, path, value string) error {
 	if err != nil {
 		return err
 	}
+	recordProvenance(ctx, client, cfg)
 
 	if err := client.Update(ctx, path, value); err != nil {
 		return fmt.Errorf("%w (use 'add' to create new secrets)", err)
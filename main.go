@@ -2,6 +2,10 @@ package main
 
 import "github.com/ethanadams/vlt/cmd"
 
+// version is set at build time (-X main.version=...)
+var version = "dev"
+
 func main() {
+	cmd.SetVersion(version)
 	cmd.Execute()
 }
@@ -3,12 +3,21 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
 type Config struct {
 	VaultAddr  string
 	VaultToken string
+
+	// TrashPath is where deleted secrets are moved before being removed.
+	// Trash is disabled when empty.
+	TrashPath string
+
+	// Provenance records who wrote each version, from where and why in custom
+	// metadata. Enabled unless VLT_PROVENANCE is set to false.
+	Provenance bool
 }
 
 func Load() (*Config, error) {
@@ -30,8 +39,69 @@ func Load() (*Config, error) {
 		token = strings.TrimSpace(string(data))
 	}
 
+	provenance := true
+	if v := os.Getenv("VLT_PROVENANCE"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VLT_PROVENANCE %q: %w", v, err)
+		}
+		provenance = enabled
+	}
+
 	return &Config{
 		VaultAddr:  addr,
 		VaultToken: token,
+		TrashPath:  strings.TrimSuffix(os.Getenv("VLT_TRASH_PATH"), "/"),
+		Provenance: provenance,
 	}, nil
 }
+
+// S3Config configures access to S3-compatible object storage for snapshots.
+// Credentials come from the usual AWS sources (environment, profile, instance role).
+type S3Config struct {
+	// Endpoint is a custom endpoint for MinIO, Ceph and others, empty for AWS
+	Endpoint string
+
+	// Region of the buckets, empty to use the AWS configuration
+	Region string
+
+	// PathStyle addresses buckets as endpoint/bucket rather than bucket.endpoint,
+	// as most self-hosted stores require
+	PathStyle bool
+
+	// SSE is the server-side encryption of written snapshots ("AES256" or "aws:kms"),
+	// empty for the bucket default. SSEKMSKeyID selects the KMS key for "aws:kms".
+	SSE         string
+	SSEKMSKeyID string
+}
+
+// LoadS3 reads the S3 configuration from VLT_S3_* environment variables.
+// Path-style addressing is the default when a custom endpoint is set.
+func LoadS3() (*S3Config, error) {
+	cfg := &S3Config{
+		Endpoint:    os.Getenv("VLT_S3_ENDPOINT"),
+		Region:      os.Getenv("VLT_S3_REGION"),
+		SSE:         os.Getenv("VLT_S3_SSE"),
+		SSEKMSKeyID: os.Getenv("VLT_S3_SSE_KMS_KEY_ID"),
+	}
+	cfg.PathStyle = cfg.Endpoint != ""
+
+	if v := os.Getenv("VLT_S3_PATH_STYLE"); v != "" {
+		pathStyle, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid VLT_S3_PATH_STYLE %q: %w", v, err)
+		}
+		cfg.PathStyle = pathStyle
+	}
+
+	switch cfg.SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return nil, fmt.Errorf("invalid VLT_S3_SSE %q: expected AES256 or aws:kms", cfg.SSE)
+	}
+	if cfg.SSEKMSKeyID != "" && cfg.SSE != "aws:kms" {
+		return nil, fmt.Errorf("VLT_S3_SSE_KMS_KEY_ID requires VLT_S3_SSE=aws:kms")
+	}
+
+	return cfg, nil
+}
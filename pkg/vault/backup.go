@@ -0,0 +1,141 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// backupTimeFormat is the creation time in backup file names, always UTC
+const backupTimeFormat = "20060102T150405Z"
+
+// backupStateLength is the number of hex digits of the state digest in file names
+const backupStateLength = 12
+
+// BackupFile is a scheduled backup of a path, identified by its file name
+type BackupFile struct {
+	Name      string    // File name, without directory or prefix
+	CreatedAt time.Time // When the backup was taken
+	State     string    // Digest of the secret versions and metadata it holds, see BackupState
+}
+
+// BackupFileName names a backup of path taken at createdAt, e.g.
+// "secret_app-20240130T140000Z-3f9a1c0d2b7e.enc.yaml" for ext ".enc.yaml"
+func BackupFileName(path string, createdAt time.Time, state, ext string) string {
+	return fmt.Sprintf("%s-%s-%s%s", backupSlug(path), createdAt.UTC().Format(backupTimeFormat), state, ext)
+}
+
+// ParseBackupFileName parses the name of a backup of path, returning false if the file
+// is not one
+func ParseBackupFileName(path, name string) (BackupFile, bool) {
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(backupSlug(path)) + `-(\d{8}T\d{6}Z)-([0-9a-f]{` + fmt.Sprint(backupStateLength) + `})(\..+)?$`)
+	m := pattern.FindStringSubmatch(name)
+	if m == nil {
+		return BackupFile{}, false
+	}
+
+	createdAt, err := time.Parse(backupTimeFormat, m[1])
+	if err != nil {
+		return BackupFile{}, false
+	}
+	return BackupFile{Name: name, CreatedAt: createdAt, State: m[2]}, true
+}
+
+// backupSlug turns a secret path into a file name prefix
+func backupSlug(path string) string {
+	return strings.ReplaceAll(strings.Trim(path, "/"), "/", "_")
+}
+
+// BackupState returns a short digest of the versions and metadata of every secret under
+// a path. It changes whenever a secret is written, deleted or has its metadata updated,
+// so an unchanged state means a new backup would hold the same secrets as the last one.
+func (c *Client) BackupState(ctx context.Context, path string) (string, error) {
+	tree, err := c.GetMetadataTree(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	// Maps are encoded with sorted keys, so the digest doesn't depend on listing order
+	data, err := json.Marshal(tree)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute backup state: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:backupStateLength], nil
+}
+
+// RetentionPolicy is a grandfather-father-son rotation: for each period kind, the
+// newest backup of each of the given number of most recent periods is kept. Periods
+// are calendar days, ISO weeks, months and years in UTC.
+type RetentionPolicy struct {
+	Daily   int
+	Weekly  int
+	Monthly int
+	Yearly  int
+}
+
+// IsEmpty returns true if the policy keeps every backup
+func (p RetentionPolicy) IsEmpty() bool {
+	return p.Daily == 0 && p.Weekly == 0 && p.Monthly == 0 && p.Yearly == 0
+}
+
+// Select splits backups into those the policy keeps and those to prune, both newest
+// first. The newest backup is always kept. An empty policy keeps everything.
+func (p RetentionPolicy) Select(backups []BackupFile) (keep, prune []BackupFile) {
+	sorted := make([]BackupFile, len(backups))
+	copy(sorted, backups)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].CreatedAt.After(sorted[j].CreatedAt)
+	})
+
+	if p.IsEmpty() {
+		return sorted, nil
+	}
+
+	kept := make([]bool, len(sorted))
+	if len(sorted) > 0 {
+		kept[0] = true
+	}
+
+	rules := []struct {
+		count  int
+		period func(time.Time) string
+	}{
+		{p.Daily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.Weekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.Monthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{p.Yearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	for _, rule := range rules {
+		remaining := rule.count
+		last := ""
+		for i, b := range sorted {
+			if remaining == 0 {
+				break
+			}
+			if period := rule.period(b.CreatedAt.UTC()); period != last {
+				last = period
+				kept[i] = true
+				remaining--
+			}
+		}
+	}
+
+	for i, b := range sorted {
+		if kept[i] {
+			keep = append(keep, b)
+		} else {
+			prune = append(prune, b)
+		}
+	}
+	return keep, prune
+}
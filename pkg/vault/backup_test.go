@@ -0,0 +1,145 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBackupFileName(t *testing.T) {
+	createdAt := time.Date(2024, 1, 30, 15, 0, 0, 0, time.FixedZone("CET", 3600))
+	name := BackupFileName("/secret/my-app/", createdAt, "3f9a1c0d2b7e", ".enc.yaml.gz")
+	if name != "secret_my-app-20240130T140000Z-3f9a1c0d2b7e.enc.yaml.gz" {
+		t.Fatalf("BackupFileName() = %q", name)
+	}
+
+	backup, ok := ParseBackupFileName("secret/my-app", name)
+	if !ok {
+		t.Fatalf("ParseBackupFileName(%q) failed", name)
+	}
+	expected := BackupFile{Name: name, CreatedAt: createdAt.UTC(), State: "3f9a1c0d2b7e"}
+	if !reflect.DeepEqual(backup, expected) {
+		t.Errorf("ParseBackupFileName() = %+v, want %+v", backup, expected)
+	}
+}
+
+func TestParseBackupFileNameRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+	}{
+		{"other path", "secret_other-20240130T140000Z-3f9a1c0d2b7e.yaml"},
+		{"path with the same prefix", "secret_app_db-20240130T140000Z-3f9a1c0d2b7e.yaml"},
+		{"no state", "secret_app-20240130T140000Z.yaml"},
+		{"short state", "secret_app-20240130T140000Z-3f9a.yaml"},
+		{"bad time", "secret_app-20241399T140000Z-3f9a1c0d2b7e.yaml"},
+		{"unrelated", "notes.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ParseBackupFileName("secret/app", tt.file); ok {
+				t.Errorf("ParseBackupFileName(%q) accepted", tt.file)
+			}
+		})
+	}
+}
+
+func TestRetentionPolicySelect(t *testing.T) {
+	at := func(s string) BackupFile {
+		createdAt, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return BackupFile{Name: s, CreatedAt: createdAt}
+	}
+	names := func(backups []BackupFile) []string {
+		var out []string
+		for _, b := range backups {
+			out = append(out, b.Name)
+		}
+		return out
+	}
+
+	// Hourly backups over two days, then daily backups going back months
+	backups := []BackupFile{
+		at("2024-03-10T12:00:00Z"),
+		at("2024-03-10T11:00:00Z"),
+		at("2024-03-09T23:00:00Z"),
+		at("2024-03-09T22:00:00Z"),
+		at("2024-03-08T12:00:00Z"), // Friday
+		at("2024-03-04T12:00:00Z"), // Monday of the same ISO week as the 8th
+		at("2024-03-03T12:00:00Z"), // Sunday, previous ISO week
+		at("2024-02-15T12:00:00Z"),
+		at("2024-01-20T12:00:00Z"),
+		at("2023-12-31T12:00:00Z"),
+	}
+
+	tests := []struct {
+		name     string
+		policy   RetentionPolicy
+		expected []string
+	}{
+		{
+			name:     "empty policy keeps everything",
+			policy:   RetentionPolicy{},
+			expected: names(backups),
+		},
+		{
+			name:     "daily",
+			policy:   RetentionPolicy{Daily: 3},
+			expected: []string{"2024-03-10T12:00:00Z", "2024-03-09T23:00:00Z", "2024-03-08T12:00:00Z"},
+		},
+		{
+			name:     "weekly",
+			policy:   RetentionPolicy{Weekly: 3},
+			expected: []string{"2024-03-10T12:00:00Z", "2024-03-03T12:00:00Z", "2024-02-15T12:00:00Z"},
+		},
+		{
+			name:   "daily, monthly and yearly overlap",
+			policy: RetentionPolicy{Daily: 1, Monthly: 3, Yearly: 2},
+			expected: []string{
+				"2024-03-10T12:00:00Z",
+				"2024-02-15T12:00:00Z",
+				"2024-01-20T12:00:00Z",
+				"2023-12-31T12:00:00Z",
+			},
+		},
+		{
+			name:     "more periods than backups",
+			policy:   RetentionPolicy{Yearly: 10},
+			expected: []string{"2024-03-10T12:00:00Z", "2023-12-31T12:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Order of the input doesn't matter
+			shuffled := append([]BackupFile{}, backups[5:]...)
+			shuffled = append(shuffled, backups[:5]...)
+
+			keep, prune := tt.policy.Select(shuffled)
+			if !reflect.DeepEqual(names(keep), tt.expected) {
+				t.Errorf("kept %v, want %v", names(keep), tt.expected)
+			}
+			if len(keep)+len(prune) != len(backups) {
+				t.Errorf("kept %d and pruned %d of %d backups", len(keep), len(prune), len(backups))
+			}
+		})
+	}
+}
+
+func TestRetentionPolicyKeepsNewest(t *testing.T) {
+	backups := []BackupFile{
+		{Name: "old", CreatedAt: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Name: "new", CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	keep, prune := RetentionPolicy{Weekly: 1}.Select(backups)
+	if len(keep) != 1 || keep[0].Name != "new" || len(prune) != 1 || prune[0].Name != "old" {
+		t.Errorf("keep = %v, prune = %v", keep, prune)
+	}
+
+	if keep, prune := (RetentionPolicy{Daily: 1}).Select(nil); keep != nil || prune != nil {
+		t.Errorf("expected nothing for no backups, got %v, %v", keep, prune)
+	}
+}
@@ -0,0 +1,320 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// BackupFormat is a file format snapshots can be written in and read from
+type BackupFormat string
+
+const (
+	// BackupFormatVlt is the vlt snapshot format, the only one with versions, metadata
+	// and a manifest
+	BackupFormatVlt BackupFormat = "vlt"
+
+	// BackupFormatMedusa is the export format of medusa: a YAML tree of directories
+	// relative to the exported path, where the non-map values of a node are the fields
+	// of the secret at that path
+	BackupFormatMedusa BackupFormat = "medusa"
+
+	// BackupFormatVaultJSON is a JSON object mapping each secret path to the output of
+	// `vault kv get -format=json` for it
+	BackupFormatVaultJSON BackupFormat = "vault-json"
+)
+
+// ParseBackupFormat parses a backup format name
+func ParseBackupFormat(s string) (BackupFormat, error) {
+	switch f := BackupFormat(s); f {
+	case BackupFormatVlt, BackupFormatMedusa, BackupFormatVaultJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown backup format %q: expected vlt, medusa or vault-json", s)
+}
+
+// DetectBackupFormat returns the format of a plaintext backup file
+func DetectBackupFormat(data []byte) (BackupFormat, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return "", fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	if len(doc) == 0 {
+		return "", fmt.Errorf("failed to parse snapshot: file is empty")
+	}
+
+	_, hasSecrets := doc["secrets"]
+	_, hasCreated := doc["created_at"]
+	if hasSecrets && hasCreated {
+		return BackupFormatVlt, nil
+	}
+
+	for _, v := range doc {
+		if !isVaultKVResponse(v) {
+			return BackupFormatMedusa, nil
+		}
+	}
+	return BackupFormatVaultJSON, nil
+}
+
+// isVaultKVResponse returns true if v looks like the output of `vault kv get -format=json`
+func isVaultKVResponse(v any) bool {
+	response, ok := v.(map[string]any)
+	if !ok {
+		return false
+	}
+	data, ok := response["data"].(map[string]any)
+	if !ok {
+		return false
+	}
+	_, hasData := data["data"].(map[string]any)
+	_, hasMetadata := data["metadata"].(map[string]any)
+	return hasData && hasMetadata
+}
+
+// MarshalBackup encodes the current secrets of a snapshot in another tool's format.
+// Version history, settings and the manifest have no place in these formats and are
+// left out; incremental snapshots must be resolved first.
+func MarshalBackup(snapshot *Snapshot, format BackupFormat) ([]byte, error) {
+	if snapshot.Base != nil {
+		return nil, fmt.Errorf("incremental snapshots cannot be written as %s", format)
+	}
+
+	switch format {
+	case BackupFormatMedusa:
+		return marshalMedusa(snapshot)
+	case BackupFormatVaultJSON:
+		return marshalVaultJSON(snapshot)
+	}
+	return nil, fmt.Errorf("cannot write snapshots as %s", format)
+}
+
+// UnmarshalBackup decodes a plaintext backup file in another tool's format
+func UnmarshalBackup(data []byte, format BackupFormat) (*Snapshot, error) {
+	switch format {
+	case BackupFormatMedusa:
+		return unmarshalMedusa(data)
+	case BackupFormatVaultJSON:
+		return unmarshalVaultJSON(data)
+	}
+	return nil, fmt.Errorf("cannot read %s backups", format)
+}
+
+// marshalMedusa builds the medusa tree of a snapshot. A secret and a directory with
+// the same path share a node, so a field can't have the name of a subdirectory, and
+// map values would be read back as directories.
+func marshalMedusa(snapshot *Snapshot) ([]byte, error) {
+	root := make(map[string]any)
+	owners := make(map[string]string) // Node key path -> secret whose field it is
+
+	for _, relPath := range sortedSecretPaths(snapshot) {
+		node := root
+		segments := strings.Split(relPath, "/")
+		for i, segment := range segments {
+			key := strings.Join(segments[:i+1], "/")
+			if owner, ok := owners[key]; ok {
+				return nil, fmt.Errorf("cannot write %s as medusa: field %s of %s has the name of a directory", relPath, segment, owner)
+			}
+			child, ok := node[segment].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[segment] = child
+			}
+			node = child
+		}
+
+		fields := snapshot.Secrets[relPath].SecretData()
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("cannot write %s as medusa: secrets without fields are read back as directories", relPath)
+		}
+		for field, value := range fields {
+			if _, ok := value.(map[string]any); ok {
+				return nil, fmt.Errorf("cannot write %s as medusa: field %s holds a map, which medusa reads as a directory", relPath, field)
+			}
+			if _, ok := node[field]; ok {
+				return nil, fmt.Errorf("cannot write %s as medusa: field %s has the name of a directory", relPath, field)
+			}
+			node[field] = value
+			owners[relPath+"/"+field] = relPath
+		}
+	}
+
+	data, err := yaml.Marshal(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal medusa backup: %w", err)
+	}
+	return data, nil
+}
+
+// unmarshalMedusa reads a medusa tree. The file does not record where it was exported
+// from, so the snapshot has no path.
+func unmarshalMedusa(data []byte) (*Snapshot, error) {
+	var root map[string]any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse medusa backup: %w", err)
+	}
+
+	snapshot := &Snapshot{Format: snapshotFormat, Secrets: make(map[string]SnapshotSecret)}
+	if err := readMedusaNode(snapshot, "", root); err != nil {
+		return nil, err
+	}
+	if len(snapshot.Secrets) == 0 {
+		return nil, fmt.Errorf("medusa backup holds no secrets")
+	}
+	return snapshot, nil
+}
+
+// readMedusaNode adds the secret at a node, if it has fields, and the secrets below it
+func readMedusaNode(snapshot *Snapshot, relPath string, node map[string]any) error {
+	fields := make(map[string]any)
+	for key, value := range node {
+		if child, ok := value.(map[string]any); ok {
+			if err := readMedusaNode(snapshot, joinRelPath(relPath, key), child); err != nil {
+				return err
+			}
+			continue
+		}
+		fields[key] = value
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	if relPath == "" {
+		return fmt.Errorf("medusa backup has values at its root, which is not a secret path")
+	}
+	snapshot.Secrets[relPath] = SnapshotSecret{Data: fields}
+	return nil
+}
+
+// vaultKVResponse is the output of `vault kv get -format=json` for a KV v2 secret
+type vaultKVResponse struct {
+	RequestID     string   `json:"request_id"`
+	LeaseID       string   `json:"lease_id"`
+	LeaseDuration int      `json:"lease_duration"`
+	Renewable     bool     `json:"renewable"`
+	Data          vaultKV  `json:"data"`
+	Warnings      []string `json:"warnings"`
+}
+
+type vaultKV struct {
+	Data     map[string]any  `json:"data"`
+	Metadata vaultKVMetadata `json:"metadata"`
+}
+
+type vaultKVMetadata struct {
+	CreatedTime    string            `json:"created_time"`
+	CustomMetadata map[string]string `json:"custom_metadata"`
+	DeletionTime   string            `json:"deletion_time"`
+	Destroyed      bool              `json:"destroyed"`
+	Version        int               `json:"version"`
+}
+
+// marshalVaultJSON writes the current version of each secret as `vault kv get` would,
+// keyed by its full path
+func marshalVaultJSON(snapshot *Snapshot) ([]byte, error) {
+	responses := make(map[string]vaultKVResponse, len(snapshot.Secrets))
+	for relPath, secret := range snapshot.Secrets {
+		var custom map[string]string
+		if secret.Metadata != nil {
+			custom = secret.Metadata.Custom
+		}
+		responses[joinRelPath(snapshot.Path, relPath)] = vaultKVResponse{
+			Data: vaultKV{
+				Data: secret.SecretData(),
+				Metadata: vaultKVMetadata{
+					CreatedTime:    secret.Updated.UTC().Format(time.RFC3339Nano),
+					CustomMetadata: custom,
+					Version:        secret.Version,
+				},
+			},
+		}
+	}
+
+	data, err := json.MarshalIndent(responses, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal vault-json backup: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// unmarshalVaultJSON reads `vault kv get` outputs keyed by path. The snapshot path is
+// the deepest directory holding every secret. Settings and custom metadata are not
+// carried over, as the output lacks the settings they are restored with.
+func unmarshalVaultJSON(data []byte) (*Snapshot, error) {
+	var responses map[string]vaultKVResponse
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&responses); err != nil {
+		return nil, fmt.Errorf("failed to parse vault-json backup: %w", err)
+	}
+	if len(responses) == 0 {
+		return nil, fmt.Errorf("vault-json backup holds no secrets")
+	}
+
+	paths := make([]string, 0, len(responses))
+	for path := range responses {
+		paths = append(paths, strings.Trim(path, "/"))
+	}
+
+	snapshot := &Snapshot{
+		Format:  snapshotFormat,
+		Path:    commonDirectory(paths),
+		Secrets: make(map[string]SnapshotSecret, len(responses)),
+	}
+	for path, response := range responses {
+		if response.Data.Metadata.DeletionTime != "" || response.Data.Metadata.Destroyed {
+			continue
+		}
+
+		secret := SnapshotSecret{
+			Data:    snapshotFields(response.Data.Data),
+			Version: response.Data.Metadata.Version,
+		}
+		if t, err := time.Parse(time.RFC3339Nano, response.Data.Metadata.CreatedTime); err == nil {
+			secret.Updated = t
+			if t.After(snapshot.CreatedAt) {
+				snapshot.CreatedAt = t
+			}
+		}
+
+		relPath, _ := cutPathPrefix(strings.Trim(path, "/"), snapshot.Path)
+		snapshot.Secrets[relPath] = secret
+	}
+
+	return snapshot, nil
+}
+
+// commonDirectory returns the deepest directory containing every path
+func commonDirectory(paths []string) string {
+	var common []string
+	for i, path := range paths {
+		segments := strings.Split(path, "/")
+		dir := segments[:len(segments)-1]
+		if i == 0 {
+			common = dir
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(dir) && common[n] == dir[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	return strings.Join(common, "/")
+}
+
+// sortedSecretPaths returns the relative paths of a snapshot's secrets in order
+func sortedSecretPaths(snapshot *Snapshot) []string {
+	paths := make([]string, 0, len(snapshot.Secrets))
+	for relPath := range snapshot.Secrets {
+		paths = append(paths, relPath)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,245 @@
+package vault
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDetectBackupFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected BackupFormat
+		wantErr  bool
+	}{
+		{
+			name:     "vlt snapshot",
+			data:     "path: secret/app\ncreated_at: 2024-01-01T00:00:00Z\nsecrets:\n  key:\n    data: {value: v}\n",
+			expected: BackupFormatVlt,
+		},
+		{
+			name:     "medusa tree",
+			data:     "db:\n  password:\n    value: pw\n",
+			expected: BackupFormatMedusa,
+		},
+		{
+			name:     "medusa tree with a secrets directory",
+			data:     "secrets:\n  key:\n    value: v\n",
+			expected: BackupFormatMedusa,
+		},
+		{
+			name:     "vault-json",
+			data:     `{"secret/app/key": {"data": {"data": {"value": "v"}, "metadata": {"version": 1}}}}`,
+			expected: BackupFormatVaultJSON,
+		},
+		{
+			name:    "empty",
+			data:    "",
+			wantErr: true,
+		},
+		{
+			name:    "not a map",
+			data:    "- a\n- b\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			format, err := DetectBackupFormat([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DetectBackupFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if format != tt.expected {
+				t.Errorf("DetectBackupFormat() = %q, want %q", format, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseBackupFormat(t *testing.T) {
+	for _, s := range []string{"vlt", "medusa", "vault-json"} {
+		if f, err := ParseBackupFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseBackupFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseBackupFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestMedusaRoundTrip(t *testing.T) {
+	snapshot := &Snapshot{
+		Path: "secret/app",
+		Secrets: map[string]SnapshotSecret{
+			"db":          {Data: map[string]any{"host": "localhost", "port": 5432}},
+			"db/password": {Data: map[string]any{"value": "pw"}},
+			"api/token":   {Data: map[string]any{"value": "abc", "scopes": []any{"read", "write"}}},
+		},
+	}
+
+	data, err := MarshalBackup(snapshot, BackupFormatMedusa)
+	if err != nil {
+		t.Fatalf("MarshalBackup failed: %v", err)
+	}
+	if format, _ := DetectBackupFormat(data); format != BackupFormatMedusa {
+		t.Errorf("written file detected as %q", format)
+	}
+
+	read, err := UnmarshalBackup(data, BackupFormatMedusa)
+	if err != nil {
+		t.Fatalf("UnmarshalBackup failed: %v", err)
+	}
+	if read.Path != "" {
+		t.Errorf("medusa files have no path, got %q", read.Path)
+	}
+	if len(read.Secrets) != len(snapshot.Secrets) {
+		t.Fatalf("read %d secrets, want %d", len(read.Secrets), len(snapshot.Secrets))
+	}
+	for relPath, secret := range snapshot.Secrets {
+		if !sameData(read.Secrets[relPath].Data, secret.Data) {
+			t.Errorf("%s = %v, want %v", relPath, read.Secrets[relPath].Data, secret.Data)
+		}
+	}
+}
+
+func TestMarshalMedusaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]SnapshotSecret
+		errMsg  string
+	}{
+		{
+			name: "map value",
+			secrets: map[string]SnapshotSecret{
+				"app": {Data: map[string]any{"nested": map[string]any{"a": 1}}},
+			},
+			errMsg: "holds a map",
+		},
+		{
+			name: "field named like a directory",
+			secrets: map[string]SnapshotSecret{
+				"app":      {Data: map[string]any{"db": "x"}},
+				"app/db/a": {Data: map[string]any{"value": "y"}},
+			},
+			errMsg: "name of a directory",
+		},
+		{
+			name: "no fields",
+			secrets: map[string]SnapshotSecret{
+				"app": {Data: map[string]any{}},
+			},
+			errMsg: "without fields",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := MarshalBackup(&Snapshot{Path: "secret", Secrets: tt.secrets}, BackupFormatMedusa)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("MarshalBackup() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestUnmarshalMedusaRootValues(t *testing.T) {
+	if _, err := UnmarshalBackup([]byte("value: x\n"), BackupFormatMedusa); err == nil {
+		t.Error("expected error for values at the root")
+	}
+}
+
+func TestVaultJSONRoundTrip(t *testing.T) {
+	updated := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	snapshot := &Snapshot{
+		Path: "secret/app",
+		Secrets: map[string]SnapshotSecret{
+			"db/password": {
+				Data:     map[string]any{"value": "pw", "port": int64(5432)},
+				Version:  4,
+				Updated:  updated,
+				Metadata: &SnapshotMetadata{Custom: map[string]string{"owner": "team-a"}},
+			},
+			"token": {Data: map[string]any{"value": "abc"}, Version: 1, Updated: updated.Add(-time.Hour)},
+		},
+	}
+
+	data, err := MarshalBackup(snapshot, BackupFormatVaultJSON)
+	if err != nil {
+		t.Fatalf("MarshalBackup failed: %v", err)
+	}
+	if !strings.Contains(string(data), `"secret/app/db/password"`) || !strings.Contains(string(data), `"owner": "team-a"`) {
+		t.Errorf("expected full paths and custom metadata in:\n%s", data)
+	}
+	if format, _ := DetectBackupFormat(data); format != BackupFormatVaultJSON {
+		t.Errorf("written file detected as %q", format)
+	}
+
+	read, err := UnmarshalBackup(data, BackupFormatVaultJSON)
+	if err != nil {
+		t.Fatalf("UnmarshalBackup failed: %v", err)
+	}
+	if read.Path != "secret/app" {
+		t.Errorf("Path = %q, want secret/app", read.Path)
+	}
+	if !read.CreatedAt.Equal(updated) {
+		t.Errorf("CreatedAt = %v, want the latest version time %v", read.CreatedAt, updated)
+	}
+	secret := read.Secrets["db/password"]
+	if !reflect.DeepEqual(secret.Data, snapshot.Secrets["db/password"].Data) || secret.Version != 4 || !secret.Updated.Equal(updated) {
+		t.Errorf("db/password = %+v", secret)
+	}
+	if secret.Metadata != nil {
+		t.Errorf("settings are not in vault-json, Metadata = %+v", secret.Metadata)
+	}
+}
+
+func TestUnmarshalVaultJSONDeleted(t *testing.T) {
+	data := `{
+  "secret/a/live": {"data": {"data": {"value": "v"}, "metadata": {"version": 2, "created_time": "2024-01-01T00:00:00Z", "deletion_time": ""}}},
+  "secret/a/gone": {"data": {"data": null, "metadata": {"version": 3, "created_time": "2024-01-01T00:00:00Z", "deletion_time": "2024-01-02T00:00:00Z"}}}
+}`
+	read, err := UnmarshalBackup([]byte(data), BackupFormatVaultJSON)
+	if err != nil {
+		t.Fatalf("UnmarshalBackup failed: %v", err)
+	}
+	if _, ok := read.Secrets["gone"]; ok || len(read.Secrets) != 1 {
+		t.Errorf("expected only the live secret, got %v", read.Secrets)
+	}
+}
+
+func TestCommonDirectory(t *testing.T) {
+	tests := []struct {
+		paths    []string
+		expected string
+	}{
+		{[]string{"secret/app/a", "secret/app/b/c"}, "secret/app"},
+		{[]string{"secret/app/a"}, "secret/app"},
+		{[]string{"secret/a/x", "secret/b/y"}, "secret"},
+		{[]string{"one/x", "two/y"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := commonDirectory(tt.paths); got != tt.expected {
+			t.Errorf("commonDirectory(%v) = %q, want %q", tt.paths, got, tt.expected)
+		}
+	}
+}
+
+func TestUnmarshalSnapshotDetectsFormat(t *testing.T) {
+	data := []byte("db:\n  password:\n    value: pw\n")
+
+	if _, err := UnmarshalSnapshot(data, SnapshotLoadOptions{}); err != ErrPlaintextSnapshot {
+		t.Errorf("expected ErrPlaintextSnapshot, got %v", err)
+	}
+
+	snapshot, err := UnmarshalSnapshot(data, SnapshotLoadOptions{AllowPlaintext: true})
+	if err != nil {
+		t.Fatalf("UnmarshalSnapshot failed: %v", err)
+	}
+	if v := snapshot.Secrets["db/password"].Data["value"]; v != "pw" {
+		t.Errorf("db/password value = %v, want pw", v)
+	}
+}
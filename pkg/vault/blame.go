@@ -0,0 +1,230 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyBlame describes the last modification of a key
+type KeyBlame struct {
+	Key        string // Flattened key, prefixed with the secret's relative path in a directory
+	SecretPath string // Full path to the secret holding the key
+	Version    int    // Version of the secret that last set the key
+	Time       time.Time
+	Changes    int         // Number of times the value changed after it was first set
+	Provenance *Provenance // Who made the last modification and why, if recorded
+}
+
+// KeyVersionChange is a change to a single key in one version of a secret
+type KeyVersionChange struct {
+	Version VersionInfo
+	Change  VersionChange
+}
+
+// blameVersion is one readable version of a secret with its flattened data
+type blameVersion struct {
+	info VersionInfo
+	data map[string]any
+}
+
+// Blame returns, for every key currently under a path, the version and time it was
+// last modified and how many times it has changed. Sorted by key.
+func (c *Client) Blame(ctx context.Context, path string) ([]KeyBlame, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secretPaths) == 0 {
+		versions, err := c.readBlameVersions(ctx, path, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("no versions found at %s", path)
+		}
+		return blameKeys(path, "", versions), nil
+	}
+
+	var result []KeyBlame
+	for _, relPath := range secretPaths {
+		fullPath := path + "/" + relPath
+		versions, err := c.readBlameVersions(ctx, fullPath, true)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, blameKeys(fullPath, relPath, versions)...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result, nil
+}
+
+// readBlameVersions reads all readable versions of a secret, oldest first
+func (c *Client) readBlameVersions(ctx context.Context, path string, forDirectory bool) ([]blameVersion, error) {
+	history, err := c.GetVersionHistory(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]blameVersion, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		data, err := c.ReadSecretVersion(ctx, path, history[i].Version)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		versions = append(versions, blameVersion{
+			info: history[i],
+			data: FlattenAndExtractValues(data, forDirectory),
+		})
+	}
+
+	return versions, nil
+}
+
+// blameKeys computes the blame of the keys present in the latest version.
+// Versions must be sorted oldest first. Keys are prefixed with relPath, if set.
+func blameKeys(secretPath, relPath string, versions []blameVersion) []KeyBlame {
+	if len(versions) == 0 {
+		return nil
+	}
+
+	blames := make(map[string]*KeyBlame)
+	var prev map[string]any
+	for _, v := range versions {
+		for k, val := range v.data {
+			old, existed := prev[k]
+			b, seen := blames[k]
+			switch {
+			case !seen:
+				blames[k] = &KeyBlame{Version: v.info.Version, Time: v.info.CreatedTime, Provenance: v.info.Provenance}
+			case !existed || fmt.Sprintf("%v", old) != fmt.Sprintf("%v", val):
+				b.Version = v.info.Version
+				b.Time = v.info.CreatedTime
+				b.Provenance = v.info.Provenance
+				b.Changes++
+			}
+		}
+		prev = v.data
+	}
+
+	var result []KeyBlame
+	for k := range versions[len(versions)-1].data {
+		b := blames[k]
+		b.SecretPath = secretPath
+		b.Key = k
+		if relPath != "" {
+			b.Key = relPath
+			if k != "" {
+				b.Key += "." + k
+			}
+		}
+		result = append(result, *b)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Key < result[j].Key
+	})
+
+	return result
+}
+
+// GetKeyHistory follows a single key across all versions of a secret. Keys are named
+// as Blame names them: flattened (e.g. "db.password"), and in a directory prefixed with
+// the relative path of the secret holding them. Returns the versions that added,
+// modified or deleted the key, newest first.
+func (c *Client) GetKeyHistory(ctx context.Context, path, key string) ([]KeyVersionChange, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	forDirectory := false
+	if len(secretPaths) > 0 {
+		relPath, secretKey, ok := splitDirectoryKey(secretPaths, key)
+		if !ok {
+			return nil, fmt.Errorf("key %q does not name a secret under %s", key, path)
+		}
+		path, key, forDirectory = path+"/"+relPath, secretKey, true
+	}
+
+	versions, err := c.readBlameVersions(ctx, path, forDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("no versions found at %s", path)
+	}
+
+	result := keyHistory(versions, key)
+	if len(result) == 0 {
+		return nil, fmt.Errorf("key %q not found in any version of %s", key, path)
+	}
+
+	return result, nil
+}
+
+// keyHistory returns the changes to a flattened key across versions sorted oldest first,
+// newest first. The Key of each change is the key as given.
+func keyHistory(versions []blameVersion, key string) []KeyVersionChange {
+	var result []KeyVersionChange
+	var prev any
+	existed := false
+
+	for _, v := range versions {
+		val, exists := v.data[key]
+		oldStr, newStr := fmt.Sprintf("%v", prev), fmt.Sprintf("%v", val)
+
+		change := VersionChange{Key: key}
+		changed := true
+		switch {
+		case exists && !existed:
+			change.Type = ChangeAdded
+			change.NewValue, change.NewLength = newStr, len(newStr)
+		case exists && oldStr != newStr:
+			change.Type = ChangeModified
+			change.OldValue, change.OldLength = oldStr, len(oldStr)
+			change.NewValue, change.NewLength = newStr, len(newStr)
+		case !exists && existed:
+			change.Type = ChangeDeleted
+			change.OldValue, change.OldLength = oldStr, len(oldStr)
+		default:
+			changed = false
+		}
+
+		if changed {
+			result = append(result, KeyVersionChange{Version: v.info, Change: change})
+		}
+		prev, existed = val, exists
+	}
+
+	slices.Reverse(result)
+	return result
+}
+
+// splitDirectoryKey splits a key as Blame names it in a directory into the relative path
+// of the secret and the key within it. The longest matching secret path wins.
+func splitDirectoryKey(secretPaths []string, key string) (relPath, secretKey string, ok bool) {
+	for _, p := range secretPaths {
+		if len(p) <= len(relPath) {
+			continue
+		}
+		if key == p {
+			relPath, secretKey, ok = p, "", true
+		} else if rest, found := strings.CutPrefix(key, p+"."); found {
+			relPath, secretKey, ok = p, rest, true
+		}
+	}
+	return relPath, secretKey, ok
+}
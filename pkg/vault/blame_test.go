@@ -0,0 +1,128 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBlameKeys(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := t1.Add(time.Hour)
+	t3 := t2.Add(time.Hour)
+
+	versions := []blameVersion{
+		{info: VersionInfo{Version: 1, CreatedTime: t1}, data: map[string]any{"user": "app", "password": "a"}},
+		{info: VersionInfo{Version: 2, CreatedTime: t2}, data: map[string]any{"user": "app", "password": "b", "host": "db"}},
+		{info: VersionInfo{Version: 4, CreatedTime: t3}, data: map[string]any{"user": "app", "password": "c"}},
+	}
+
+	got := blameKeys("secret/app/db", "db", versions)
+
+	expected := []KeyBlame{
+		{Key: "db.password", SecretPath: "secret/app/db", Version: 4, Time: t3, Changes: 2},
+		{Key: "db.user", SecretPath: "secret/app/db", Version: 1, Time: t1, Changes: 0},
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("blameKeys() returned %d keys, want %d: %+v", len(got), len(expected), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("blameKeys()[%d] = %+v, want %+v", i, got[i], expected[i])
+		}
+	}
+}
+
+func TestBlameKeysReAdded(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	versions := []blameVersion{
+		{info: VersionInfo{Version: 1, CreatedTime: t1}, data: map[string]any{"": "x"}},
+		{info: VersionInfo{Version: 2, CreatedTime: t1.Add(time.Hour)}, data: map[string]any{"other": "y"}},
+		{info: VersionInfo{Version: 3, CreatedTime: t1.Add(2 * time.Hour)}, data: map[string]any{"": "x"}},
+	}
+
+	got := blameKeys("secret/app/token", "token", versions)
+	if len(got) != 1 {
+		t.Fatalf("blameKeys() returned %d keys, want 1: %+v", len(got), got)
+	}
+	if got[0].Key != "token" || got[0].Version != 3 || got[0].Changes != 1 {
+		t.Errorf("blameKeys() = %+v, want key token at v3 with 1 change", got[0])
+	}
+}
+
+func TestBlameKeysEmpty(t *testing.T) {
+	if got := blameKeys("secret/app", "", nil); got != nil {
+		t.Errorf("blameKeys() = %+v, want nil", got)
+	}
+}
+
+func TestKeyHistory(t *testing.T) {
+	t1 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(v int) VersionInfo {
+		return VersionInfo{Version: v, CreatedTime: t1.Add(time.Duration(v) * time.Hour)}
+	}
+
+	// Flattened as Blame reads them
+	versions := []blameVersion{
+		{info: at(1), data: map[string]any{"db.password": "a", "db.user": "app"}},
+		{info: at(2), data: map[string]any{"db.password": "a", "db.user": "app2"}},
+		{info: at(3), data: map[string]any{"db.password": "b", "db.user": "app2"}},
+		{info: at(5), data: map[string]any{"db.user": "app2"}},
+		{info: at(6), data: map[string]any{"db.password": "c", "db.user": "app2"}},
+	}
+
+	got := keyHistory(versions, "db.password")
+
+	expected := []struct {
+		version int
+		change  ChangeType
+	}{
+		{6, ChangeAdded},
+		{5, ChangeDeleted},
+		{3, ChangeModified},
+		{1, ChangeAdded},
+	}
+	if len(got) != len(expected) {
+		t.Fatalf("keyHistory() returned %d changes, want %d: %+v", len(got), len(expected), got)
+	}
+	for i, e := range expected {
+		if got[i].Version.Version != e.version || got[i].Change.Type != e.change || got[i].Change.Key != "db.password" {
+			t.Errorf("keyHistory()[%d] = v%d %v, want v%d %v", i, got[i].Version.Version, got[i].Change, e.version, e.change)
+		}
+	}
+	if got[2].Change.OldValue != "a" || got[2].Change.NewValue != "b" {
+		t.Errorf("keyHistory() modification = %+v, want a -> b", got[2].Change)
+	}
+
+	if len(keyHistory(versions, "db")) != 0 {
+		t.Error("keyHistory() matched a key by its prefix")
+	}
+}
+
+func TestSplitDirectoryKey(t *testing.T) {
+	paths := []string{"app", "app/db", "app/db.v2", "cache"}
+
+	tests := []struct {
+		key       string
+		relPath   string
+		secretKey string
+		ok        bool
+	}{
+		{"cache", "cache", "", true},
+		{"app/db.password", "app/db", "password", true},
+		{"app/db.v2.password", "app/db.v2", "password", true},
+		{"app.config.port", "app", "config.port", true},
+		{"other.key", "", "", false},
+		{"app/d", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			relPath, secretKey, ok := splitDirectoryKey(paths, tt.key)
+			if relPath != tt.relPath || secretKey != tt.secretKey || ok != tt.ok {
+				t.Errorf("splitDirectoryKey(%q) = %q, %q, %v, want %q, %q, %v", tt.key, relPath, secretKey, ok, tt.relPath, tt.secretKey, tt.ok)
+			}
+		})
+	}
+}
@@ -15,7 +15,12 @@ import (
 
 type Client struct {
 	client     *api.Client
-	mountCache []string // cached KV v2 mounts, sorted by length descending
+	mountCache []string    // cached KV v2 mounts, sorted by length descending
+	trashPath  string      // where deleted secrets are kept, empty if trash is disabled
+	trashID    string      // trash batch for this client, created on first delete
+	provenance *Provenance // recorded on every write, nil if disabled
+
+	provenanceWarn func(error) // called when provenance can't be recorded, nil to ignore
 }
 
 func NewClient(cfg *config.Config) (*Client, error) {
@@ -29,15 +34,22 @@ func NewClient(cfg *config.Config) (*Client, error) {
 
 	client.SetToken(cfg.VaultToken)
 
-	return &Client{client: client}, nil
+	return &Client{client: client, trashPath: cfg.TrashPath}, nil
 }
 
-// ListSecrets recursively lists all secrets under a path and returns them as a nested map
+// ListSecrets recursively lists all secrets under a path and returns them as a nested map.
+// The trash is left out unless the path is inside it.
 func (c *Client) ListSecrets(ctx context.Context, path string) (map[string]any, error) {
 	// Determine the mount and secret path
 	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
 
-	secrets, err := c.listRecursive(ctx, mount, secretPath)
+	// listRecursive works on paths within the mount, so the trash is given the same way
+	hidden := c.hiddenTrashDir(path)
+	if hidden != "" && secretPath != "" {
+		hidden = strings.TrimSuffix(secretPath, "/") + "/" + hidden
+	}
+
+	secrets, err := c.listRecursive(ctx, mount, secretPath, hidden)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +113,9 @@ func setNestedValue(m map[string]any, key string, value any) {
 	}
 }
 
-func (c *Client) listRecursive(ctx context.Context, mount, path string) (map[string]any, error) {
+// listRecursive reads all secrets below path, skipping the directory hidden (a path
+// within the mount) if set
+func (c *Client) listRecursive(ctx context.Context, mount, path, hidden string) (map[string]any, error) {
 	result := make(map[string]any)
 
 	secret, err := c.client.Logical().ListWithContext(ctx, fmt.Sprintf("%s/metadata/%s", mount, ensureTrailingSlash(path)))
@@ -135,8 +149,11 @@ func (c *Client) listRecursive(ctx context.Context, mount, path string) (map[str
 		fullPath += strings.TrimSuffix(keyStr, "/")
 
 		if strings.HasSuffix(keyStr, "/") {
+			if hidden != "" && fullPath == hidden {
+				continue
+			}
 			// This is a directory, recurse
-			nested, err := c.listRecursive(ctx, mount, fullPath)
+			nested, err := c.listRecursive(ctx, mount, fullPath, hidden)
 			if err != nil {
 				return nil, err
 			}
@@ -289,17 +306,45 @@ func (c *Client) WriteSecret(ctx context.Context, path string, data map[string]a
 
 // WriteSecretWithMount writes data to a secret path with an explicit mount point.
 // Use this when the mount path contains slashes (e.g., "satellite/slc").
+// Records the client's provenance on the new version, if set. Failing to record it
+// doesn't fail the write, see SetProvenanceWarning.
 func (c *Client) WriteSecretWithMount(ctx context.Context, mount, path string, data map[string]any) error {
-	_, err := c.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/data/%s", mount, path), map[string]any{
-		"data": data,
-	})
+	version, err := c.writeSecretData(ctx, mount, path, data)
 	if err != nil {
-		return fmt.Errorf("failed to write secret at %s/%s: %w", mount, path, err)
+		return err
 	}
-
+	c.recordProvenance(ctx, mount, path, version)
 	return nil
 }
 
+// writeSecretData writes a new version of a secret without recording provenance.
+// Returns the version written, or 0 if Vault did not report it.
+func (c *Client) writeSecretData(ctx context.Context, mount, path string, data map[string]any) (int, error) {
+	return c.writeSecretDataCAS(ctx, mount, path, data, -1)
+}
+
+// writeSecretDataCAS is writeSecretData with check-and-set: the write only succeeds if
+// the current version of the secret is cas (0 if it must not exist). A negative cas
+// writes unconditionally.
+func (c *Client) writeSecretDataCAS(ctx context.Context, mount, path string, data map[string]any, cas int) (int, error) {
+	body := map[string]any{"data": data}
+	if cas >= 0 {
+		body["options"] = map[string]any{"cas": cas}
+	}
+
+	secret, err := c.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/data/%s", mount, path), body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write secret at %s/%s: %w", mount, path, err)
+	}
+
+	if secret == nil || secret.Data == nil {
+		return 0, nil
+	}
+	v, _ := secret.Data["version"].(json.Number)
+	version, _ := v.Int64()
+	return int(version), nil
+}
+
 // WriteSecrets writes multiple secrets from a flattened map.
 // Each key in the data map becomes a separate secret path under basePath,
 // with the value stored as {"value": val}.
@@ -333,8 +378,19 @@ func (c *Client) WriteSecretsWithMount(ctx context.Context, mount, basePath stri
 	return len(keys), nil
 }
 
-// DeleteSecret deletes a secret at the given path (all versions and metadata)
+// DeleteSecret deletes a secret at the given path (all versions and metadata).
+// If trash is enabled, the version history is moved to the trash first.
 func (c *Client) DeleteSecret(ctx context.Context, path string) error {
+	if c.TrashEnabled() && !c.isTrashPath(path) {
+		if err := c.moveToTrash(ctx, path); err != nil {
+			return err
+		}
+	}
+	return c.purgeSecret(ctx, path)
+}
+
+// purgeSecret permanently deletes a secret without moving it to the trash
+func (c *Client) purgeSecret(ctx context.Context, path string) error {
 	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
 
 	_, err := c.client.Logical().DeleteWithContext(ctx, fmt.Sprintf("%s/metadata/%s", mount, secretPath))
@@ -345,6 +401,19 @@ func (c *Client) DeleteSecret(ctx context.Context, path string) error {
 	return nil
 }
 
+// writeMetadata writes metadata fields of a secret. Fields not given are left unchanged,
+// custom_metadata is replaced as a whole.
+func (c *Client) writeMetadata(ctx context.Context, path string, fields map[string]any) error {
+	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
+
+	_, err := c.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/metadata/%s", mount, secretPath), fields)
+	if err != nil {
+		return fmt.Errorf("failed to write metadata at %s: %w", path, err)
+	}
+
+	return nil
+}
+
 // SecretExists checks if a secret exists at the given path
 func (c *Client) SecretExists(ctx context.Context, path string) (bool, error) {
 	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
@@ -358,13 +427,16 @@ func (c *Client) SecretExists(ctx context.Context, path string) (bool, error) {
 }
 
 // ListSecretPaths recursively lists all secret paths under a given path
-// Returns relative paths from the given base path
+// Returns relative paths from the given base path. The trash is left out
+// unless the path is inside it.
 func (c *Client) ListSecretPaths(ctx context.Context, path string) ([]string, error) {
 	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
-	return c.listSecretPathsRecursive(ctx, mount, secretPath, "")
+	return c.listSecretPathsRecursive(ctx, mount, secretPath, "", c.hiddenTrashDir(path))
 }
 
-func (c *Client) listSecretPathsRecursive(ctx context.Context, mount, basePath, relativePath string) ([]string, error) {
+// listSecretPathsRecursive lists the secrets below relativePath, skipping the
+// directory hidden (relative to basePath) if set
+func (c *Client) listSecretPathsRecursive(ctx context.Context, mount, basePath, relativePath, hidden string) ([]string, error) {
 	var paths []string
 
 	fullPath := basePath
@@ -397,8 +469,11 @@ func (c *Client) listSecretPathsRecursive(ctx context.Context, mount, basePath,
 		}
 
 		if strings.HasSuffix(keyStr, "/") {
+			if hidden != "" && strings.TrimSuffix(keyRelPath, "/") == hidden {
+				continue
+			}
 			// Directory - recurse
-			subPaths, err := c.listSecretPathsRecursive(ctx, mount, basePath, strings.TrimSuffix(keyRelPath, "/"))
+			subPaths, err := c.listSecretPathsRecursive(ctx, mount, basePath, strings.TrimSuffix(keyRelPath, "/"), hidden)
 			if err != nil {
 				return nil, err
 			}
@@ -425,7 +500,8 @@ func (c *Client) IsDirectory(ctx context.Context, path string) (bool, error) {
 }
 
 // ListDirectories lists immediate subdirectories at a path (non-recursive)
-// Returns directory names (without trailing slash) and whether secrets exist at this level
+// Returns directory names (without trailing slash) and whether secrets exist at this level.
+// The trash is left out unless the path is inside it.
 func (c *Client) ListDirectories(ctx context.Context, path string) (dirs []string, hasSecrets bool, err error) {
 	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
 
@@ -443,13 +519,18 @@ func (c *Client) ListDirectories(ctx context.Context, path string) (dirs []strin
 		return nil, false, nil
 	}
 
+	hidden := c.hiddenTrashDir(path)
+
 	for _, key := range keys {
 		keyStr, ok := key.(string)
 		if !ok {
 			continue
 		}
 		if strings.HasSuffix(keyStr, "/") {
-			dirs = append(dirs, strings.TrimSuffix(keyStr, "/"))
+			dir := strings.TrimSuffix(keyStr, "/")
+			if dir != hidden {
+				dirs = append(dirs, dir)
+			}
 		} else {
 			hasSecrets = true
 		}
@@ -460,11 +541,13 @@ func (c *Client) ListDirectories(ctx context.Context, path string) (dirs []strin
 
 // SecretMetadata contains metadata about a secret
 type SecretMetadata struct {
-	CreatedTime    time.Time
-	UpdatedTime    time.Time
-	CurrentVersion int
-	MaxVersions    int
-	CustomMetadata map[string]string
+	CreatedTime        time.Time
+	UpdatedTime        time.Time
+	CurrentVersion     int
+	MaxVersions        int
+	CASRequired        bool
+	DeleteVersionAfter time.Duration
+	CustomMetadata     map[string]string
 }
 
 // GetMetadata retrieves metadata for a secret
@@ -494,6 +577,16 @@ func (c *Client) GetMetadata(ctx context.Context, path string) (*SecretMetadata,
 		}
 	}
 
+	if v, ok := secret.Data["cas_required"].(bool); ok {
+		metadata.CASRequired = v
+	}
+
+	if v, ok := secret.Data["delete_version_after"].(string); ok {
+		if d, err := time.ParseDuration(v); err == nil {
+			metadata.DeleteVersionAfter = d
+		}
+	}
+
 	if v, ok := secret.Data["created_time"].(string); ok {
 		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
 			metadata.CreatedTime = t
@@ -523,12 +616,47 @@ type VersionInfo struct {
 	Version     int
 	CreatedTime time.Time
 	Destroyed   bool
-	Deleted     bool
+	Deleted     bool      // Soft-deleted, the version can't be read until undeleted
+	DeletedTime time.Time // When the version was or will be deleted, zero if not scheduled
+
+	// OriginalTime is when the version was first written, if it was replayed
+	// from another secret by a copy or move with history. Zero otherwise.
+	OriginalTime time.Time
+
+	// Provenance is who wrote the version and why, if recorded by vlt. Nil otherwise.
+	Provenance *Provenance
+}
+
+// WrittenTime returns when the version was first written: OriginalTime if set, else CreatedTime
+func (v VersionInfo) WrittenTime() time.Time {
+	if !v.OriginalTime.IsZero() {
+		return v.OriginalTime
+	}
+	return v.CreatedTime
 }
 
 // GetVersionHistory retrieves the version history for a secret
 // Returns a list of VersionInfo sorted by version descending (newest first)
 func (c *Client) GetVersionHistory(ctx context.Context, path string) ([]VersionInfo, error) {
+	versions, err := c.getAllVersions(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only include non-destroyed, non-deleted versions
+	var result []VersionInfo
+	for _, v := range versions {
+		if !v.Destroyed && !v.Deleted {
+			result = append(result, v)
+		}
+	}
+
+	return result, nil
+}
+
+// getAllVersions retrieves all versions of a secret known to its metadata, including
+// deleted and destroyed ones, sorted by version descending (newest first)
+func (c *Client) getAllVersions(ctx context.Context, path string) ([]VersionInfo, error) {
 	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
 
 	secret, err := c.client.Logical().ReadWithContext(ctx, fmt.Sprintf("%s/metadata/%s", mount, secretPath))
@@ -545,6 +673,8 @@ func (c *Client) GetVersionHistory(ctx context.Context, path string) ([]VersionI
 		return nil, nil
 	}
 
+	custom, _ := secret.Data["custom_metadata"].(map[string]any)
+
 	var result []VersionInfo
 	for versionStr, versionData := range versions {
 		version, err := strconv.Atoi(versionStr)
@@ -565,13 +695,24 @@ func (c *Client) GetVersionHistory(ctx context.Context, path string) ([]VersionI
 			}
 			if dt, ok := vd["deletion_time"].(string); ok && dt != "" {
 				info.Deleted = true
+				if t, err := time.Parse(time.RFC3339Nano, dt); err == nil {
+					info.DeletedTime = t
+					// delete_version_after schedules deletions ahead of time
+					info.Deleted = !t.After(time.Now())
+				}
 			}
 		}
 
-		// Only include non-destroyed, non-deleted versions
-		if !info.Destroyed && !info.Deleted {
-			result = append(result, info)
+		if ot, ok := custom[historyCreatedKey(version)].(string); ok {
+			if t, err := time.Parse(time.RFC3339, ot); err == nil {
+				info.OriginalTime = t
+			}
+		}
+		if p, ok := custom[provenanceKey(version)].(string); ok {
+			info.Provenance = parseProvenance(p)
 		}
+
+		result = append(result, info)
 	}
 
 	// Sort by version descending (newest first)
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // DiffResult holds the comparison between two secret maps
@@ -16,6 +17,16 @@ type DiffResult struct {
 	OnlyInSecond []DiffEntry
 	Changed      []ChangedEntry
 	Unchanged    int
+
+	// Drift lists secrets whose version differs, if both sources have versions
+	Drift []VersionDrift
+}
+
+// VersionDrift is a secret that is at a different version in each source
+type VersionDrift struct {
+	Path          string
+	FirstVersion  int
+	SecondVersion int
 }
 
 // DiffEntry represents a key that exists only in one source
@@ -38,6 +49,53 @@ func (d *DiffResult) HasDifferences() bool {
 	return len(d.OnlyInFirst) > 0 || len(d.OnlyInSecond) > 0 || len(d.Changed) > 0
 }
 
+// CompareSecretVersions compares the versions of secrets, keyed by relative path.
+// Returns the secrets present in both whose versions differ, sorted by path.
+func CompareSecretVersions(versions1, versions2 map[string]int) []VersionDrift {
+	var drift []VersionDrift
+	for path, v1 := range versions1 {
+		if v2, ok := versions2[path]; ok && v1 != v2 {
+			drift = append(drift, VersionDrift{Path: path, FirstVersion: v1, SecondVersion: v2})
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		return drift[i].Path < drift[j].Path
+	})
+
+	return drift
+}
+
+// CurrentVersions returns the current version of every secret under a path, keyed by
+// path relative to it. A single secret is returned under the empty key.
+func (c *Client) CurrentVersions(ctx context.Context, path string) (map[string]int, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	fullPaths := map[string]string{"": path}
+	if len(secretPaths) > 0 {
+		fullPaths = make(map[string]string, len(secretPaths))
+		for _, relPath := range secretPaths {
+			fullPaths[relPath] = path + "/" + relPath
+		}
+	}
+
+	versions := make(map[string]int, len(fullPaths))
+	for relPath, fullPath := range fullPaths {
+		metadata, err := c.GetMetadata(ctx, fullPath)
+		if err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			versions[relPath] = metadata.CurrentVersion
+		}
+	}
+
+	return versions, nil
+}
+
 // CompareSecrets compares two flattened secret maps and returns the differences
 func CompareSecrets(secrets1, secrets2 map[string]any) *DiffResult {
 	result := &DiffResult{}
@@ -90,11 +148,13 @@ type VersionSpec struct {
 	ChangesAgo   int  // Negative offset for changes ago (e.g., @-2 means 2 changes ago)
 	IsPrev       bool // @prev alias
 	IsChangesAgo bool // True if using @-N syntax
+	At           time.Time
+	IsAt         bool // True if using @<time> syntax (e.g., @2024-01-30T14:00:00Z, @{2h ago})
 }
 
 // HasVersion returns true if any version specifier was provided
 func (v VersionSpec) HasVersion() bool {
-	return v.Version > 0 || v.IsPrev || v.IsChangesAgo
+	return v.Version > 0 || v.IsPrev || v.IsChangesAgo || v.IsAt
 }
 
 // ParseVersionedPath extracts path and version from "path@version" format
@@ -102,6 +162,7 @@ func (v VersionSpec) HasVersion() bool {
 //   - @N for specific version (single secrets only)
 //   - @prev for previous version
 //   - @-N for N changes ago (directories only, based on change timeline)
+//   - @<time> for the state at a point in time (see ParseTimeSpec)
 func ParseVersionedPath(path string) (string, VersionSpec) {
 	spec := VersionSpec{}
 
@@ -127,6 +188,13 @@ func ParseVersionedPath(path string) (string, VersionSpec) {
 				return basePath, spec
 			}
 		}
+
+		// Parse point in time
+		if t, err := ParseTimeSpec(versionStr); err == nil {
+			spec.At = t
+			spec.IsAt = true
+			return basePath, spec
+		}
 	}
 	return path, spec
 }
@@ -1,7 +1,9 @@
 package vault
 
 import (
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestParseVersionedPath(t *testing.T) {
@@ -59,6 +61,12 @@ func TestParseVersionedPath(t *testing.T) {
 			expectedPath: "secret/app@0",
 			expectedSpec: VersionSpec{},
 		},
+		{
+			name:         "timestamp",
+			input:        "secret/app@2024-01-30T14:00:00Z",
+			expectedPath: "secret/app",
+			expectedSpec: VersionSpec{IsAt: true, At: time.Date(2024, 1, 30, 14, 0, 0, 0, time.UTC)},
+		},
 		{
 			name:         "path with @ in name",
 			input:        "secret/email@domain.com",
@@ -90,6 +98,7 @@ func TestVersionSpecHasVersion(t *testing.T) {
 		{"version set", VersionSpec{Version: 3}, true},
 		{"prev set", VersionSpec{IsPrev: true}, true},
 		{"changes ago set", VersionSpec{IsChangesAgo: true, ChangesAgo: 2}, true},
+		{"time set", VersionSpec{IsAt: true, At: time.Now()}, true},
 	}
 
 	for _, tt := range tests {
@@ -201,3 +210,18 @@ func TestHashValue(t *testing.T) {
 		t.Error("different values produced same hash")
 	}
 }
+
+func TestCompareSecretVersions(t *testing.T) {
+	first := map[string]int{"api/key": 3, "db/password": 1, "removed": 2}
+	second := map[string]int{"api/key": 5, "db/password": 1, "added": 1}
+
+	got := CompareSecretVersions(first, second)
+	expected := []VersionDrift{{Path: "api/key", FirstVersion: 3, SecondVersion: 5}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("CompareSecretVersions() = %+v, want %+v", got, expected)
+	}
+
+	if drift := CompareSecretVersions(first, first); len(drift) != 0 {
+		t.Errorf("expected no drift comparing with itself, got %+v", drift)
+	}
+}
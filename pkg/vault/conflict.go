@@ -0,0 +1,132 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ConflictPolicy decides what a restore does with secrets that were modified after the
+// snapshot recorded them
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite"  // Restore the snapshot value anyway (default)
+	ConflictSkip      ConflictPolicy = "skip"       // Keep the secret as it is in Vault
+	ConflictFail      ConflictPolicy = "fail"       // Refuse the restore before changing anything
+	ConflictPrompt    ConflictPolicy = "prompt"     // Ask RestoreOptions.Prompt about each secret
+	ConflictNewerWins ConflictPolicy = "newer-wins" // Keep whichever was updated last
+)
+
+// ParseConflictPolicy parses a policy name as given to --on-conflict
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(s); p {
+	case ConflictOverwrite, ConflictSkip, ConflictFail, ConflictPrompt, ConflictNewerWins:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q: expected skip, overwrite, fail, prompt or newer-wins", s)
+}
+
+// RestoreConflict is a secret the restore would change that was modified after the
+// snapshot recorded it
+type RestoreConflict struct {
+	Path           string         // Relative to the restore target
+	Snapshot       SnapshotSecret // As recorded in the snapshot
+	Current        map[string]any // Data in Vault
+	CurrentVersion int
+	CurrentUpdated time.Time
+}
+
+// SnapshotNewer returns true if the snapshot holds the more recently updated value.
+// Snapshots from history hold an older version, and snapshots without update times
+// can't tell, so Vault wins for both.
+func (c RestoreConflict) SnapshotNewer() bool {
+	return c.Snapshot.Current == 0 && c.Snapshot.Updated.After(c.CurrentUpdated)
+}
+
+// ConflictError is returned by a restore with ConflictFail if any secret was modified
+// after the snapshot recorded it
+type ConflictError struct {
+	Conflicts []RestoreConflict
+}
+
+func (e *ConflictError) Error() string {
+	paths := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		paths[i] = conflict.Path
+	}
+	return fmt.Sprintf("%d secret(s) modified since the snapshot was taken: %s", len(paths), strings.Join(paths, ", "))
+}
+
+// modifiedSince returns true if a secret in Vault is no longer at the version the
+// snapshot recorded, or was updated after the recorded update time. Secrets the
+// snapshot has no version of, such as those read from medusa files, always are.
+func (s SnapshotSecret) modifiedSince(current *SecretMetadata) bool {
+	expected := s.ExpectedVersion()
+	if expected == 0 || current == nil || current.CurrentVersion != expected {
+		return true
+	}
+	// Snapshots from history record the update time of an older version
+	return s.Current == 0 && !s.Updated.IsZero() && current.UpdatedTime.After(s.Updated)
+}
+
+// restoreConflict returns the conflict a restore of secret over the current state in
+// Vault runs into, nil if the restore would not change it or it was not modified
+func restoreConflict(relPath string, secret SnapshotSecret, currentData map[string]any, current *SecretMetadata) *RestoreConflict {
+	unchanged := sameData(currentData, secret.SecretData()) && (secret.Metadata == nil || secret.Metadata.matches(current))
+	if unchanged || !secret.modifiedSince(current) {
+		return nil
+	}
+
+	conflict := &RestoreConflict{Path: relPath, Snapshot: secret, Current: currentData}
+	if current != nil {
+		conflict.CurrentVersion = current.CurrentVersion
+		conflict.CurrentUpdated = current.UpdatedTime
+	}
+	return conflict
+}
+
+// restoreConflicts returns the conflicts a restore of snapshot to targetPath runs into,
+// sorted by path. existing holds the relative paths of the secrets at the target.
+func (c *Client) restoreConflicts(ctx context.Context, snapshot *Snapshot, targetPath string, existing map[string]bool) ([]RestoreConflict, error) {
+	var conflicts []RestoreConflict
+	for _, relPath := range sortedSecretPaths(snapshot) {
+		if !existing[relPath] {
+			continue
+		}
+
+		fullPath := targetPath + "/" + relPath
+		current, err := c.GetMetadata(ctx, fullPath)
+		if err != nil {
+			return nil, err
+		}
+		currentData, err := c.ReadSecretRaw(ctx, fullPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if conflict := restoreConflict(relPath, snapshot.Secrets[relPath], currentData, current); conflict != nil {
+			conflicts = append(conflicts, *conflict)
+		}
+	}
+	return conflicts, nil
+}
+
+// overwrites returns true if the restore should write the snapshot over a conflicting
+// secret. decisions holds the answers to Prompt, by path.
+func (o RestoreOptions) overwrites(conflict *RestoreConflict, decisions map[string]bool) (bool, error) {
+	switch o.OnConflict {
+	case ConflictSkip:
+		return false, nil
+	case ConflictNewerWins:
+		return conflict.SnapshotNewer(), nil
+	case ConflictFail:
+		// Modified while the restore ran, after the conflicts were checked
+		return false, &ConflictError{Conflicts: []RestoreConflict{*conflict}}
+	case ConflictPrompt:
+		// Secrets modified after the prompts were answered are left alone
+		return decisions[conflict.Path], nil
+	}
+	return true, nil
+}
@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	for _, s := range []string{"skip", "overwrite", "fail", "prompt", "newer-wins"} {
+		if p, err := ParseConflictPolicy(s); err != nil || string(p) != s {
+			t.Errorf("ParseConflictPolicy(%q) = %q, %v", s, p, err)
+		}
+	}
+	if _, err := ParseConflictPolicy("merge"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
+
+func TestRestoreConflict(t *testing.T) {
+	recorded := time.Date(2024, 1, 30, 14, 0, 0, 0, time.UTC)
+	later := recorded.Add(time.Hour)
+	earlier := recorded.Add(-time.Hour)
+	snapshotData := map[string]any{"value": "old"}
+	changedData := map[string]any{"value": "new"}
+
+	tests := []struct {
+		name     string
+		secret   SnapshotSecret
+		current  map[string]any
+		metadata *SecretMetadata
+		conflict bool
+	}{
+		{
+			name:     "unchanged since the snapshot",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 3, Updated: recorded},
+			current:  snapshotData,
+			metadata: &SecretMetadata{CurrentVersion: 3, UpdatedTime: recorded},
+		},
+		{
+			name:     "written since the snapshot",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 3, Updated: recorded},
+			current:  changedData,
+			metadata: &SecretMetadata{CurrentVersion: 4, UpdatedTime: later},
+			conflict: true,
+		},
+		{
+			name:     "written since, back to the snapshot value",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 3, Updated: recorded},
+			current:  snapshotData,
+			metadata: &SecretMetadata{CurrentVersion: 4, UpdatedTime: later},
+		},
+		{
+			name:     "same version number, updated later",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 3, Updated: recorded},
+			current:  changedData,
+			metadata: &SecretMetadata{CurrentVersion: 3, UpdatedTime: later},
+			conflict: true,
+		},
+		{
+			name:     "same version number, updated earlier",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 3, Updated: recorded},
+			current:  changedData,
+			metadata: &SecretMetadata{CurrentVersion: 3, UpdatedTime: earlier},
+		},
+		{
+			name:     "settings changed since the snapshot",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 3, Updated: recorded, Metadata: &SnapshotMetadata{MaxVersions: 5}},
+			current:  snapshotData,
+			metadata: &SecretMetadata{CurrentVersion: 3, UpdatedTime: later, MaxVersions: 10},
+			conflict: true,
+		},
+		{
+			name:     "no recorded version",
+			secret:   SnapshotSecret{Data: snapshotData},
+			current:  changedData,
+			metadata: &SecretMetadata{CurrentVersion: 1, UpdatedTime: earlier},
+			conflict: true,
+		},
+		{
+			name:     "from history, still at the current version",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 2, Current: 5, Updated: earlier},
+			current:  changedData,
+			metadata: &SecretMetadata{CurrentVersion: 5, UpdatedTime: later},
+		},
+		{
+			name:     "from history, written since",
+			secret:   SnapshotSecret{Data: snapshotData, Version: 2, Current: 5, Updated: earlier},
+			current:  changedData,
+			metadata: &SecretMetadata{CurrentVersion: 6, UpdatedTime: later},
+			conflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conflict := restoreConflict("app", tt.secret, tt.current, tt.metadata)
+			if (conflict != nil) != tt.conflict {
+				t.Fatalf("restoreConflict() = %+v, want conflict %v", conflict, tt.conflict)
+			}
+			if conflict != nil && (conflict.Path != "app" || conflict.CurrentVersion != tt.metadata.CurrentVersion) {
+				t.Errorf("unexpected conflict %+v", conflict)
+			}
+		})
+	}
+}
+
+func TestConflictOverwrites(t *testing.T) {
+	recorded := time.Date(2024, 1, 30, 14, 0, 0, 0, time.UTC)
+	snapshotNewer := &RestoreConflict{Path: "a", Snapshot: SnapshotSecret{Version: 3, Updated: recorded}, CurrentVersion: 1, CurrentUpdated: recorded.Add(-time.Hour)}
+	vaultNewer := &RestoreConflict{Path: "b", Snapshot: SnapshotSecret{Version: 3, Updated: recorded}, CurrentVersion: 4, CurrentUpdated: recorded.Add(time.Hour)}
+	fromHistory := &RestoreConflict{Path: "c", Snapshot: SnapshotSecret{Version: 1, Current: 3, Updated: recorded}, CurrentVersion: 4, CurrentUpdated: recorded.Add(-time.Hour)}
+
+	tests := []struct {
+		policy   ConflictPolicy
+		conflict *RestoreConflict
+		expected bool
+	}{
+		{"", vaultNewer, true},
+		{ConflictOverwrite, vaultNewer, true},
+		{ConflictSkip, snapshotNewer, false},
+		{ConflictNewerWins, snapshotNewer, true},
+		{ConflictNewerWins, vaultNewer, false},
+		{ConflictNewerWins, fromHistory, false},
+		{ConflictPrompt, snapshotNewer, true},
+		{ConflictPrompt, vaultNewer, false},
+		{ConflictPrompt, fromHistory, false},
+	}
+
+	decisions := map[string]bool{"a": true, "b": false}
+	for _, tt := range tests {
+		overwrite, err := RestoreOptions{OnConflict: tt.policy}.overwrites(tt.conflict, decisions)
+		if err != nil {
+			t.Fatalf("%s: overwrites(%s) failed: %v", tt.policy, tt.conflict.Path, err)
+		}
+		if overwrite != tt.expected {
+			t.Errorf("%s: overwrites(%s) = %v, want %v", tt.policy, tt.conflict.Path, overwrite, tt.expected)
+		}
+	}
+
+	_, err := RestoreOptions{OnConflict: ConflictFail}.overwrites(vaultNewer, nil)
+	var conflictErr *ConflictError
+	if !errors.As(err, &conflictErr) || len(conflictErr.Conflicts) != 1 {
+		t.Errorf("expected a ConflictError with fail, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"hours", "24h", 24 * time.Hour, false},
+		{"minutes", "90m", 90 * time.Minute, false},
+		{"days", "30d", 30 * 24 * time.Hour, false},
+		{"weeks", "2w", 14 * 24 * time.Hour, false},
+		{"days and hours", "1d12h", 36 * time.Hour, false},
+		{"weeks and days", "1w2d", 9 * 24 * time.Hour, false},
+		{"empty", "", 0, true},
+		{"no number", "d", 0, true},
+		{"garbage", "soon", 0, true},
+		{"negative days", "-3d", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDuration(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ParseDuration(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
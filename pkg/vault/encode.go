@@ -0,0 +1,636 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputFormat is a file format secrets can be printed or exported in
+type OutputFormat string
+
+const (
+	OutputYAML       OutputFormat = "yaml"
+	OutputJSON       OutputFormat = "json"
+	OutputDotenv     OutputFormat = "dotenv"
+	OutputProperties OutputFormat = "properties"
+	OutputTOML       OutputFormat = "toml"
+	OutputHCL        OutputFormat = "hcl"
+)
+
+// ParseOutputFormat parses a format name as given to --format
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	switch f := OutputFormat(strings.ToLower(s)); f {
+	case OutputYAML, OutputJSON, OutputDotenv, OutputProperties, OutputTOML, OutputHCL:
+		return f, nil
+	case "yml":
+		return OutputYAML, nil
+	case "env":
+		return OutputDotenv, nil
+	}
+	return "", fmt.Errorf("unknown format %q: expected yaml, json, dotenv, properties, toml or hcl", s)
+}
+
+// Extension returns the usual file extension of the format
+func (f OutputFormat) Extension() string {
+	if f == OutputDotenv {
+		return ".env"
+	}
+	return "." + string(f)
+}
+
+// flat returns true if the format only holds key-value pairs
+func (f OutputFormat) flat() bool {
+	return f == OutputDotenv || f == OutputProperties
+}
+
+// KeyStyle is how nested keys are named in the output
+type KeyStyle string
+
+const (
+	KeysNested     KeyStyle = "nested"      // Keep the nesting, for formats that support it
+	KeysDot        KeyStyle = "dot"         // db.password
+	KeysUnderscore KeyStyle = "underscore"  // db_password
+	KeysUpperSnake KeyStyle = "upper-snake" // DB_PASSWORD, with camelCase split: apiKey -> API_KEY
+)
+
+// ParseKeyStyle parses a key style as given to --key-style
+func ParseKeyStyle(s string) (KeyStyle, error) {
+	switch k := KeyStyle(strings.ReplaceAll(strings.ToLower(s), "_", "-")); k {
+	case KeysNested, KeysDot, KeysUnderscore, KeysUpperSnake:
+		return k, nil
+	}
+	return "", fmt.Errorf("unknown key style %q: expected nested, dot, underscore or upper-snake", s)
+}
+
+// EncodeOptions configures how secrets are encoded
+type EncodeOptions struct {
+	Format OutputFormat
+
+	// Keys defaults to upper-snake for dotenv, dot for properties and nested otherwise
+	Keys KeyStyle
+
+	// Prefix is prepended to every flattened key, e.g. "APP_"
+	Prefix string
+}
+
+// Encode writes secrets, as returned by Get or Export, in a file format. Values that
+// the format can't represent, such as lists in dotenv files, are reported as errors
+// naming the key.
+func Encode(secrets map[string]any, opts EncodeOptions) ([]byte, error) {
+	format := opts.Format
+	if format == "" {
+		format = OutputYAML
+	}
+
+	keys := opts.Keys
+	if keys == "" {
+		switch format {
+		case OutputDotenv:
+			keys = KeysUpperSnake
+		case OutputProperties:
+			keys = KeysDot
+		default:
+			keys = KeysNested
+		}
+	}
+	if keys == KeysNested && format.flat() {
+		return nil, fmt.Errorf("%s files can't hold nested keys, use a dot, underscore or upper-snake key style", format)
+	}
+	if keys == KeysNested && opts.Prefix != "" {
+		return nil, fmt.Errorf("a key prefix requires flattened keys, use a dot, underscore or upper-snake key style")
+	}
+
+	data := secrets
+	if keys != KeysNested {
+		var err error
+		if data, err = flattenKeys(secrets, keys, opts.Prefix); err != nil {
+			return nil, err
+		}
+	}
+
+	switch format {
+	case OutputYAML:
+		out, err := yaml.Marshal(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+		}
+		return out, nil
+	case OutputJSON:
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return append(out, '\n'), nil
+	case OutputDotenv:
+		return encodeDotenv(data)
+	case OutputProperties:
+		return encodeProperties(data)
+	case OutputTOML:
+		return encodeTOML(data)
+	case OutputHCL:
+		return encodeHCL(data)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+// EncodeValue writes a single value, as returned by GetValue, in a file format. Maps are
+// encoded like Encode does. Strings are printed as plain text for yaml, dotenv and
+// properties, which have no syntax for a value without a key, and quoted for json, toml
+// and hcl, so the output parses in that format.
+func EncodeValue(value any, key string, opts EncodeOptions) ([]byte, error) {
+	if m, ok := value.(map[string]any); ok {
+		return Encode(m, opts)
+	}
+
+	format := opts.Format
+	if format == "" {
+		format = OutputYAML
+	}
+
+	var s string
+	var err error
+	switch format {
+	case OutputJSON:
+		out, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal JSON: %w", err)
+		}
+		return append(out, '\n'), nil
+	case OutputTOML:
+		s, err = tomlValue(value, key)
+	case OutputHCL:
+		s, err = hclValue(value, key, "")
+	case OutputYAML, OutputDotenv, OutputProperties:
+		text, ok := scalarString(value)
+		switch {
+		case ok:
+			s = text
+		case format == OutputYAML:
+			out, err := yaml.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+			}
+			return out, nil
+		default:
+			return nil, fmt.Errorf("key %s holds %s, which %s files can't represent", key, describeValue(value), format)
+		}
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s + "\n"), nil
+}
+
+// flattenKeys flattens nested maps into keys named in a style. Keys that end up with
+// the same name are reported as an error.
+func flattenKeys(secrets map[string]any, style KeyStyle, prefix string) (map[string]any, error) {
+	result := make(map[string]any)
+	sources := make(map[string]string)
+
+	var walk func(m map[string]any, path []string) error
+	walk = func(m map[string]any, path []string) error {
+		for _, k := range sortedKeys(m) {
+			segments := append(path[:len(path):len(path)], k)
+			if nested, ok := m[k].(map[string]any); ok {
+				if err := walk(nested, segments); err != nil {
+					return err
+				}
+				continue
+			}
+
+			name := prefix + styleKey(segments, style)
+			source := strings.Join(segments, ".")
+			if other, ok := sources[name]; ok {
+				return fmt.Errorf("keys %s and %s both become %s", other, source, name)
+			}
+			sources[name] = source
+			result[name] = m[k]
+		}
+		return nil
+	}
+
+	if err := walk(secrets, nil); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// styleKey names a flattened key
+func styleKey(segments []string, style KeyStyle) string {
+	switch style {
+	case KeysUnderscore:
+		return strings.Join(segments, "_")
+	case KeysUpperSnake:
+		upper := make([]string, len(segments))
+		for i, s := range segments {
+			upper[i] = upperSnake(s)
+		}
+		return strings.Join(upper, "_")
+	}
+	return strings.Join(segments, ".")
+}
+
+// upperSnake converts a key to UPPER_SNAKE_CASE: camelCase words are split, and
+// characters other than letters and digits become underscores
+func upperSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	underscore := func() {
+		if b.Len() > 0 && !strings.HasSuffix(b.String(), "_") {
+			b.WriteByte('_')
+		}
+	}
+
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			underscore()
+			continue
+		}
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// apiKey -> API_KEY, URLPath -> URL_PATH
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				underscore()
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scalarString formats a string, number or boolean as text, false for other values
+func scalarString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	}
+	return "", false
+}
+
+// describeValue names the kind of a value for errors
+func describeValue(value any) string {
+	switch value.(type) {
+	case nil:
+		return "a null value"
+	case []any:
+		return "a list"
+	case map[string]any:
+		return "a map"
+	}
+	return fmt.Sprintf("a value of type %T", value)
+}
+
+// dotenvName is a variable name shells and docker-compose accept
+var dotenvName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// dotenvBare matches values that need no quoting
+var dotenvBare = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,-]*$`)
+
+// encodeDotenv writes KEY=value lines. Values are single-quoted when they contain
+// special characters, and double-quoted with backslash escapes when they contain
+// newlines or single quotes.
+func encodeDotenv(data map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(data) {
+		if !dotenvName.MatchString(k) {
+			return nil, fmt.Errorf("key %q is not a valid dotenv variable name: only letters, digits and underscores are allowed, not starting with a digit", k)
+		}
+		value, ok := scalarString(data[k])
+		if !ok {
+			return nil, fmt.Errorf("key %s holds %s, which dotenv files can't represent", k, describeValue(data[k]))
+		}
+
+		switch {
+		case dotenvBare.MatchString(value):
+			fmt.Fprintf(&buf, "%s=%s\n", k, value)
+		case !strings.ContainsAny(value, "'\n\r"):
+			fmt.Fprintf(&buf, "%s='%s'\n", k, value)
+		default:
+			r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`", "\n", `\n`, "\r", `\r`)
+			fmt.Fprintf(&buf, "%s=\"%s\"\n", k, r.Replace(value))
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeProperties writes a Java .properties file, escaped to be read with
+// Properties.load in ISO-8859-1
+func encodeProperties(data map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(data) {
+		value, ok := scalarString(data[k])
+		if !ok {
+			return nil, fmt.Errorf("key %s holds %s, which properties files can't represent", k, describeValue(data[k]))
+		}
+		buf.WriteString(escapeProperty(k, true))
+		buf.WriteByte('=')
+		buf.WriteString(escapeProperty(value, false))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// escapeProperty escapes a properties key or value. Characters outside printable
+// ASCII are written as \uXXXX.
+func escapeProperty(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == '\f':
+			b.WriteString(`\f`)
+		case r == ' ' && (key || i == 0):
+			b.WriteString(`\ `)
+		case key && strings.ContainsRune("=:#!", r):
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case !key && i == 0 && (r == '#' || r == '!'):
+			// Not a comment marker after '=', escaped anyway for readers that trim
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < 0x20 || r > 0x7e:
+			for _, unit := range utf16Units(r) {
+				fmt.Fprintf(&b, `\u%04X`, unit)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// utf16Units returns the UTF-16 code units of a rune, a surrogate pair above U+FFFF
+func utf16Units(r rune) []rune {
+	if r == utf8.RuneError || r <= 0xffff {
+		return []rune{r}
+	}
+	r -= 0x10000
+	return []rune{0xd800 + (r>>10)&0x3ff, 0xdc00 + r&0x3ff}
+}
+
+// tomlBareKey matches keys that need no quoting
+var tomlBareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// encodeTOML writes a TOML document, with nested maps as tables
+func encodeTOML(data map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeTOMLTable(&buf, nil, data); err != nil {
+		return nil, err
+	}
+	return bytes.TrimLeft(buf.Bytes(), "\n"), nil
+}
+
+func writeTOMLTable(buf *bytes.Buffer, path []string, table map[string]any) error {
+	keys := sortedKeys(table)
+
+	// Values come before subtables, which would otherwise claim them
+	var values, tables []string
+	for _, k := range keys {
+		if _, ok := table[k].(map[string]any); ok {
+			tables = append(tables, k)
+		} else {
+			values = append(values, k)
+		}
+	}
+
+	if len(path) > 0 && (len(values) > 0 || len(tables) == 0) {
+		quoted := make([]string, len(path))
+		for i, p := range path {
+			quoted[i] = tomlKey(p)
+		}
+		fmt.Fprintf(buf, "\n[%s]\n", strings.Join(quoted, "."))
+	}
+
+	for _, k := range values {
+		keyPath := strings.Join(append(path[:len(path):len(path)], k), ".")
+		value, err := tomlValue(table[k], keyPath)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s = %s\n", tomlKey(k), value)
+	}
+
+	for _, k := range tables {
+		if err := writeTOMLTable(buf, append(path[:len(path):len(path)], k), table[k].(map[string]any)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func tomlKey(k string) string {
+	if tomlBareKey.MatchString(k) {
+		return k
+	}
+	return tomlString(k)
+}
+
+// tomlValue formats a value inline: lists as arrays and maps as inline tables
+func tomlValue(value any, keyPath string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", fmt.Errorf("key %s holds a null value, which TOML can't represent", keyPath)
+	case string:
+		return tomlString(v), nil
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "nan", nil
+		case math.IsInf(v, 1):
+			return "inf", nil
+		case math.IsInf(v, -1):
+			return "-inf", nil
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := tomlValue(item, fmt.Sprintf("%s[%d]", keyPath, i))
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]any:
+		items := make([]string, 0, len(v))
+		for _, k := range sortedKeys(v) {
+			s, err := tomlValue(v[k], keyPath+"."+k)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, tomlKey(k)+" = "+s)
+		}
+		return "{" + strings.Join(items, ", ") + "}", nil
+	}
+
+	s, ok := scalarString(value)
+	if !ok {
+		return "", fmt.Errorf("key %s holds %s, which TOML can't represent", keyPath, describeValue(value))
+	}
+	return s, nil
+}
+
+// tomlString writes a basic string, with control characters escaped
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// hclIdentifier matches keys that can be written without quotes
+var hclIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// encodeHCL writes an HCL body of attributes, with nested maps as object values
+func encodeHCL(data map[string]any) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, k := range sortedKeys(data) {
+		if !hclIdentifier.MatchString(k) {
+			return nil, fmt.Errorf("key %q is not a valid HCL attribute name: only letters, digits, underscores and dashes are allowed, starting with a letter or underscore", k)
+		}
+		value, err := hclValue(data[k], k, "")
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%s = %s\n", k, value)
+	}
+	return buf.Bytes(), nil
+}
+
+func hclValue(value any, keyPath, indent string) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "null", nil
+	case string:
+		return hclString(v), nil
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			s, err := hclValue(item, fmt.Sprintf("%s[%d]", keyPath, i), indent)
+			if err != nil {
+				return "", err
+			}
+			items[i] = s
+		}
+		return "[" + strings.Join(items, ", ") + "]", nil
+	case map[string]any:
+		if len(v) == 0 {
+			return "{}", nil
+		}
+		var b strings.Builder
+		b.WriteString("{\n")
+		for _, k := range sortedKeys(v) {
+			s, err := hclValue(v[k], keyPath+"."+k, indent+"  ")
+			if err != nil {
+				return "", err
+			}
+			key := k
+			if !hclIdentifier.MatchString(k) {
+				key = hclString(k)
+			}
+			fmt.Fprintf(&b, "%s  %s = %s\n", indent, key, s)
+		}
+		b.WriteString(indent + "}")
+		return b.String(), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return "", fmt.Errorf("key %s holds %v, which HCL can't represent", keyPath, v)
+		}
+	}
+
+	s, ok := scalarString(value)
+	if !ok {
+		return "", fmt.Errorf("key %s holds %s, which HCL can't represent", keyPath, describeValue(value))
+	}
+	return s, nil
+}
+
+// hclString writes a quoted string, with template sequences escaped so values are
+// taken literally
+func hclString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			b.WriteRune(r)
+			b.WriteRune(r)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04X`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
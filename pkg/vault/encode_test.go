@@ -0,0 +1,266 @@
+package vault
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	for _, s := range []string{"yaml", "json", "dotenv", "properties", "toml", "hcl", "JSON"} {
+		if _, err := ParseOutputFormat(s); err != nil {
+			t.Errorf("ParseOutputFormat(%q) failed: %v", s, err)
+		}
+	}
+	if f, _ := ParseOutputFormat("env"); f != OutputDotenv || f.Extension() != ".env" {
+		t.Errorf("env = %q (%s), want dotenv (.env)", f, f.Extension())
+	}
+	if _, err := ParseOutputFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if k, err := ParseKeyStyle("UPPER_SNAKE"); err != nil || k != KeysUpperSnake {
+		t.Errorf("ParseKeyStyle(UPPER_SNAKE) = %q, %v", k, err)
+	}
+}
+
+func TestUpperSnake(t *testing.T) {
+	tests := map[string]string{
+		"password":  "PASSWORD",
+		"apiKey":    "API_KEY",
+		"clientID":  "CLIENT_ID",
+		"URLPath":   "URL_PATH",
+		"oauth2Key": "OAUTH2_KEY",
+		"db-host":   "DB_HOST",
+		"a.b  c":    "A_B_C",
+		"_private":  "PRIVATE",
+	}
+	for in, expected := range tests {
+		if got := upperSnake(in); got != expected {
+			t.Errorf("upperSnake(%q) = %q, want %q", in, got, expected)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	secrets := map[string]any{
+		"db": map[string]any{
+			"password": "p@ss word",
+			"port":     json.Number("5432"),
+		},
+		"apiKey": "abc",
+	}
+
+	tests := []struct {
+		name     string
+		opts     EncodeOptions
+		expected string
+	}{
+		{
+			name:     "yaml by default",
+			opts:     EncodeOptions{},
+			expected: "apiKey: abc\ndb:\n    password: p@ss word\n    port: \"5432\"\n",
+		},
+		{
+			name:     "json",
+			opts:     EncodeOptions{Format: OutputJSON},
+			expected: "{\n  \"apiKey\": \"abc\",\n  \"db\": {\n    \"password\": \"p@ss word\",\n    \"port\": 5432\n  }\n}\n",
+		},
+		{
+			name:     "json with dotted keys",
+			opts:     EncodeOptions{Format: OutputJSON, Keys: KeysDot},
+			expected: "{\n  \"apiKey\": \"abc\",\n  \"db.password\": \"p@ss word\",\n  \"db.port\": 5432\n}\n",
+		},
+		{
+			name:     "dotenv defaults to upper snake",
+			opts:     EncodeOptions{Format: OutputDotenv},
+			expected: "API_KEY=abc\nDB_PASSWORD='p@ss word'\nDB_PORT=5432\n",
+		},
+		{
+			name:     "dotenv with prefix and underscores",
+			opts:     EncodeOptions{Format: OutputDotenv, Keys: KeysUnderscore, Prefix: "APP_"},
+			expected: "APP_apiKey=abc\nAPP_db_password='p@ss word'\nAPP_db_port=5432\n",
+		},
+		{
+			name:     "properties defaults to dots",
+			opts:     EncodeOptions{Format: OutputProperties},
+			expected: "apiKey=abc\ndb.password=p@ss word\ndb.port=5432\n",
+		},
+		{
+			name:     "toml",
+			opts:     EncodeOptions{Format: OutputTOML},
+			expected: "apiKey = \"abc\"\n\n[db]\npassword = \"p@ss word\"\nport = 5432\n",
+		},
+		{
+			name:     "toml with dotted keys",
+			opts:     EncodeOptions{Format: OutputTOML, Keys: KeysDot},
+			expected: "apiKey = \"abc\"\n\"db.password\" = \"p@ss word\"\n\"db.port\" = 5432\n",
+		},
+		{
+			name:     "hcl",
+			opts:     EncodeOptions{Format: OutputHCL},
+			expected: "apiKey = \"abc\"\ndb = {\n  password = \"p@ss word\"\n  port = 5432\n}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := Encode(secrets, tt.opts)
+			if err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Encode() =\n%s\nwant\n%s", data, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEncodeEscaping(t *testing.T) {
+	value := "line1\nline2 \"quoted\" \\ $HOME ${x} it's"
+	secrets := map[string]any{"key": value}
+
+	tests := []struct {
+		format   OutputFormat
+		expected string
+	}{
+		{OutputDotenv, `KEY="line1\nline2 \"quoted\" \\ \$HOME \${x} it's"` + "\n"},
+		{OutputProperties, `key=line1\nline2 "quoted" \\ $HOME ${x} it's` + "\n"},
+		{OutputTOML, `key = "line1\nline2 \"quoted\" \\ $HOME ${x} it's"` + "\n"},
+		{OutputHCL, `key = "line1\nline2 \"quoted\" \\ $HOME $${x} it's"` + "\n"},
+		{OutputJSON, "{\n  \"key\": \"line1\\nline2 \\\"quoted\\\" \\\\ $HOME ${x} it's\"\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			data, err := Encode(secrets, EncodeOptions{Format: tt.format})
+			if err != nil {
+				t.Fatalf("Encode() failed: %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("Encode() = %s, want %s", data, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEncodeProperties(t *testing.T) {
+	secrets := map[string]any{
+		"a key=x": " leading",
+		"unicode": "grüß 😀",
+		"comment": "#not a comment",
+	}
+	data, err := Encode(secrets, EncodeOptions{Format: OutputProperties})
+	if err != nil {
+		t.Fatalf("Encode() failed: %v", err)
+	}
+	expected := "a\\ key\\=x=\\ leading\n" +
+		"comment=\\#not a comment\n" +
+		"unicode=gr\\u00FC\\u00DF \\uD83D\\uDE00\n"
+	if string(data) != expected {
+		t.Errorf("Encode() =\n%s\nwant\n%s", data, expected)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]any
+		opts    EncodeOptions
+		errMsg  string
+	}{
+		{
+			name:    "list in dotenv",
+			secrets: map[string]any{"hosts": []any{"a", "b"}},
+			opts:    EncodeOptions{Format: OutputDotenv},
+			errMsg:  "HOSTS holds a list, which dotenv files can't represent",
+		},
+		{
+			name:    "null in properties",
+			secrets: map[string]any{"a": nil},
+			opts:    EncodeOptions{Format: OutputProperties},
+			errMsg:  "a holds a null value",
+		},
+		{
+			name:    "null in toml",
+			secrets: map[string]any{"db": map[string]any{"hosts": []any{"a", nil}}},
+			opts:    EncodeOptions{Format: OutputTOML},
+			errMsg:  "db.hosts[1] holds a null value",
+		},
+		{
+			name:    "invalid dotenv name",
+			secrets: map[string]any{"db": map[string]any{"password": "x"}},
+			opts:    EncodeOptions{Format: OutputDotenv, Keys: KeysDot},
+			errMsg:  "not a valid dotenv variable name",
+		},
+		{
+			name:    "invalid hcl attribute",
+			secrets: map[string]any{"1st": "x"},
+			opts:    EncodeOptions{Format: OutputHCL},
+			errMsg:  "not a valid HCL attribute name",
+		},
+		{
+			name:    "nested keys in a flat format",
+			secrets: map[string]any{"a": "x"},
+			opts:    EncodeOptions{Format: OutputProperties, Keys: KeysNested},
+			errMsg:  "can't hold nested keys",
+		},
+		{
+			name:    "prefix without flattening",
+			secrets: map[string]any{"a": "x"},
+			opts:    EncodeOptions{Format: OutputJSON, Prefix: "APP_"},
+			errMsg:  "requires flattened keys",
+		},
+		{
+			name:    "keys colliding after renaming",
+			secrets: map[string]any{"apiKey": "x", "api_key": "y"},
+			opts:    EncodeOptions{Format: OutputDotenv},
+			errMsg:  "keys apiKey and api_key both become API_KEY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Encode(tt.secrets, tt.opts)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Encode() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    any
+		format   OutputFormat
+		expected string
+	}{
+		{"yaml string", "p@ss \"word\"", OutputYAML, "p@ss \"word\"\n"},
+		{"yaml number", json.Number("5432"), OutputYAML, "5432\n"},
+		{"yaml list", []any{"a", "b"}, OutputYAML, "- a\n- b\n"},
+		{"json string", "p@ss \"word\"", OutputJSON, "\"p@ss \\\"word\\\"\"\n"},
+		{"json number", json.Number("5432"), OutputJSON, "5432\n"},
+		{"json null", nil, OutputJSON, "null\n"},
+		{"dotenv string", "line1\nline2", OutputDotenv, "line1\nline2\n"},
+		{"toml string", "a\"b", OutputTOML, "\"a\\\"b\"\n"},
+		{"toml list", []any{"a", true}, OutputTOML, "[\"a\", true]\n"},
+		{"hcl template", "${x}", OutputHCL, "\"$${x}\"\n"},
+		{"map", map[string]any{"apiKey": "x"}, OutputJSON, "{\n  \"apiKey\": \"x\"\n}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeValue(tt.value, "key", EncodeOptions{Format: tt.format})
+			if err != nil {
+				t.Fatalf("EncodeValue() error = %v", err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("EncodeValue() = %q, want %q", data, tt.expected)
+			}
+		})
+	}
+
+	if _, err := EncodeValue([]any{"a"}, "hosts", EncodeOptions{Format: OutputProperties}); err == nil || !strings.Contains(err.Error(), "hosts holds a list, which properties files can't represent") {
+		t.Errorf("EncodeValue(list, properties) error = %v", err)
+	}
+}
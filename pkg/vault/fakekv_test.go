@@ -0,0 +1,91 @@
+package vault
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethanadams/vlt/pkg/config"
+)
+
+// fakeVersion is a version of a secret served by newFakeKV
+type fakeVersion struct {
+	created time.Time
+	data    map[string]any
+}
+
+// newFakeKV serves LIST, metadata and read requests for a KV v2 mount named secret,
+// with the trash at secret/.vlt-trash. Secrets are keyed by their path within the
+// mount, with their versions oldest first, and custom metadata by the same paths.
+func newFakeKV(t *testing.T, secrets map[string][]fakeVersion, custom map[string]map[string]string) *Client {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		reply := func(data map[string]any) {
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+		}
+
+		if dir, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/metadata"); ok && r.URL.Query().Get("list") == "true" {
+			// The client may drop the trailing slash of listed directories
+			if dir = strings.Trim(dir, "/"); dir != "" {
+				dir += "/"
+			}
+			seen := map[string]bool{}
+			var keys []string
+			for path := range secrets {
+				rest, ok := strings.CutPrefix(path, dir)
+				if !ok {
+					continue
+				}
+				if i := strings.Index(rest, "/"); i >= 0 {
+					rest = rest[:i+1]
+				}
+				if !seen[rest] {
+					seen[rest] = true
+					keys = append(keys, rest)
+				}
+			}
+			if len(keys) > 0 {
+				sort.Strings(keys)
+				reply(map[string]any{"keys": keys})
+				return
+			}
+		} else if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/metadata/"); ok && secrets[path] != nil {
+			versions := map[string]any{}
+			for i, v := range secrets[path] {
+				versions[strconv.Itoa(i+1)] = map[string]any{
+					"created_time":  v.created.Format(time.RFC3339Nano),
+					"deletion_time": "",
+					"destroyed":     false,
+				}
+			}
+			reply(map[string]any{"current_version": len(secrets[path]), "versions": versions, "custom_metadata": custom[path]})
+			return
+		} else if path, ok := strings.CutPrefix(r.URL.Path, "/v1/secret/data/"); ok && secrets[path] != nil {
+			versions := secrets[path]
+			version := len(versions)
+			if v := r.URL.Query().Get("version"); v != "" {
+				version, _ = strconv.Atoi(v)
+			}
+			if version >= 1 && version <= len(versions) {
+				reply(map[string]any{"data": versions[version-1].data})
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{}})
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(&config.Config{VaultAddr: server.URL, VaultToken: "token", TrashPath: "secret/.vlt-trash"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	return client
+}
@@ -4,12 +4,14 @@ package vault_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
 
 	"github.com/ethanadams/vlt/pkg/config"
 	"github.com/ethanadams/vlt/pkg/vault"
+	"github.com/hashicorp/vault/api"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
 )
@@ -441,6 +443,331 @@ func TestIntegration_Restore(t *testing.T) {
 	}
 }
 
+func TestIntegration_RestoreChangesAgo(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/undo/key1", "original")
+	_ = client.Add(ctx, "secret/undo/key2", "original")
+	_ = client.Update(ctx, "secret/undo/key1", "modified")
+	_ = client.Update(ctx, "secret/undo/key2", "modified")
+
+	// Undo the last change only
+	snapshot, err := client.CreateSnapshotAtChangesAgo(ctx, "secret/undo", 1)
+	if err != nil {
+		t.Fatalf("CreateSnapshotAtChangesAgo failed: %v", err)
+	}
+
+	result, err := client.RestoreSnapshot(ctx, snapshot, "secret/undo", vault.RestoreOptions{OnConflict: vault.ConflictSkip, DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	if len(result.Updated) != 1 || len(result.Skipped) != 0 {
+		t.Errorf("expected 1 updated and none skipped, got %+v", result)
+	}
+
+	key1, _ := client.Get(ctx, "secret/undo/key1")
+	if key1["value"] != "modified" {
+		t.Errorf("expected key1 'modified', got %v", key1["value"])
+	}
+	key2, _ := client.Get(ctx, "secret/undo/key2")
+	if key2["value"] != "original" {
+		t.Errorf("expected key2 'original', got %v", key2["value"])
+	}
+}
+
+func TestIntegration_RestoreChangesAgoCreated(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/undo/key1", "original")
+	_ = client.Update(ctx, "secret/undo/key1", "modified")
+	_ = client.Add(ctx, "secret/undo/key2", "new")
+
+	// The creation of key2 is the last change
+	snapshot, err := client.CreateSnapshotAtChangesAgo(ctx, "secret/undo", 1)
+	if err != nil {
+		t.Fatalf("CreateSnapshotAtChangesAgo failed: %v", err)
+	}
+	if _, ok := snapshot.Secrets["key2"]; ok || len(snapshot.Secrets) != 1 {
+		t.Fatalf("expected only key1 in the snapshot, got %v", snapshot.Secrets)
+	}
+
+	// --no-delete keeps the secret created since
+	result, err := client.RestoreSnapshot(ctx, snapshot, "secret/undo", vault.RestoreOptions{})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if len(result.Deleted) != 0 {
+		t.Errorf("expected nothing deleted without DeleteExtra, got %v", result.Deleted)
+	}
+
+	result, err = client.RestoreSnapshot(ctx, snapshot, "secret/undo", vault.RestoreOptions{DeleteExtra: true})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if len(result.Deleted) != 1 || result.Deleted[0] != "key2" {
+		t.Errorf("expected key2 deleted, got %v", result.Deleted)
+	}
+
+	key1, _ := client.Get(ctx, "secret/undo/key1")
+	if key1["value"] != "modified" {
+		t.Errorf("expected key1 'modified', got %v", key1["value"])
+	}
+}
+
+func TestIntegration_RestoreChangesAgoRemovedVersion(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	for _, opts := range []vault.PruneOptions{{Keep: 1}, {Keep: 1, Destroy: true}} {
+		dir := "secret/deleted"
+		if opts.Destroy {
+			dir = "secret/destroyed"
+		}
+		path := dir + "/key"
+
+		_ = client.Add(ctx, path, "v1")
+		_ = client.Update(ctx, path, "v2")
+		if _, err := client.PruneVersions(ctx, path, opts); err != nil {
+			t.Fatalf("PruneVersions failed: %v", err)
+		}
+
+		// Undoing v2 needs v1, which can't be read anymore
+		if snapshot, err := client.CreateSnapshotAtChangesAgo(ctx, dir, 1); err == nil {
+			t.Errorf("CreateSnapshotAtChangesAgo succeeded without v1 of %s: %v", path, snapshot.Secrets)
+		}
+		if _, err := client.GetStateAtChangesAgo(ctx, dir, 1); err == nil {
+			t.Errorf("GetStateAtChangesAgo succeeded without v1 of %s", path)
+		}
+
+		secret, _ := client.Get(ctx, path)
+		if secret["value"] != "v2" {
+			t.Errorf("expected %s to be left at v2, got %v", path, secret["value"])
+		}
+	}
+}
+
+func TestIntegration_RestoreAllVersions(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/orig/key", "v1")
+	_ = client.Update(ctx, "secret/orig/key", "v2")
+	_ = client.Update(ctx, "secret/orig/key", "v3")
+	if _, err := client.PruneVersions(ctx, "secret/orig/key", vault.PruneOptions{Keep: 2}); err != nil {
+		t.Fatalf("PruneVersions failed: %v", err)
+	}
+
+	snapshot, err := client.CreateSnapshotWithOptions(ctx, "secret/orig", vault.SnapshotOptions{AllVersions: true})
+	if err != nil {
+		t.Fatalf("CreateSnapshotWithOptions failed: %v", err)
+	}
+
+	versions := snapshot.Secrets["key"].Versions
+	if len(versions) != 3 || !versions[0].Deleted || versions[2].Data["value"] != "v3" {
+		t.Fatalf("unexpected snapshot versions: %+v", versions)
+	}
+
+	if _, err := client.RestoreSnapshot(ctx, snapshot, "secret/migrated", vault.RestoreOptions{}); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	history, err := client.GetVersionHistory(ctx, "secret/migrated/key")
+	if err != nil {
+		t.Fatalf("GetVersionHistory failed: %v", err)
+	}
+	if len(history) != 2 || history[0].Version != 3 || history[1].Version != 2 {
+		t.Fatalf("expected readable versions 3 and 2, got %+v", history)
+	}
+	if !history[1].OriginalTime.Equal(versions[1].Created.Truncate(time.Second)) {
+		t.Errorf("expected original time %v, got %v", versions[1].Created, history[1].OriginalTime)
+	}
+
+	prev, _ := client.ReadSecretVersion(ctx, "secret/migrated/key", 2)
+	if prev["value"] != "v2" {
+		t.Errorf("expected v2 at version 2, got %v", prev["value"])
+	}
+}
+
+func TestIntegration_RestoreLongHistory(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// More versions than Vault allows custom metadata keys
+	created := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	var versions []vault.SnapshotVersion
+	for v := 1; v <= 80; v++ {
+		versions = append(versions, vault.SnapshotVersion{
+			Version: v,
+			Data:    map[string]any{"value": fmt.Sprintf("v%d", v)},
+			Created: created.Add(time.Duration(v) * time.Minute),
+		})
+	}
+	snapshot := &vault.Snapshot{
+		Path: "secret/orig",
+		Secrets: map[string]vault.SnapshotSecret{
+			"key": {Data: map[string]any{"value": "v80"}, Version: 80, Versions: versions},
+		},
+	}
+
+	if _, err := client.RestoreSnapshot(ctx, snapshot, "secret/long", vault.RestoreOptions{}); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	metadata, err := client.GetMetadata(ctx, "secret/long/key")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.CurrentVersion != 80 || len(metadata.CustomMetadata) > 64 {
+		t.Fatalf("expected version 80 with at most 64 custom metadata keys, got v%d with %d keys", metadata.CurrentVersion, len(metadata.CustomMetadata))
+	}
+
+	history, err := client.GetVersionHistory(ctx, "secret/long/key")
+	if err != nil {
+		t.Fatalf("GetVersionHistory failed: %v", err)
+	}
+	if len(history) == 0 || !history[0].OriginalTime.Equal(versions[79].Created) {
+		t.Errorf("expected the latest version to keep its original time, got %+v", history)
+	}
+}
+
+func TestIntegration_PointInTimeDeletedVersions(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/pit/deleted", "v1")
+	_ = client.Add(ctx, "secret/pit/destroyed", "v1")
+	time.Sleep(100 * time.Millisecond)
+	_ = client.Update(ctx, "secret/pit/deleted", "v2")
+	_ = client.Update(ctx, "secret/pit/destroyed", "v2")
+	time.Sleep(100 * time.Millisecond)
+	at := time.Now()
+	time.Sleep(100 * time.Millisecond)
+	_ = client.Update(ctx, "secret/pit/deleted", "v3")
+	_ = client.Update(ctx, "secret/pit/destroyed", "v3")
+
+	// Remove the versions that were current at the point in time
+	if _, err := client.PruneVersions(ctx, "secret/pit/deleted", vault.PruneOptions{Keep: 1}); err != nil {
+		t.Fatalf("PruneVersions failed: %v", err)
+	}
+	if _, err := client.PruneVersions(ctx, "secret/pit/destroyed", vault.PruneOptions{Keep: 1, Destroy: true}); err != nil {
+		t.Fatalf("PruneVersions failed: %v", err)
+	}
+
+	// Neither may fall back to v1
+	for _, path := range []string{"secret/pit/deleted", "secret/pit/destroyed"} {
+		data, err := client.ReadSecretAt(ctx, path, at)
+		if err == nil {
+			t.Errorf("ReadSecretAt(%s) = %v, want an error for the removed version", path, data)
+		}
+	}
+	if _, err := client.CreateSnapshotAt(ctx, "secret/pit", at); err == nil {
+		t.Error("CreateSnapshotAt succeeded without the removed versions")
+	}
+}
+
+func TestIntegration_SnapshotDrift(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/drift/key1", "value1")
+	_ = client.Add(ctx, "secret/drift/key2", "value2")
+
+	snapshot, err := client.CreateSnapshot(ctx, "secret/drift")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	// Rewriting the same value changes the version but not the data
+	_ = client.Update(ctx, "secret/drift/key1", "value1")
+
+	live, _ := client.Get(ctx, "secret/drift")
+	if result := vault.CompareSecrets(vault.Flatten(snapshot.Data()), vault.Flatten(live)); result.HasDifferences() {
+		t.Errorf("expected identical values, got %+v", result)
+	}
+
+	versions, err := client.CurrentVersions(ctx, "secret/drift")
+	if err != nil {
+		t.Fatalf("CurrentVersions failed: %v", err)
+	}
+	drift := vault.CompareSecretVersions(snapshot.SecretVersions(), versions)
+	if len(drift) != 1 || drift[0].Path != "key1" || drift[0].SecondVersion != 2 {
+		t.Errorf("expected key1 to drift to v2, got %+v", drift)
+	}
+}
+
 func TestIntegration_FindDuplicates(t *testing.T) {
 	ctx := context.Background()
 
@@ -475,3 +802,801 @@ func TestIntegration_FindDuplicates(t *testing.T) {
 		t.Errorf("expected 2 paths in duplicate group, got %d", len(duplicates[0].Paths))
 	}
 }
+
+func TestIntegration_TrashRestore(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := vault.NewClient(&config.Config{
+		VaultAddr:  container.URI,
+		VaultToken: testToken,
+		TrashPath:  "secret/.vlt-trash",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	// Create a secret with two versions and delete it
+	_ = client.Add(ctx, "secret/test/trashed", "v1")
+	_ = client.Update(ctx, "secret/test/trashed", "v2")
+	if err := client.DeleteSecret(ctx, "secret/test/trashed"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+
+	id := client.TrashID()
+	if id == "" {
+		t.Fatal("expected a trash ID after delete")
+	}
+
+	entries, err := client.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(entries) != 1 || len(entries[0].Secrets) != 1 {
+		t.Fatalf("expected 1 trash entry with 1 secret, got %+v", entries)
+	}
+	if entries[0].Secrets[0].Origin != "secret/test/trashed" {
+		t.Errorf("expected origin secret/test/trashed, got %s", entries[0].Secrets[0].Origin)
+	}
+
+	// Restore and verify history came back
+	if _, err := client.RestoreTrash(ctx, id); err != nil {
+		t.Fatalf("RestoreTrash failed: %v", err)
+	}
+
+	versions, err := client.GetVersionHistory(ctx, "secret/test/trashed")
+	if err != nil {
+		t.Fatalf("GetVersionHistory failed: %v", err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("expected 2 versions after restore, got %d", len(versions))
+	}
+
+	secrets, _ := client.Get(ctx, "secret/test/trashed")
+	if secrets["value"] != "v2" {
+		t.Errorf("expected 'v2', got %v", secrets["value"])
+	}
+
+	// Trash should be empty again
+	entries, err = client.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected empty trash after restore, got %d entries", len(entries))
+	}
+}
+
+func TestIntegration_TrashHiddenFromMount(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := vault.NewClient(&config.Config{
+		VaultAddr:  container.URI,
+		VaultToken: testToken,
+		TrashPath:  "secret/.vlt-trash",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/app/db", "v1")
+	_ = client.Add(ctx, "secret/top", "v1")
+
+	// Deleting the whole mount moves everything to the trash, but leaves the trash alone
+	result, err := client.DeleteRecursive(ctx, "secret")
+	if err != nil {
+		t.Fatalf("DeleteRecursive failed: %v", err)
+	}
+	if result.Count != 2 {
+		t.Errorf("expected 2 deleted secrets, got %v", result.Deleted)
+	}
+
+	paths, err := client.ListSecretPaths(ctx, "secret")
+	if err != nil {
+		t.Fatalf("ListSecretPaths failed: %v", err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("expected the trash to be hidden from the mount, got %v", paths)
+	}
+
+	entries, err := client.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(entries) != 1 || len(entries[0].Secrets) != 2 {
+		t.Fatalf("expected 1 trash entry with 2 secrets, got %+v", entries)
+	}
+
+	// A second delete of the mount must not purge the trash
+	if _, err := client.DeleteRecursive(ctx, "secret"); err != nil {
+		t.Fatalf("DeleteRecursive failed: %v", err)
+	}
+	entries, err = client.ListTrash(ctx)
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected the trash to survive, got %d entries", len(entries))
+	}
+
+	// Reading the mount returns live secrets only, never the trashed values
+	_ = client.Add(ctx, "secret/keep", "k")
+	exported, err := client.Export(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(exported) != 1 || exported["keep"] != "k" {
+		t.Errorf("expected Export to return only the live secret, got %v", exported)
+	}
+	got, err := client.Get(ctx, "secret")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(got) != 1 || got["keep"] != "k" {
+		t.Errorf("expected Get to return only the live secret, got %v", got)
+	}
+}
+
+func TestIntegration_CopyPreservesMetadata(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/test/meta-src", "value")
+	maxVersions := 4
+	err = client.UpdateMetadata(ctx, "secret/test/meta-src", vault.MetadataUpdate{
+		MaxVersions: &maxVersions,
+		SetCustom:   map[string]string{"owner": "team-a"},
+	})
+	if err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+
+	// Default copy keeps metadata
+	if err := client.Copy(ctx, "secret/test/meta-src", "secret/test/meta-dst"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	metadata, err := client.GetMetadata(ctx, "secret/test/meta-dst")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.MaxVersions != 4 || metadata.CustomMetadata["owner"] != "team-a" {
+		t.Errorf("expected metadata to be copied, got %+v", metadata)
+	}
+
+	// Reset copy drops it
+	err = client.CopyWithOptions(ctx, "secret/test/meta-src", "secret/test/meta-reset", vault.CopyOptions{ResetMetadata: true})
+	if err != nil {
+		t.Fatalf("CopyWithOptions failed: %v", err)
+	}
+	metadata, err = client.GetMetadata(ctx, "secret/test/meta-reset")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if len(metadata.CustomMetadata) != 0 {
+		t.Errorf("expected no custom metadata after reset, got %v", metadata.CustomMetadata)
+	}
+}
+
+func TestIntegration_MoveWithHistory(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/test/hist-src", "v1")
+	_ = client.Update(ctx, "secret/test/hist-src", "v2")
+	_ = client.Update(ctx, "secret/test/hist-src", "v3")
+
+	err = client.MoveWithOptions(ctx, "secret/test/hist-src", "secret/test/hist-dst", vault.CopyOptions{WithHistory: true})
+	if err != nil {
+		t.Fatalf("MoveWithOptions failed: %v", err)
+	}
+
+	versions, err := client.GetVersionHistory(ctx, "secret/test/hist-dst")
+	if err != nil {
+		t.Fatalf("GetVersionHistory failed: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("expected 3 versions, got %d", len(versions))
+	}
+	for _, v := range versions {
+		if v.OriginalTime.IsZero() {
+			t.Errorf("expected original time recorded for v%d", v.Version)
+		}
+	}
+
+	data, err := client.ReadSecretVersion(ctx, "secret/test/hist-dst", 1)
+	if err != nil {
+		t.Fatalf("ReadSecretVersion failed: %v", err)
+	}
+	if data["value"] != "v1" {
+		t.Errorf("expected v1 at version 1, got %v", data["value"])
+	}
+
+	exists, _ := client.SecretExists(ctx, "secret/test/hist-src")
+	if exists {
+		t.Error("source should not exist after move")
+	}
+}
+
+func TestIntegration_CopyWithHistoryKeepsVersionNumbers(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/test/gap-src", "v1")
+	_ = client.Update(ctx, "secret/test/gap-src", "v2")
+	_ = client.Update(ctx, "secret/test/gap-src", "v3")
+
+	// Soft-delete the middle version
+	raw, err := api.NewClient(&api.Config{Address: container.URI})
+	if err != nil {
+		t.Fatalf("failed to create vault api client: %v", err)
+	}
+	raw.SetToken(testToken)
+	if _, err := raw.Logical().WriteWithContext(ctx, "secret/delete/test/gap-src", map[string]any{"versions": []int{2}}); err != nil {
+		t.Fatalf("failed to delete version 2: %v", err)
+	}
+
+	err = client.CopyWithOptions(ctx, "secret/test/gap-src", "secret/test/gap-dst", vault.CopyOptions{WithHistory: true})
+	if err != nil {
+		t.Fatalf("CopyWithOptions failed: %v", err)
+	}
+
+	for version, expected := range map[int]any{1: "v1", 3: "v3"} {
+		data, err := client.ReadSecretVersion(ctx, "secret/test/gap-dst", version)
+		if err != nil {
+			t.Fatalf("ReadSecretVersion failed: %v", err)
+		}
+		if data["value"] != expected {
+			t.Errorf("expected %v at version %d, got %v", expected, version, data)
+		}
+	}
+
+	versions, err := client.GetVersionHistory(ctx, "secret/test/gap-dst")
+	if err != nil {
+		t.Fatalf("GetVersionHistory failed: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 3 || versions[1].Version != 1 {
+		t.Errorf("expected readable versions 3 and 1 with 2 deleted, got %+v", versions)
+	}
+}
+
+func TestIntegration_HistoryFollowsMove(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := vault.NewClient(&config.Config{
+		VaultAddr:  container.URI,
+		VaultToken: testToken,
+		TrashPath:  "secret/.vlt-trash",
+	})
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/test/old-name", "v1")
+	_ = client.Update(ctx, "secret/test/old-name", "v2")
+
+	if err := client.Move(ctx, "secret/test/old-name", "secret/test/new-name"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+	_ = client.Update(ctx, "secret/test/new-name", "v3")
+
+	segments, err := client.GetLineage(ctx, "secret/test/new-name")
+	if err != nil {
+		t.Fatalf("GetLineage failed: %v", err)
+	}
+	if len(segments) != 2 {
+		t.Fatalf("expected 2 lineage segments, got %d", len(segments))
+	}
+	if segments[1].Path != "secret/test/old-name" || len(segments[1].Versions) != 2 {
+		t.Errorf("expected 2 versions at secret/test/old-name, got %+v", segments[1])
+	}
+
+	timeline, gaps, err := client.GetTimelineFollow(ctx, "secret/test/new-name")
+	if err != nil {
+		t.Fatalf("GetTimelineFollow failed: %v", err)
+	}
+	if len(timeline) != 4 {
+		t.Errorf("expected 4 timeline entries, got %d", len(timeline))
+	}
+	if len(gaps) != 0 {
+		t.Errorf("expected the history to be followed past the move, got gaps %+v", gaps)
+	}
+}
+
+func TestIntegration_HistoryFollowsMoveWithoutTrash(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/test/old-name", "v1")
+	_ = client.Update(ctx, "secret/test/old-name", "v2")
+
+	// Without the trash the source is purged, so its versions are gone
+	if err := client.Move(ctx, "secret/test/old-name", "secret/test/new-name"); err != nil {
+		t.Fatalf("Move failed: %v", err)
+	}
+
+	segments, err := client.GetLineage(ctx, "secret/test/new-name")
+	if err != nil {
+		t.Fatalf("GetLineage failed: %v", err)
+	}
+	if len(segments) != 2 || segments[1].HistoryPath != "" || len(segments[1].Versions) != 0 {
+		t.Fatalf("expected the lineage to end at secret/test/old-name without versions, got %+v", segments)
+	}
+
+	timeline, gaps, err := client.GetTimelineFollow(ctx, "secret/test/new-name")
+	if err != nil {
+		t.Fatalf("GetTimelineFollow failed: %v", err)
+	}
+	if len(timeline) != 1 {
+		t.Errorf("expected 1 timeline entry, got %d", len(timeline))
+	}
+	if len(gaps) != 1 || gaps[0].From != "secret/test/old-name" {
+		t.Errorf("expected a gap at the move from secret/test/old-name, got %+v", gaps)
+	}
+}
+
+func TestIntegration_IncrementalSnapshot(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/inc/same", "unchanged")
+	_ = client.Add(ctx, "secret/inc/changed", "v1")
+	_ = client.Add(ctx, "secret/inc/removed", "bye")
+	_ = client.Add(ctx, "secret/inc/recreated", "first")
+
+	base, err := client.CreateSnapshot(ctx, "secret/inc")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	_ = client.Update(ctx, "secret/inc/changed", "v2")
+	_ = client.Add(ctx, "secret/inc/added", "new")
+	_ = client.DeleteSecret(ctx, "secret/inc/removed")
+
+	// Recreated at the same version number as in the base
+	_ = client.DeleteSecret(ctx, "secret/inc/recreated")
+	_ = client.Add(ctx, "secret/inc/recreated", "second")
+
+	incremental, err := client.CreateIncrementalSnapshot(ctx, "secret/inc", base, vault.SnapshotOptions{})
+	if err != nil {
+		t.Fatalf("CreateIncrementalSnapshot failed: %v", err)
+	}
+
+	if len(incremental.Secrets) != 3 || incremental.Secrets["changed"].Data["value"] != "v2" || incremental.Secrets["added"].Data["value"] != "new" {
+		t.Errorf("expected changed, added and recreated secrets only, got %+v", incremental.Secrets)
+	}
+	if incremental.Secrets["recreated"].Data["value"] != "second" {
+		t.Errorf("expected the recreated secret to be captured, got %+v", incremental.Secrets["recreated"])
+	}
+	if len(incremental.Removed) != 1 || incremental.Removed[0] != "removed" {
+		t.Errorf("expected removed secret to be recorded, got %v", incremental.Removed)
+	}
+
+	merged := vault.ApplyIncremental(base, incremental)
+	if _, err := client.RestoreSnapshot(ctx, merged, "secret/inc-restored", vault.RestoreOptions{}); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	paths, _ := client.ListSecretPaths(ctx, "secret/inc-restored")
+	if len(paths) != 4 {
+		t.Errorf("expected 4 restored secrets, got %v", paths)
+	}
+
+	if _, err := client.CreateIncrementalSnapshot(ctx, "secret/other", base, vault.SnapshotOptions{}); err == nil {
+		t.Error("expected an error for a base of another path")
+	}
+}
+
+func TestIntegration_RestoreRollback(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/tx/existing", "before")
+	_ = client.Add(ctx, "secret/tx/extra", "keep-me")
+
+	// A value that cannot be encoded makes the restore fail partway
+	now := time.Now()
+	snapshot := &vault.Snapshot{
+		Path:      "secret/tx",
+		CreatedAt: now,
+		Secrets: map[string]vault.SnapshotSecret{
+			"existing": {Value: "after", Version: 1, Updated: now},
+			"added":    {Value: "new", Version: 1, Updated: now},
+			"broken":   {Value: map[string]any{"bad": make(chan int)}, Version: 1, Updated: now},
+		},
+	}
+
+	_, err = client.RestoreSnapshot(ctx, snapshot, "secret/tx", vault.RestoreOptions{DeleteExtra: true})
+	var restoreErr *vault.RestoreError
+	if !errors.As(err, &restoreErr) {
+		t.Fatalf("expected a RestoreError, got %v", err)
+	}
+	if !restoreErr.RolledBack || restoreErr.Failed == nil || restoreErr.Failed.Path != "broken" {
+		t.Errorf("unexpected restore error: %+v", restoreErr)
+	}
+
+	paths, _ := client.ListSecretPaths(ctx, "secret/tx")
+	if len(paths) != 2 {
+		t.Errorf("expected only the original secrets after rollback, got %v", paths)
+	}
+	if v, _ := client.GetValue(ctx, "secret/tx/existing", "value"); v != "before" {
+		t.Errorf("expected existing to be rolled back, got %v", v)
+	}
+
+	// With KeepPartial the applied changes stay and are reported
+	_, err = client.RestoreSnapshot(ctx, snapshot, "secret/tx", vault.RestoreOptions{DeleteExtra: true, KeepPartial: true})
+	if !errors.As(err, &restoreErr) || restoreErr.RolledBack {
+		t.Fatalf("expected a partial RestoreError, got %v", err)
+	}
+	for _, change := range restoreErr.Applied {
+		exists, _ := client.SecretExists(ctx, "secret/tx/"+change.Path)
+		if !exists {
+			t.Errorf("applied change %+v is not in place", change)
+		}
+	}
+}
+
+func TestIntegration_RestoreSelection(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/sel/database/password", "pw")
+	_ = client.Add(ctx, "secret/sel/database/user", "admin")
+	_ = client.Add(ctx, "secret/sel/config", "cfg")
+
+	snapshot, err := client.CreateSnapshot(ctx, "secret/sel")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/scratch/unrelated", "keep")
+
+	result, err := client.RestoreSnapshot(ctx, snapshot, "secret/scratch", vault.RestoreOptions{
+		DeleteExtra: true,
+		Selection: vault.SnapshotSelection{
+			Include: []string{"database/*"},
+			Map:     []vault.PathMapping{{From: "database", To: "db"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if len(result.Added) != 2 || len(result.Deleted) != 0 {
+		t.Errorf("expected 2 added and none deleted, got %+v", result)
+	}
+
+	if v, _ := client.GetValue(ctx, "secret/scratch/db/password", "value"); v != "pw" {
+		t.Errorf("expected mapped secret, got %v", v)
+	}
+	if exists, _ := client.SecretExists(ctx, "secret/scratch/unrelated"); !exists {
+		t.Error("secret outside the selection was deleted")
+	}
+	if exists, _ := client.SecretExists(ctx, "secret/scratch/config"); exists {
+		t.Error("secret outside the include pattern was restored")
+	}
+}
+
+func TestIntegration_SnapshotFidelity(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.WriteSecret(ctx, "secret/hifi/wrapped", map[string]any{"value": map[string]any{"a": "b"}})
+	_ = client.WriteSecret(ctx, "secret/hifi/bare", map[string]any{"a": "b"})
+	_ = client.WriteSecret(ctx, "secret/hifi/typed", map[string]any{"port": 5432, "port_str": "5432", "on": true})
+
+	maxVersions, casRequired := 3, true
+	if err := client.UpdateMetadata(ctx, "secret/hifi/typed", vault.MetadataUpdate{
+		MaxVersions: &maxVersions,
+		CASRequired: &casRequired,
+		SetCustom:   map[string]string{"owner": "team-a"},
+	}); err != nil {
+		t.Fatalf("UpdateMetadata failed: %v", err)
+	}
+
+	snapshot, err := client.CreateSnapshot(ctx, "secret/hifi")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	if _, err := client.RestoreSnapshot(ctx, snapshot, "secret/hifi-copy", vault.RestoreOptions{}); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	for _, name := range []string{"wrapped", "bare", "typed"} {
+		original, _ := client.ReadSecretRaw(ctx, "secret/hifi/"+name)
+		restored, _ := client.ReadSecretRaw(ctx, "secret/hifi-copy/"+name)
+		if fmt.Sprintf("%#v", original) != fmt.Sprintf("%#v", restored) {
+			t.Errorf("%s: restored %#v, want %#v", name, restored, original)
+		}
+	}
+
+	metadata, err := client.GetMetadata(ctx, "secret/hifi-copy/typed")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.MaxVersions != 3 || !metadata.CASRequired || metadata.CustomMetadata["owner"] != "team-a" {
+		t.Errorf("metadata not restored: %+v", metadata)
+	}
+
+	// Restoring again over a check-and-set secret writes against its current version
+	snapshot.Secrets["typed"].Data["port"] = int64(6543)
+	result, err := client.RestoreSnapshot(ctx, snapshot, "secret/hifi-copy", vault.RestoreOptions{})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot over cas_required secret failed: %v", err)
+	}
+	if len(result.Updated) != 1 || len(result.Unchanged) != 2 {
+		t.Errorf("expected 1 updated and 2 unchanged, got %+v", result)
+	}
+}
+
+func TestIntegration_RestoreOtherBackupFormats(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.WriteSecret(ctx, "secret/interop/db", map[string]any{"user": "admin", "password": "pw"})
+	_ = client.Add(ctx, "secret/interop/api/token", "abc")
+
+	snapshot, err := client.CreateSnapshot(ctx, "secret/interop")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	for _, format := range []vault.BackupFormat{vault.BackupFormatMedusa, vault.BackupFormatVaultJSON} {
+		data, err := vault.MarshalBackup(snapshot, format)
+		if err != nil {
+			t.Fatalf("MarshalBackup(%s) failed: %v", format, err)
+		}
+
+		read, err := vault.UnmarshalSnapshot(data, vault.SnapshotLoadOptions{AllowPlaintext: true})
+		if err != nil {
+			t.Fatalf("UnmarshalSnapshot(%s) failed: %v", format, err)
+		}
+
+		target := "secret/interop-" + string(format)
+		result, err := client.RestoreSnapshot(ctx, read, target, vault.RestoreOptions{})
+		if err != nil {
+			t.Fatalf("RestoreSnapshot(%s) failed: %v", format, err)
+		}
+		if len(result.Added) != 2 {
+			t.Errorf("%s: expected 2 added, got %+v", format, result)
+		}
+
+		if v, _ := client.GetValue(ctx, target+"/db", "password"); v != "pw" {
+			t.Errorf("%s: expected db password pw, got %v", format, v)
+		}
+		if v, _ := client.GetValue(ctx, target+"/api/token", "value"); v != "abc" {
+			t.Errorf("%s: expected api/token abc, got %v", format, v)
+		}
+	}
+}
+
+func TestIntegration_BackupState(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/state/a", "1")
+	_ = client.Add(ctx, "secret/state/b", "2")
+
+	state, err := client.BackupState(ctx, "secret/state")
+	if err != nil {
+		t.Fatalf("BackupState failed: %v", err)
+	}
+	if again, _ := client.BackupState(ctx, "secret/state"); again != state {
+		t.Errorf("state changed without writes: %s, then %s", state, again)
+	}
+
+	// Writing the same value still creates a version, which a backup would record
+	if err := client.Update(ctx, "secret/state/a", "1"); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	changed, err := client.BackupState(ctx, "secret/state")
+	if err != nil {
+		t.Fatalf("BackupState failed: %v", err)
+	}
+	if changed == state {
+		t.Error("expected the state to change after a write")
+	}
+
+	// Writes elsewhere don't affect it
+	_ = client.Add(ctx, "secret/other/c", "3")
+	if again, _ := client.BackupState(ctx, "secret/state"); again != changed {
+		t.Errorf("state changed by a write outside the path")
+	}
+}
+
+func TestIntegration_RestoreConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	container, err := setupVault(ctx)
+	if err != nil {
+		t.Fatalf("failed to setup vault: %v", err)
+	}
+	defer container.Terminate(ctx)
+
+	client, err := newTestClient(container.URI)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	_ = client.Add(ctx, "secret/conflict/stale", "original")
+	_ = client.Add(ctx, "secret/conflict/edited", "original")
+
+	snapshot, err := client.CreateSnapshot(ctx, "secret/conflict")
+	if err != nil {
+		t.Fatalf("CreateSnapshot failed: %v", err)
+	}
+
+	// stale only differs from the snapshot, edited was modified after it
+	_ = client.Update(ctx, "secret/conflict/edited", "edited")
+	stale := snapshot.Secrets["stale"]
+	stale.Data = map[string]any{"value": "from-backup"}
+	snapshot.Secrets["stale"] = stale
+
+	_, err = client.RestoreSnapshot(ctx, snapshot, "secret/conflict", vault.RestoreOptions{OnConflict: vault.ConflictFail})
+	var conflictErr *vault.ConflictError
+	if !errors.As(err, &conflictErr) || len(conflictErr.Conflicts) != 1 || conflictErr.Conflicts[0].Path != "edited" {
+		t.Fatalf("expected a conflict on edited, got %v", err)
+	}
+	if got, _ := client.Get(ctx, "secret/conflict/stale"); got["value"] != "original" {
+		t.Errorf("failed restore changed stale to %v", got["value"])
+	}
+
+	var prompted []string
+	result, err := client.RestoreSnapshot(ctx, snapshot, "secret/conflict", vault.RestoreOptions{
+		OnConflict: vault.ConflictPrompt,
+		Prompt: func(conflict vault.RestoreConflict) (bool, error) {
+			prompted = append(prompted, conflict.Path)
+			if conflict.Current["value"] != "edited" || conflict.CurrentVersion != 2 {
+				t.Errorf("unexpected conflict %+v", conflict)
+			}
+			return false, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if len(prompted) != 1 || prompted[0] != "edited" {
+		t.Errorf("prompted for %v, want [edited]", prompted)
+	}
+	if len(result.Updated) != 1 || result.Updated[0] != "stale" || len(result.Skipped) != 1 || result.Skipped[0] != "edited" {
+		t.Errorf("expected stale updated and edited skipped, got %+v", result)
+	}
+	if got, _ := client.Get(ctx, "secret/conflict/edited"); got["value"] != "edited" {
+		t.Errorf("declined conflict was restored: %v", got["value"])
+	}
+
+	// Vault holds the newer value of edited
+	result, err = client.RestoreSnapshot(ctx, snapshot, "secret/conflict", vault.RestoreOptions{OnConflict: vault.ConflictNewerWins})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if len(result.Skipped) != 1 || len(result.Conflicts) != 1 {
+		t.Errorf("expected edited kept as newer, got %+v", result)
+	}
+
+	result, err = client.RestoreSnapshot(ctx, snapshot, "secret/conflict", vault.RestoreOptions{})
+	if err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if len(result.Updated) != 1 || len(result.Conflicts) != 1 || len(result.Skipped) != 0 {
+		t.Errorf("expected edited overwritten, got %+v", result)
+	}
+	if got, _ := client.Get(ctx, "secret/conflict/edited"); got["value"] != "original" {
+		t.Errorf("expected edited restored, got %v", got["value"])
+	}
+}
@@ -0,0 +1,216 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RestoreAction is the kind of change a restore made to a secret
+type RestoreAction string
+
+const (
+	RestoreAdded   RestoreAction = "added"
+	RestoreUpdated RestoreAction = "updated"
+	RestoreDeleted RestoreAction = "deleted"
+)
+
+// RestoreChange is a single change made by a restore
+type RestoreChange struct {
+	Path   string // Secret path relative to the restore target
+	Action RestoreAction
+}
+
+// RestoreError is returned when a restore fails after it started changing secrets.
+// Unless the restore was run with KeepPartial, the changes were rolled back.
+type RestoreError struct {
+	Err         error           // Failure that stopped the restore
+	Applied     []RestoreChange // Changes completed before the failure, in order
+	Failed      *RestoreChange  // Change that was in progress when the restore failed
+	RolledBack  bool            // Changes were reverted to the pre-restore state
+	RollbackErr error           // Reverting failed, secrets are partially restored
+}
+
+func (e *RestoreError) Error() string {
+	switch {
+	case e.RollbackErr != nil:
+		return fmt.Sprintf("%v (rollback failed: %v)", e.Err, e.RollbackErr)
+	case e.RolledBack:
+		return fmt.Sprintf("%v (rolled back %d change(s))", e.Err, len(e.Applied))
+	default:
+		return fmt.Sprintf("%v (%d change(s) left in place)", e.Err, len(e.Applied))
+	}
+}
+
+func (e *RestoreError) Unwrap() error {
+	return e.Err
+}
+
+// journalEntry records the state of a secret before a restore changed it
+type journalEntry struct {
+	change   RestoreChange
+	fullPath string
+	data     map[string]any  // Value before an update, nil if the current version was deleted
+	metadata *SecretMetadata // Metadata before an update
+	history  *secretHistory  // Versions and metadata before a delete
+	done     bool
+}
+
+// secretHistory holds the readable versions of a secret and its metadata
+type secretHistory struct {
+	versions []VersionInfo
+	data     []map[string]any
+	metadata *SecretMetadata
+}
+
+// restoreJournal keeps the prior state of every secret a restore changes, so the
+// restore can be reverted if it fails partway
+type restoreJournal struct {
+	entries []*journalEntry
+}
+
+// journalChange records a change before it is made. data and metadata are the prior
+// state of an updated secret.
+func (c *Client) journalChange(ctx context.Context, j *restoreJournal, change RestoreChange, fullPath string, data map[string]any, metadata *SecretMetadata) (*journalEntry, error) {
+	entry := &journalEntry{change: change, fullPath: fullPath, data: data, metadata: metadata}
+
+	if change.Action == RestoreDeleted {
+		history, err := c.readSecretHistory(ctx, fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to journal %s: %w", change.Path, err)
+		}
+		entry.history = history
+	}
+
+	j.entries = append(j.entries, entry)
+	return entry, nil
+}
+
+// readSecretHistory reads every readable version of a secret, oldest first, and its metadata
+func (c *Client) readSecretHistory(ctx context.Context, path string) (*secretHistory, error) {
+	versions, err := c.GetVersionHistory(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	history := &secretHistory{}
+	for i := len(versions) - 1; i >= 0; i-- {
+		data, err := c.ReadSecretVersion(ctx, path, versions[i].Version)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		history.versions = append(history.versions, versions[i])
+		history.data = append(history.data, data)
+	}
+
+	if history.metadata, err = c.GetMetadata(ctx, path); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// failRestore builds the error for a restore that failed with err, rolling back the
+// journaled changes unless keepPartial is set
+func (c *Client) failRestore(ctx context.Context, j *restoreJournal, err error, keepPartial bool) error {
+	if len(j.entries) == 0 {
+		return err
+	}
+
+	restoreErr := &RestoreError{Err: err}
+	for _, entry := range j.entries {
+		if entry.done {
+			restoreErr.Applied = append(restoreErr.Applied, entry.change)
+		} else {
+			change := entry.change
+			restoreErr.Failed = &change
+		}
+	}
+
+	if keepPartial {
+		return restoreErr
+	}
+
+	// Roll back even if the restore was interrupted
+	restoreErr.RollbackErr = c.rollback(context.WithoutCancel(ctx), j)
+	restoreErr.RolledBack = restoreErr.RollbackErr == nil
+	return restoreErr
+}
+
+// rollback reverts journaled changes, most recent first. A change that was in
+// progress is reverted too, as it may have been partially applied.
+func (c *Client) rollback(ctx context.Context, j *restoreJournal) error {
+	var failures []string
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		entry := j.entries[i]
+		if err := c.revertChange(ctx, entry); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", entry.change.Path, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("%s", strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// revertChange restores the state of a secret recorded in a journal entry
+func (c *Client) revertChange(ctx context.Context, entry *journalEntry) error {
+	switch entry.change.Action {
+	case RestoreAdded:
+		return c.purgeSecret(ctx, entry.fullPath)
+
+	case RestoreUpdated:
+		current, err := c.GetMetadata(ctx, entry.fullPath)
+		if err != nil || current == nil {
+			return err
+		}
+		if entry.metadata == nil || current.CurrentVersion != entry.metadata.CurrentVersion {
+			if entry.data != nil {
+				err = c.writeRestoredData(ctx, entry.fullPath, entry.data, current)
+			} else {
+				// The current version was deleted before the restore, delete the restored one
+				err = c.removeVersions(ctx, entry.fullPath, []int{current.CurrentVersion}, false)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if entry.metadata == nil {
+			return nil
+		}
+		return c.restoreMetadata(ctx, entry.fullPath, snapshotMetadata(entry.metadata))
+
+	case RestoreDeleted:
+		if exists, err := c.SecretExists(ctx, entry.fullPath); err != nil || exists {
+			return err
+		}
+		return c.restoreSecretHistory(ctx, entry.fullPath, entry.history)
+	}
+
+	return nil
+}
+
+// restoreSecretHistory writes a secret back with the versions and metadata it had
+func (c *Client) restoreSecretHistory(ctx context.Context, path string, history *secretHistory) error {
+	if len(history.versions) == 0 {
+		return nil
+	}
+
+	for _, data := range history.data {
+		if err := c.writeSecretCopy(ctx, path, data); err != nil {
+			return err
+		}
+	}
+	if err := c.writeMetadata(ctx, path, copiedMetadataFields(history.metadata, history.versions, false)); err != nil {
+		return err
+	}
+
+	// Drop the copy the delete left in the trash, the secret is back in place
+	if c.TrashEnabled() && c.trashID != "" {
+		return c.purgeSecret(ctx, c.trashedPath(path))
+	}
+	return nil
+}
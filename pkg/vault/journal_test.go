@@ -0,0 +1,44 @@
+package vault
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRestoreError(t *testing.T) {
+	cause := errors.New("failed to write secret db")
+	applied := []RestoreChange{{Path: "config", Action: RestoreAdded}, {Path: "api", Action: RestoreUpdated}}
+
+	tests := []struct {
+		name     string
+		err      *RestoreError
+		expected string
+	}{
+		{
+			name:     "rolled back",
+			err:      &RestoreError{Err: cause, Applied: applied, RolledBack: true},
+			expected: "failed to write secret db (rolled back 2 change(s))",
+		},
+		{
+			name:     "kept partial",
+			err:      &RestoreError{Err: cause, Applied: applied},
+			expected: "failed to write secret db (2 change(s) left in place)",
+		},
+		{
+			name:     "rollback failed",
+			err:      &RestoreError{Err: cause, Applied: applied, RollbackErr: errors.New("config: permission denied")},
+			expected: "failed to write secret db (rollback failed: config: permission denied)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); got != tt.expected {
+				t.Errorf("Error() = %q, want %q", got, tt.expected)
+			}
+			if !errors.Is(tt.err, cause) {
+				t.Error("RestoreError does not unwrap to its cause")
+			}
+		})
+	}
+}
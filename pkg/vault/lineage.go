@@ -0,0 +1,210 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Custom metadata keys recording where a moved secret came from
+const (
+	movedFromKey        = "vlt_moved_from"
+	movedAtKey          = "vlt_moved_at"
+	movedHistoryKey     = "vlt_moved_history"      // Where the history of the source was kept (the trash)
+	movedWithHistoryKey = "vlt_moved_with_history" // "true" if the source versions were replayed onto the destination
+	movedToKey          = "vlt_moved_to"           // Recorded on the kept history of the source
+)
+
+// LineageSegment is the part of a secret's history spent at one path
+type LineageSegment struct {
+	Path        string    // Path the secret had during this segment
+	HistoryPath string    // Where the versions of this segment can be read, empty if they were not kept
+	MovedAt     time.Time // When the secret was moved away from Path, zero for the current path
+	Replayed    bool      // Versions were replayed onto the next path, so they appear in its history
+	Versions    []VersionInfo
+}
+
+// LineageGap is a move whose source versions were not kept, because the trash was
+// disabled and the move didn't replay them, so the history stops there
+type LineageGap struct {
+	SecretPath string    // Secret as named in the timeline
+	From       string    // Path the secret was moved from
+	MovedAt    time.Time // When it was moved, zero if not recorded
+}
+
+// lost returns true if the versions written at the segment's path were not kept
+func (s LineageSegment) lost() bool {
+	return s.HistoryPath == "" && !s.Replayed
+}
+
+// recordMove records on dst that it was moved from src, and on the trashed history
+// of src (if kept) where it was moved to
+func (c *Client) recordMove(ctx context.Context, src, dst string, opts CopyOptions) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	historyPath := ""
+	if c.TrashEnabled() && c.trashID != "" {
+		historyPath = c.trashPath + "/" + c.trashID + "/" + src
+		if exists, err := c.SecretExists(ctx, historyPath); err != nil || !exists {
+			historyPath = ""
+		}
+	}
+
+	update := MetadataUpdate{
+		SetCustom: map[string]string{
+			movedFromKey: src,
+			movedAtKey:   now,
+		},
+	}
+	if historyPath != "" {
+		update.SetCustom[movedHistoryKey] = historyPath
+	} else {
+		update.UnsetCustom = append(update.UnsetCustom, movedHistoryKey)
+	}
+	if opts.WithHistory {
+		update.SetCustom[movedWithHistoryKey] = "true"
+	} else {
+		update.UnsetCustom = append(update.UnsetCustom, movedWithHistoryKey)
+	}
+
+	if err := c.UpdateMetadata(ctx, dst, update); err != nil {
+		return err
+	}
+
+	if historyPath == "" {
+		return nil
+	}
+	return c.UpdateMetadata(ctx, historyPath, MetadataUpdate{
+		SetCustom: map[string]string{movedToKey: dst, movedAtKey: now},
+	})
+}
+
+// GetLineage returns the history of a secret across renames, current path first.
+// Earlier segments are found through the move records left by Move; their versions
+// are only available if the source was kept in the trash or replayed by the move.
+// Without the trash, a move without history purges them and the lineage ends there.
+func (c *Client) GetLineage(ctx context.Context, path string) ([]LineageSegment, error) {
+	versions, err := c.GetVersionHistory(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := []LineageSegment{{Path: path, HistoryPath: path, Versions: versions}}
+
+	metadata, err := c.GetMetadata(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{path: true}
+	for metadata != nil {
+		from := metadata.CustomMetadata[movedFromKey]
+		if from == "" || seen[from] {
+			break
+		}
+		seen[from] = true
+
+		segment := LineageSegment{
+			Path:        from,
+			HistoryPath: metadata.CustomMetadata[movedHistoryKey],
+			Replayed:    metadata.CustomMetadata[movedWithHistoryKey] == "true",
+		}
+		if t, err := time.Parse(time.RFC3339, metadata.CustomMetadata[movedAtKey]); err == nil {
+			segment.MovedAt = t
+		}
+
+		// Continue from the kept history of the source, which has its own move records
+		metadata = nil
+		if segment.HistoryPath != "" {
+			if segment.Versions, err = c.GetVersionHistory(ctx, segment.HistoryPath); err != nil {
+				return nil, err
+			}
+			if metadata, err = c.GetMetadata(ctx, segment.HistoryPath); err != nil {
+				return nil, err
+			}
+			if metadata == nil {
+				segment.HistoryPath = ""
+			}
+		}
+		if segment.Replayed {
+			segment.Versions = nil
+		}
+
+		segments = append(segments, segment)
+	}
+
+	return segments, nil
+}
+
+// GetTimelineFollow returns the timeline of a secret or of all secrets under a directory,
+// including versions written before they were moved to their current path.
+// Sorted by time descending (newest first). Also returns the moves the history can't
+// be followed past, because the versions before them were not kept.
+func (c *Client) GetTimelineFollow(ctx context.Context, path string) ([]TimelineEntry, []LineageGap, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	single := len(secretPaths) == 0
+	if single {
+		secretPaths = []string{""}
+	}
+
+	var timeline []TimelineEntry
+	var gaps []LineageGap
+	for _, relPath := range secretPaths {
+		fullPath, name := path, path
+		if !single {
+			fullPath, name = path+"/"+relPath, relPath
+		}
+
+		segments, err := c.GetLineage(ctx, fullPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		timeline = append(timeline, lineageTimeline(name, segments)...)
+
+		for _, segment := range segments[1:] {
+			if segment.lost() {
+				gaps = append(gaps, LineageGap{SecretPath: name, From: segment.Path, MovedAt: segment.MovedAt})
+			}
+		}
+	}
+
+	if len(timeline) == 0 {
+		return nil, nil, fmt.Errorf("no version history found at %s", path)
+	}
+
+	// Sort by time descending (newest first)
+	sort.SliceStable(timeline, func(i, j int) bool {
+		return timeline[i].Time.After(timeline[j].Time)
+	})
+
+	return timeline, gaps, nil
+}
+
+// lineageTimeline converts the lineage of a secret into timeline entries named secretPath
+func lineageTimeline(secretPath string, segments []LineageSegment) []TimelineEntry {
+	var timeline []TimelineEntry
+	for i, segment := range segments {
+		formerPath := ""
+		if i > 0 {
+			formerPath = segment.Path
+		}
+
+		for _, v := range segment.Versions {
+			timeline = append(timeline, TimelineEntry{
+				Time:       v.WrittenTime(),
+				SecretPath: secretPath,
+				FullPath:   segment.HistoryPath,
+				Version:    v.Version,
+				IsCreation: v.Version == 1,
+				Provenance: v.Provenance,
+				FormerPath: formerPath,
+			})
+		}
+	}
+	return timeline
+}
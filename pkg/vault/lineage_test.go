@@ -0,0 +1,80 @@
+package vault
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLineageTimeline(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+	segments := []LineageSegment{
+		{
+			Path:        "secret/new/db",
+			HistoryPath: "secret/new/db",
+			Versions: []VersionInfo{
+				{Version: 2, CreatedTime: at(10)},
+				{Version: 1, CreatedTime: at(5)},
+			},
+		},
+		{
+			Path:        "secret/old/db",
+			HistoryPath: "secret/.vlt-trash/20240130T170000.000Z/secret/old/db",
+			MovedAt:     at(5),
+			Versions: []VersionInfo{
+				{Version: 2, CreatedTime: at(5), OriginalTime: at(3)},
+				{Version: 1, CreatedTime: at(5), OriginalTime: at(1)},
+			},
+		},
+		{
+			// Moved without keeping history
+			Path: "secret/older/db",
+		},
+	}
+
+	expected := []TimelineEntry{
+		{Time: at(10), SecretPath: "db", FullPath: "secret/new/db", Version: 2},
+		{Time: at(5), SecretPath: "db", FullPath: "secret/new/db", Version: 1, IsCreation: true},
+		{Time: at(3), SecretPath: "db", FullPath: "secret/.vlt-trash/20240130T170000.000Z/secret/old/db", Version: 2, FormerPath: "secret/old/db"},
+		{Time: at(1), SecretPath: "db", FullPath: "secret/.vlt-trash/20240130T170000.000Z/secret/old/db", Version: 1, IsCreation: true, FormerPath: "secret/old/db"},
+	}
+
+	got := lineageTimeline("db", segments)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("lineageTimeline() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestGetTimelineFollowLostHistory(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+	value := func(v string) map[string]any { return map[string]any{"value": v} }
+
+	kept := ".vlt-trash/20240130T150000.000Z/secret/old/b"
+	client := newFakeKV(t, map[string][]fakeVersion{
+		// Moved with the trash disabled: the versions at secret/old/a were purged
+		"app/a": {{at(3), value("a2")}},
+		// Moved with the trash enabled: the versions at secret/old/b are in the trash
+		"app/b": {{at(3), value("b2")}},
+		kept:    {{at(0), value("b1")}, {at(1), value("b2")}},
+	}, map[string]map[string]string{
+		"app/a": {movedFromKey: "secret/old/a", movedAtKey: at(3).Format(time.RFC3339)},
+		"app/b": {movedFromKey: "secret/old/b", movedAtKey: at(3).Format(time.RFC3339), movedHistoryKey: "secret/" + kept},
+	})
+
+	timeline, gaps, err := client.GetTimelineFollow(context.Background(), "secret/app")
+	if err != nil {
+		t.Fatalf("GetTimelineFollow() error = %v", err)
+	}
+	if len(timeline) != 4 {
+		t.Errorf("GetTimelineFollow() returned %d entries, want 4: %+v", len(timeline), timeline)
+	}
+
+	expected := []LineageGap{{SecretPath: "a", From: "secret/old/a", MovedAt: at(3)}}
+	if !reflect.DeepEqual(gaps, expected) {
+		t.Errorf("GetTimelineFollow() gaps = %+v, want %+v", gaps, expected)
+	}
+}
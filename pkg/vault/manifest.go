@@ -0,0 +1,233 @@
+package vault
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// manifestFormat is the version of the snapshot manifest format
+const manifestFormat = 1
+
+// SnapshotManifest describes the content of a snapshot file so it can be checked
+// before restoring: truncated or edited snapshots no longer match their digests.
+type SnapshotManifest struct {
+	Format     int    `yaml:"format"`
+	VltVersion string `yaml:"vlt_version,omitempty"`
+	Cluster    string `yaml:"cluster,omitempty"` // Address of the Vault the snapshot was taken from
+	Mount      string `yaml:"mount,omitempty"`   // KV mount of the snapshot path
+
+	Secrets  int               `yaml:"secrets"`  // Number of secrets
+	Versions int               `yaml:"versions"` // Number of versions, for snapshots taken with all versions
+	Digests  map[string]string `yaml:"digests"`  // SHA-256 of each secret, keyed by relative path
+
+	// Signature is an ed25519 signature of the manifest and snapshot header, base64 encoded
+	Signature string `yaml:"signature,omitempty"`
+}
+
+// snapshotSource returns a manifest recording where a snapshot of path is taken from
+func (c *Client) snapshotSource(ctx context.Context, path string) *SnapshotManifest {
+	mount, _, _ := c.ResolveMountPath(ctx, path)
+	return &SnapshotManifest{
+		Cluster: c.client.Address(),
+		Mount:   mount,
+	}
+}
+
+// secretDigest returns the SHA-256 digest of a snapshot secret, as "sha256:<hex>"
+func secretDigest(secret SnapshotSecret) (string, error) {
+	// Times are compared as instants, whatever zone they were written in
+	secret.Updated = secret.Updated.UTC()
+	if len(secret.Versions) > 0 {
+		versions := make([]SnapshotVersion, len(secret.Versions))
+		for i, v := range secret.Versions {
+			v.Created = v.Created.UTC()
+			versions[i] = v
+		}
+		secret.Versions = versions
+	}
+
+	data, err := json.Marshal(secret)
+	if err != nil {
+		return "", err
+	}
+	return Digest(data), nil
+}
+
+// buildManifest computes the counts and digests of a snapshot, starting from the source
+// recorded in base (if any), and signs it if key is set
+func buildManifest(snapshot *Snapshot, base *SnapshotManifest, vltVersion string, key ed25519.PrivateKey) (*SnapshotManifest, error) {
+	manifest := &SnapshotManifest{}
+	if base != nil {
+		*manifest = *base
+	}
+	manifest.Format = manifestFormat
+	manifest.VltVersion = vltVersion
+	manifest.Secrets = len(snapshot.Secrets)
+	manifest.Versions = 0
+	manifest.Digests = make(map[string]string, len(snapshot.Secrets))
+	manifest.Signature = ""
+
+	for relPath, secret := range snapshot.Secrets {
+		digest, err := secretDigest(secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to digest %s: %w", relPath, err)
+		}
+		manifest.Digests[relPath] = digest
+		manifest.Versions += len(secret.Versions)
+	}
+
+	if key != nil {
+		payload, err := signedPayload(snapshot, manifest)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, payload))
+	}
+
+	return manifest, nil
+}
+
+// signedPayload returns the data covered by the signature: the manifest without its
+// signature and the snapshot header
+func signedPayload(snapshot *Snapshot, manifest *SnapshotManifest) ([]byte, error) {
+	unsigned := *manifest
+	unsigned.Signature = ""
+
+	var base *SnapshotBase
+	if snapshot.Base != nil {
+		b := *snapshot.Base
+		b.CreatedAt = b.CreatedAt.UTC()
+		base = &b
+	}
+
+	return json.Marshal(struct {
+		Path      string
+		CreatedAt time.Time
+		At        time.Time
+		Base      *SnapshotBase `json:",omitempty"`
+		Removed   []string      `json:",omitempty"`
+		Manifest  SnapshotManifest
+	}{snapshot.Path, snapshot.CreatedAt.UTC(), snapshot.At.UTC(), base, snapshot.Removed, unsigned})
+}
+
+// Digest returns the SHA-256 digest of data, as "sha256:<hex>"
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// VerifyManifest checks a snapshot against its manifest: the number of secrets and
+// versions and the digest of every secret. If key is set, the manifest must also carry
+// a valid signature by it. Snapshots without a manifest pass unless a key is given.
+func (s *Snapshot) VerifyManifest(key ed25519.PublicKey) error {
+	m := s.Manifest
+	if m == nil {
+		if key != nil {
+			return fmt.Errorf("snapshot has no manifest and is not signed")
+		}
+		return nil
+	}
+
+	if m.Format > manifestFormat {
+		return fmt.Errorf("snapshot manifest format %d is newer than supported (%d), upgrade vlt", m.Format, manifestFormat)
+	}
+
+	versions := 0
+	var problems []string
+	for relPath, secret := range s.Secrets {
+		versions += len(secret.Versions)
+
+		expected, ok := m.Digests[relPath]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: not in manifest", relPath))
+			continue
+		}
+		digest, err := secretDigest(secret)
+		if err != nil || digest != expected {
+			problems = append(problems, fmt.Sprintf("%s: digest mismatch", relPath))
+		}
+	}
+	for relPath := range m.Digests {
+		if _, ok := s.Secrets[relPath]; !ok {
+			problems = append(problems, fmt.Sprintf("%s: missing from snapshot", relPath))
+		}
+	}
+
+	if len(s.Secrets) != m.Secrets {
+		problems = append(problems, fmt.Sprintf("snapshot has %d secrets, manifest expects %d", len(s.Secrets), m.Secrets))
+	}
+	if versions != m.Versions {
+		problems = append(problems, fmt.Sprintf("snapshot has %d versions, manifest expects %d", versions, m.Versions))
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("snapshot does not match its manifest:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	if key == nil {
+		return nil
+	}
+	if m.Signature == "" {
+		return fmt.Errorf("snapshot is not signed")
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid snapshot signature: %w", err)
+	}
+	payload, err := signedPayload(s, m)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key, payload, signature) {
+		return fmt.Errorf("snapshot signature does not match the verification key")
+	}
+
+	return nil
+}
+
+// ParseSigningKey parses a PEM encoded (PKCS #8) ed25519 private key,
+// as created by `openssl genpkey -algorithm ed25519`
+func ParseSigningKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in signing key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("signing key is not an ed25519 key")
+	}
+	return edKey, nil
+}
+
+// ParseVerifyKey parses a PEM encoded (PKIX) ed25519 public key,
+// as created by `openssl pkey -pubout`
+func ParseVerifyKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in verification key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification key: %w", err)
+	}
+	edKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("verification key is not an ed25519 key")
+	}
+	return edKey, nil
+}
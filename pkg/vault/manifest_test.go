@@ -0,0 +1,167 @@
+package vault
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testManifestSnapshot() *Snapshot {
+	updated := time.Date(2024, 1, 30, 10, 15, 23, 0, time.UTC)
+	return &Snapshot{
+		Manifest:  &SnapshotManifest{Cluster: "https://vault.example.com", Mount: "secret"},
+		Path:      "secret/myapp",
+		CreatedAt: updated,
+		Secrets: map[string]SnapshotSecret{
+			"config": {Value: "some-value", Version: 3, Updated: updated},
+			"database/password": {
+				Value:   map[string]any{"user": "admin", "port": json.Number("5432")},
+				Version: 2,
+				Updated: updated,
+				Versions: []SnapshotVersion{
+					{Version: 1, Created: updated.Add(-time.Hour), Deleted: true},
+					{Version: 2, Value: map[string]any{"user": "admin", "port": json.Number("5432")}, Created: updated},
+				},
+			},
+		},
+	}
+}
+
+func TestSnapshotManifest(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPublic, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalSnapshot(testManifestSnapshot(), SnapshotFileOptions{SigningKey: private, VltVersion: "1.2.3"})
+	if err != nil {
+		t.Fatalf("MarshalSnapshot() error = %v", err)
+	}
+
+	load := func(t *testing.T, data []byte) *Snapshot {
+		t.Helper()
+		snapshot, err := UnmarshalSnapshot(data, SnapshotLoadOptions{AllowPlaintext: true})
+		if err != nil {
+			t.Fatalf("UnmarshalSnapshot() error = %v", err)
+		}
+		return snapshot
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		snapshot := load(t, data)
+		m := snapshot.Manifest
+		if m.Secrets != 2 || m.Versions != 2 || m.VltVersion != "1.2.3" || m.Cluster != "https://vault.example.com" || m.Mount != "secret" {
+			t.Errorf("unexpected manifest %+v", m)
+		}
+		if err := snapshot.VerifyManifest(public); err != nil {
+			t.Errorf("VerifyManifest() error = %v", err)
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		if err := load(t, data).VerifyManifest(otherPublic); err == nil {
+			t.Error("expected a signature error with another key")
+		}
+	})
+
+	t.Run("edited value", func(t *testing.T) {
+		snapshot := load(t, data)
+		secret := snapshot.Secrets["config"]
+		secret.Value = "edited"
+		snapshot.Secrets["config"] = secret
+		if err := snapshot.VerifyManifest(nil); err == nil || !strings.Contains(err.Error(), "config: digest mismatch") {
+			t.Errorf("VerifyManifest() error = %v, want digest mismatch", err)
+		}
+	})
+
+	t.Run("missing secret", func(t *testing.T) {
+		snapshot := load(t, data)
+		delete(snapshot.Secrets, "database/password")
+		if err := snapshot.VerifyManifest(nil); err == nil || !strings.Contains(err.Error(), "database/password: missing from snapshot") {
+			t.Errorf("VerifyManifest() error = %v, want missing secret", err)
+		}
+	})
+
+	t.Run("edited header", func(t *testing.T) {
+		snapshot := load(t, data)
+		snapshot.Path = "secret/other"
+		if err := snapshot.VerifyManifest(nil); err != nil {
+			t.Errorf("VerifyManifest() without key error = %v", err)
+		}
+		if err := snapshot.VerifyManifest(public); err == nil {
+			t.Error("expected a signature error after editing the path")
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		truncated := data[:len(data)*3/4]
+		snapshot, err := UnmarshalSnapshot(truncated, SnapshotLoadOptions{AllowPlaintext: true})
+		if err == nil {
+			t.Errorf("expected an error loading a truncated snapshot, got %+v", snapshot)
+		}
+	})
+}
+
+func TestVerifyManifestWithoutManifest(t *testing.T) {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snapshot := testManifestSnapshot()
+	snapshot.Manifest = nil
+	if err := snapshot.VerifyManifest(nil); err != nil {
+		t.Errorf("VerifyManifest() error = %v, want nil for a snapshot without manifest", err)
+	}
+	if err := snapshot.VerifyManifest(public); err == nil {
+		t.Error("expected an error requiring a signature on a snapshot without manifest")
+	}
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gotPrivate, err := ParseSigningKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}))
+	if err != nil {
+		t.Fatalf("ParseSigningKey() error = %v", err)
+	}
+	if !gotPrivate.Equal(private) {
+		t.Error("ParseSigningKey() returned a different key")
+	}
+
+	gotPublic, err := ParseVerifyKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}))
+	if err != nil {
+		t.Fatalf("ParseVerifyKey() error = %v", err)
+	}
+	if !gotPublic.Equal(public) {
+		t.Error("ParseVerifyKey() returned a different key")
+	}
+
+	if _, err := ParseSigningKey([]byte("not a key")); err == nil {
+		t.Error("expected an error parsing invalid PEM")
+	}
+	if _, err := ParseVerifyKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})); err == nil {
+		t.Error("expected an error parsing a private key as a public key")
+	}
+}
@@ -0,0 +1,231 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyCreatedPrefix prefixes custom metadata keys recording the original creation
+// time of versions replayed from another secret (e.g. "vlt_created_v3")
+const historyCreatedPrefix = "vlt_created_v"
+
+// historyCreatedVersions is how many of the latest replayed versions keep their original
+// creation time. Older ones show when they were replayed instead. Together with
+// provenanceVersions, this leaves room in Vault's limit of 64 custom metadata keys.
+const historyCreatedVersions = 20
+
+// MetadataUpdate describes changes to a secret's metadata.
+// Nil settings are left unchanged.
+type MetadataUpdate struct {
+	MaxVersions        *int
+	CASRequired        *bool
+	DeleteVersionAfter *time.Duration
+	SetCustom          map[string]string // Custom metadata keys to add or overwrite
+	UnsetCustom        []string          // Custom metadata keys to remove
+}
+
+// IsEmpty returns true if the update changes nothing
+func (u MetadataUpdate) IsEmpty() bool {
+	return u.MaxVersions == nil && u.CASRequired == nil && u.DeleteVersionAfter == nil &&
+		len(u.SetCustom) == 0 && len(u.UnsetCustom) == 0
+}
+
+// metadataFields converts metadata into the fields accepted by the metadata endpoint
+func metadataFields(m *SecretMetadata) map[string]any {
+	custom := make(map[string]string, len(m.CustomMetadata))
+	for k, v := range m.CustomMetadata {
+		custom[k] = v
+	}
+
+	return map[string]any{
+		"max_versions":         m.MaxVersions,
+		"cas_required":         m.CASRequired,
+		"delete_version_after": m.DeleteVersionAfter.String(),
+		"custom_metadata":      custom,
+	}
+}
+
+// GetMetadataTree returns the metadata of all secrets under a path, keyed by relative path.
+// If the path is a single secret, its metadata is returned under the empty key.
+func (c *Client) GetMetadataTree(ctx context.Context, path string) (map[string]*SecretMetadata, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*SecretMetadata)
+
+	if len(secretPaths) == 0 {
+		metadata, err := c.GetMetadata(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		if metadata == nil {
+			return nil, fmt.Errorf("no secrets found at %s", path)
+		}
+		result[""] = metadata
+		return result, nil
+	}
+
+	for _, relPath := range secretPaths {
+		metadata, err := c.GetMetadata(ctx, path+"/"+relPath)
+		if err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			result[relPath] = metadata
+		}
+	}
+
+	return result, nil
+}
+
+// FlattenMetadata converts a metadata tree into a flat key->value map suitable for CompareSecrets.
+// Keys are the relative secret path followed by the setting name,
+// e.g. "db/password.max_versions" or "db/password.custom_metadata.owner".
+func FlattenMetadata(tree map[string]*SecretMetadata) map[string]any {
+	result := make(map[string]any)
+	for relPath, m := range tree {
+		prefix := ""
+		if relPath != "" {
+			prefix = relPath + "."
+		}
+
+		result[prefix+"max_versions"] = strconv.Itoa(m.MaxVersions)
+		result[prefix+"cas_required"] = strconv.FormatBool(m.CASRequired)
+		result[prefix+"delete_version_after"] = m.DeleteVersionAfter.String()
+		for k, v := range m.CustomMetadata {
+			result[prefix+"custom_metadata."+k] = v
+		}
+	}
+	return result
+}
+
+// UpdateMetadata applies a metadata update to a single secret
+func (c *Client) UpdateMetadata(ctx context.Context, path string, update MetadataUpdate) error {
+	existing, err := c.GetMetadata(ctx, path)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("secret does not exist: %s", path)
+	}
+
+	fields := make(map[string]any)
+	if update.MaxVersions != nil {
+		fields["max_versions"] = *update.MaxVersions
+	}
+	if update.CASRequired != nil {
+		fields["cas_required"] = *update.CASRequired
+	}
+	if update.DeleteVersionAfter != nil {
+		fields["delete_version_after"] = update.DeleteVersionAfter.String()
+	}
+
+	if len(update.SetCustom) > 0 || len(update.UnsetCustom) > 0 {
+		custom := make(map[string]string)
+		for k, v := range existing.CustomMetadata {
+			custom[k] = v
+		}
+		for k, v := range update.SetCustom {
+			custom[k] = v
+		}
+		for _, k := range update.UnsetCustom {
+			delete(custom, k)
+		}
+		fields["custom_metadata"] = custom
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return c.writeMetadata(ctx, path, fields)
+}
+
+// UpdateMetadataRecursive applies a metadata update to all secrets under a path.
+// If the path is a single secret, only that secret is updated.
+// Returns the paths of the updated secrets.
+func (c *Client) UpdateMetadataRecursive(ctx context.Context, path string, update MetadataUpdate) ([]string, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secretPaths) == 0 {
+		if err := c.UpdateMetadata(ctx, path, update); err != nil {
+			return nil, err
+		}
+		return []string{path}, nil
+	}
+
+	sort.Strings(secretPaths)
+
+	var updated []string
+	for _, relPath := range secretPaths {
+		fullPath := path + "/" + relPath
+		if err := c.UpdateMetadata(ctx, fullPath, update); err != nil {
+			return updated, err
+		}
+		updated = append(updated, fullPath)
+	}
+
+	return updated, nil
+}
+
+// copiedMetadataFields returns the metadata fields for a copy of src whose versions in
+// replayed were written to the copy in order. Unless reset, the settings and custom
+// metadata of src are kept. The original creation time of each of the last
+// historyCreatedVersions replayed versions is recorded under a historyCreatedPrefix key
+// for its new version number.
+func copiedMetadataFields(src *SecretMetadata, replayed []VersionInfo, reset bool) map[string]any {
+	if src == nil {
+		src = &SecretMetadata{}
+	}
+
+	fields := map[string]any{"custom_metadata": map[string]string{}}
+	if !reset {
+		fields = metadataFields(src)
+	}
+	custom := fields["custom_metadata"].(map[string]string)
+
+	// Times and provenance recorded on src refer to its own version numbers
+	for k := range custom {
+		if isVersionMetadataKey(k) {
+			delete(custom, k)
+		}
+	}
+
+	for i, v := range replayed {
+		custom[historyCreatedKey(i+1)] = v.WrittenTime().UTC().Format(time.RFC3339)
+
+		if p, ok := src.CustomMetadata[provenanceKey(v.Version)]; ok {
+			custom[provenanceKey(i+1)] = p
+		}
+	}
+	pruneHistoryCreated(custom, len(replayed))
+	pruneProvenance(custom, len(replayed))
+
+	return fields
+}
+
+// pruneHistoryCreated removes original creation times of versions older than the last
+// historyCreatedVersions
+func pruneHistoryCreated(custom map[string]string, latest int) {
+	pruneVersionKeys(custom, historyCreatedPrefix, latest-historyCreatedVersions)
+}
+
+// isVersionMetadataKey returns true if a custom metadata key is recorded by vlt for a
+// specific version number
+func isVersionMetadataKey(k string) bool {
+	return strings.HasPrefix(k, historyCreatedPrefix) || strings.HasPrefix(k, provenancePrefix)
+}
+
+// historyCreatedKey returns the custom metadata key holding the original creation time of a version
+func historyCreatedKey(version int) string {
+	return historyCreatedPrefix + strconv.Itoa(version)
+}
@@ -0,0 +1,124 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlattenMetadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    map[string]*SecretMetadata
+		expected map[string]any
+	}{
+		{
+			name: "single secret",
+			input: map[string]*SecretMetadata{
+				"": {MaxVersions: 5, CustomMetadata: map[string]string{"owner": "team-a"}},
+			},
+			expected: map[string]any{
+				"max_versions":          "5",
+				"cas_required":          "false",
+				"delete_version_after":  "0s",
+				"custom_metadata.owner": "team-a",
+			},
+		},
+		{
+			name: "tree",
+			input: map[string]*SecretMetadata{
+				"db/password": {CASRequired: true, DeleteVersionAfter: 48 * time.Hour},
+			},
+			expected: map[string]any{
+				"db/password.max_versions":         "0",
+				"db/password.cas_required":         "true",
+				"db/password.delete_version_after": "48h0m0s",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FlattenMetadata(tt.input)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("FlattenMetadata() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMetadataUpdateIsEmpty(t *testing.T) {
+	maxVersions := 3
+
+	if !(MetadataUpdate{}).IsEmpty() {
+		t.Error("zero update should be empty")
+	}
+	if (MetadataUpdate{MaxVersions: &maxVersions}).IsEmpty() {
+		t.Error("update with max versions should not be empty")
+	}
+	if (MetadataUpdate{UnsetCustom: []string{"owner"}}).IsEmpty() {
+		t.Error("update with unset keys should not be empty")
+	}
+}
+
+func TestCopiedMetadataFields(t *testing.T) {
+	t1 := time.Date(2024, 1, 28, 9, 0, 0, 0, time.UTC)
+	t2 := time.Date(2024, 1, 29, 14, 22, 1, 0, time.UTC)
+
+	src := &SecretMetadata{
+		MaxVersions: 5,
+		CustomMetadata: map[string]string{
+			"owner":          "team-a",
+			"vlt_created_v7": "2023-01-01T00:00:00Z",
+			"vlt_prov_v3":    `{"by":"alice"}`,
+			"vlt_prov_v7":    `{"by":"bob"}`,
+		},
+	}
+	replayed := []VersionInfo{
+		{Version: 2, CreatedTime: t1},
+		{Version: 3, CreatedTime: time.Now(), OriginalTime: t2},
+	}
+
+	t.Run("keep", func(t *testing.T) {
+		fields := copiedMetadataFields(src, replayed, false)
+		expected := map[string]string{
+			"owner":          "team-a",
+			"vlt_created_v1": "2024-01-28T09:00:00Z",
+			"vlt_created_v2": "2024-01-29T14:22:01Z",
+			"vlt_prov_v2":    `{"by":"alice"}`,
+		}
+		if !reflect.DeepEqual(fields["custom_metadata"], expected) {
+			t.Errorf("custom_metadata = %v, want %v", fields["custom_metadata"], expected)
+		}
+		if fields["max_versions"] != 5 {
+			t.Errorf("max_versions = %v, want 5", fields["max_versions"])
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		fields := copiedMetadataFields(src, replayed[:1], true)
+		expected := map[string]any{
+			"custom_metadata": map[string]string{"vlt_created_v1": "2024-01-28T09:00:00Z"},
+		}
+		if !reflect.DeepEqual(fields, expected) {
+			t.Errorf("fields = %v, want %v", fields, expected)
+		}
+	})
+	t.Run("long history", func(t *testing.T) {
+		var long []VersionInfo
+		for v := 1; v <= 100; v++ {
+			long = append(long, VersionInfo{Version: v, CreatedTime: t1.Add(time.Duration(v) * time.Hour)})
+		}
+
+		custom := copiedMetadataFields(src, long, true)["custom_metadata"].(map[string]string)
+		if len(custom) != historyCreatedVersions {
+			t.Errorf("copy of 100 versions has %d custom metadata keys, want %d", len(custom), historyCreatedVersions)
+		}
+		if _, ok := custom[historyCreatedKey(100)]; !ok {
+			t.Error("creation time of the latest version was dropped")
+		}
+		if _, ok := custom[historyCreatedKey(100-historyCreatedVersions)]; ok {
+			t.Errorf("creation time of version %d was kept", 100-historyCreatedVersions)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+// snapshotAddress is the address reported by clients serving a snapshot
+const snapshotAddress = "http://snapshot.invalid"
+
+// NewSnapshotClient returns a client that serves reads from a snapshot instead of a
+// Vault server. The snapshot is exposed under the path it was taken from, as a KV v2
+// mount; full-history snapshots also serve their version history. Writes are refused.
+func NewSnapshotClient(snapshot *Snapshot) (*Client, error) {
+	if snapshot.Path == "" {
+		return nil, fmt.Errorf("snapshot does not record the path it was taken from")
+	}
+
+	vaultCfg := api.DefaultConfig()
+	vaultCfg.Address = snapshotAddress
+	vaultCfg.MaxRetries = 0
+	vaultCfg.HttpClient = &http.Client{Transport: newSnapshotBackend(snapshot)}
+
+	client, err := api.NewClient(vaultCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot client: %w", err)
+	}
+
+	return &Client{client: client}, nil
+}
+
+// snapshotBackend answers the KV v2 API requests made by Client from a snapshot
+type snapshotBackend struct {
+	mount   string
+	secrets map[string]SnapshotSecret // Keyed by path within the mount
+}
+
+func newSnapshotBackend(snapshot *Snapshot) *snapshotBackend {
+	mount, _ := splitMountPath(snapshot.Path)
+	if snapshot.Manifest != nil && snapshot.Manifest.Mount != "" {
+		mount = snapshot.Manifest.Mount
+	}
+	prefix := strings.Trim(strings.TrimPrefix(snapshot.Path, mount), "/")
+
+	b := &snapshotBackend{mount: mount, secrets: make(map[string]SnapshotSecret, len(snapshot.Secrets))}
+	for relPath, secret := range snapshot.Secrets {
+		b.secrets[joinRelPath(prefix, relPath)] = secret
+	}
+	return b
+}
+
+// RoundTrip serves a request from the snapshot
+func (b *snapshotBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	if req.Method != http.MethodGet {
+		return snapshotResponse(req, http.StatusForbidden, map[string]any{
+			"errors": []string{"secrets are served from a snapshot, which is read-only"},
+		})
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v1/")
+	if path == "sys/mounts" {
+		return snapshotResponse(req, http.StatusOK, map[string]any{
+			"data": map[string]any{
+				b.mount + "/": map[string]any{"type": "kv", "options": map[string]string{"version": "2"}},
+			},
+		})
+	}
+
+	rest, ok := strings.CutPrefix(path, b.mount+"/")
+	if !ok {
+		return snapshotNotFound(req)
+	}
+
+	var data map[string]any
+	switch {
+	case strings.HasPrefix(rest, "data/"):
+		data = b.readData(strings.TrimPrefix(rest, "data/"), req.URL.Query().Get("version"))
+	case strings.HasPrefix(rest, "metadata/") && req.URL.Query().Get("list") == "true":
+		data = b.list(strings.Trim(strings.TrimPrefix(rest, "metadata/"), "/"))
+	case strings.HasPrefix(rest, "metadata/"):
+		data = b.readMetadata(strings.TrimPrefix(rest, "metadata/"))
+	}
+
+	if data == nil {
+		return snapshotNotFound(req)
+	}
+	return snapshotResponse(req, http.StatusOK, map[string]any{"data": data})
+}
+
+// readData returns the data of a secret version, nil if it is not in the snapshot
+func (b *snapshotBackend) readData(path, versionParam string) map[string]any {
+	secret, ok := b.secrets[path]
+	if !ok {
+		return nil
+	}
+
+	version := secret.Version
+	if versionParam != "" && versionParam != "0" {
+		v, err := strconv.Atoi(versionParam)
+		if err != nil {
+			return nil
+		}
+		version = v
+	}
+
+	if version == secret.Version {
+		return map[string]any{
+			"data":     secret.SecretData(),
+			"metadata": versionMetadata(version, secret.Updated, false),
+		}
+	}
+	for _, v := range secret.Versions {
+		if v.Version == version && !v.Deleted {
+			return map[string]any{
+				"data":     v.SecretData(),
+				"metadata": versionMetadata(version, v.Created, false),
+			}
+		}
+	}
+	return nil
+}
+
+// readMetadata returns the metadata of a secret, nil if it is not in the snapshot
+func (b *snapshotBackend) readMetadata(path string) map[string]any {
+	secret, ok := b.secrets[path]
+	if !ok {
+		return nil
+	}
+
+	versions := map[string]any{
+		strconv.Itoa(secret.Version): versionMetadata(secret.Version, secret.Updated, false),
+	}
+	created := secret.Updated
+	for _, v := range secret.Versions {
+		if v.Version != secret.Version {
+			versions[strconv.Itoa(v.Version)] = versionMetadata(v.Version, v.Created, v.Deleted)
+		}
+		if v.Created.Before(created) {
+			created = v.Created
+		}
+	}
+
+	metadata := secret.Metadata
+	if metadata == nil {
+		metadata = &SnapshotMetadata{}
+	}
+
+	return map[string]any{
+		"current_version":      secret.Version,
+		"max_versions":         metadata.MaxVersions,
+		"cas_required":         metadata.CASRequired,
+		"delete_version_after": metadata.DeleteVersionAfter.String(),
+		"custom_metadata":      metadata.Custom,
+		"created_time":         created.UTC().Format(time.RFC3339Nano),
+		"updated_time":         secret.Updated.UTC().Format(time.RFC3339Nano),
+		"versions":             versions,
+	}
+}
+
+// list returns the keys directly under a directory, nil if it holds no secrets
+func (b *snapshotBackend) list(dir string) map[string]any {
+	seen := make(map[string]bool)
+	for path := range b.secrets {
+		rest, ok := cutPathPrefix(path, dir)
+		if !ok || rest == "" {
+			continue
+		}
+		if child, _, isDir := strings.Cut(rest, "/"); isDir {
+			seen[child+"/"] = true
+		} else {
+			seen[child] = true
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return map[string]any{"keys": keys}
+}
+
+// versionMetadata returns the metadata Vault reports for a single version
+func versionMetadata(version int, created time.Time, deleted bool) map[string]any {
+	deletionTime := ""
+	if deleted {
+		deletionTime = created.UTC().Format(time.RFC3339Nano)
+	}
+	return map[string]any{
+		"version":       version,
+		"created_time":  created.UTC().Format(time.RFC3339Nano),
+		"deletion_time": deletionTime,
+		"destroyed":     false,
+	}
+}
+
+// snapshotNotFound answers like Vault for a path that does not exist
+func snapshotNotFound(req *http.Request) (*http.Response, error) {
+	return snapshotResponse(req, http.StatusNotFound, map[string]any{"errors": []string{}})
+}
+
+// snapshotResponse builds a JSON response to req
+func snapshotResponse(req *http.Request, status int, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
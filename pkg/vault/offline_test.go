@@ -0,0 +1,146 @@
+package vault
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotClient(t *testing.T) {
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	snapshot := &Snapshot{
+		Format: snapshotFormat,
+		Path:   "secret/app",
+		Secrets: map[string]SnapshotSecret{
+			"db/password": {
+				Data:    map[string]any{"value": "new"},
+				Version: 3,
+				Updated: updated,
+				Metadata: &SnapshotMetadata{
+					MaxVersions: 5,
+					CASRequired: true,
+					Custom:      map[string]string{"owner": "team-a"},
+				},
+				Versions: []SnapshotVersion{
+					{Version: 1, Data: map[string]any{"value": "old"}, Created: created},
+					{Version: 2, Created: created.Add(time.Hour), Deleted: true},
+					{Version: 3, Data: map[string]any{"value": "new"}, Created: updated},
+				},
+			},
+			"db/user": {Data: map[string]any{"value": "admin"}, Version: 1, Updated: created},
+			"token":   {Data: map[string]any{"value": "abc", "ttl": int64(60)}, Version: 1, Updated: created},
+		},
+	}
+
+	client, err := NewSnapshotClient(snapshot)
+	if err != nil {
+		t.Fatalf("NewSnapshotClient failed: %v", err)
+	}
+	ctx := context.Background()
+
+	paths, err := client.ListSecretPaths(ctx, "secret/app")
+	if err != nil {
+		t.Fatalf("ListSecretPaths failed: %v", err)
+	}
+	if want := []string{"db/password", "db/user", "token"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("ListSecretPaths = %v, want %v", paths, want)
+	}
+
+	if paths, _ := client.ListSecretPaths(ctx, "secret/other"); len(paths) != 0 {
+		t.Errorf("ListSecretPaths outside the snapshot = %v, want none", paths)
+	}
+
+	if isDir, _ := client.IsDirectory(ctx, "secret/app/db"); !isDir {
+		t.Error("secret/app/db should be a directory")
+	}
+	if isDir, _ := client.IsDirectory(ctx, "secret/app/token"); isDir {
+		t.Error("secret/app/token should not be a directory")
+	}
+
+	data, err := client.ReadSecretRaw(ctx, "secret/app/token")
+	if err != nil {
+		t.Fatalf("ReadSecretRaw failed: %v", err)
+	}
+	if data["value"] != "abc" || len(data) != 2 {
+		t.Errorf("ReadSecretRaw = %v", data)
+	}
+
+	if data, _ := client.ReadSecretRaw(ctx, "secret/app/missing"); data != nil {
+		t.Errorf("ReadSecretRaw of a missing secret = %v, want nil", data)
+	}
+
+	old, err := client.ReadSecretVersion(ctx, "secret/app/db/password", 1)
+	if err != nil {
+		t.Fatalf("ReadSecretVersion failed: %v", err)
+	}
+	if old["value"] != "old" {
+		t.Errorf("version 1 = %v, want old", old)
+	}
+	if deleted, _ := client.ReadSecretVersion(ctx, "secret/app/db/password", 2); deleted != nil {
+		t.Errorf("deleted version 2 = %v, want nil", deleted)
+	}
+
+	metadata, err := client.GetMetadata(ctx, "secret/app/db/password")
+	if err != nil {
+		t.Fatalf("GetMetadata failed: %v", err)
+	}
+	if metadata.CurrentVersion != 3 || metadata.MaxVersions != 5 || !metadata.CASRequired ||
+		metadata.CustomMetadata["owner"] != "team-a" || !metadata.CreatedTime.Equal(created) || !metadata.UpdatedTime.Equal(updated) {
+		t.Errorf("GetMetadata = %+v", metadata)
+	}
+
+	history, err := client.GetVersionHistory(ctx, "secret/app/db/password")
+	if err != nil {
+		t.Fatalf("GetVersionHistory failed: %v", err)
+	}
+	var versions []int
+	for _, v := range history {
+		versions = append(versions, v.Version)
+	}
+	if want := []int{3, 1}; !reflect.DeepEqual(versions, want) {
+		t.Errorf("GetVersionHistory versions = %v, want %v", versions, want)
+	}
+
+	tree, err := client.GetTree(ctx, "secret/app")
+	if err != nil {
+		t.Fatalf("GetTree failed: %v", err)
+	}
+	if len(tree.Children) != 2 || tree.Children[0].Name != "db/" || len(tree.Children[0].Children) != 2 {
+		t.Errorf("GetTree children = %+v", tree.Children)
+	}
+
+	if err := client.WriteSecret(ctx, "secret/app/token", map[string]any{"value": "x"}); err == nil {
+		t.Error("WriteSecret should be refused")
+	}
+	if err := client.DeleteSecret(ctx, "secret/app/token"); err == nil {
+		t.Error("DeleteSecret should be refused")
+	}
+}
+
+func TestSnapshotClientMount(t *testing.T) {
+	snapshot := &Snapshot{
+		Path:     "team/kv/app",
+		Manifest: &SnapshotManifest{Mount: "team/kv"},
+		Secrets: map[string]SnapshotSecret{
+			"key": {Data: map[string]any{"value": "v"}, Version: 1},
+		},
+	}
+
+	client, err := NewSnapshotClient(snapshot)
+	if err != nil {
+		t.Fatalf("NewSnapshotClient failed: %v", err)
+	}
+
+	mount, secretPath, err := client.ResolveMountPath(context.Background(), "team/kv/app/key")
+	if err != nil || mount != "team/kv" || secretPath != "app/key" {
+		t.Errorf("ResolveMountPath = %q, %q, %v", mount, secretPath, err)
+	}
+
+	value, err := client.GetValue(context.Background(), "team/kv/app/key", "value")
+	if err != nil || value != "v" {
+		t.Errorf("GetValue = %v, %v", value, err)
+	}
+}
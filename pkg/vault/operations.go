@@ -229,8 +229,60 @@ func (c *Client) checkDestinationsNotExist(ctx context.Context, dst string, relP
 	return nil
 }
 
+// CopyOptions configures how copy and move operations behave
+type CopyOptions struct {
+	ResetMetadata bool // Don't carry custom metadata and settings over to the destination
+	WithHistory   bool // Replay all versions onto the destination instead of only the latest
+}
+
+// writeCopy writes src to dst: the given latest data, or every version of src if
+// opts.WithHistory is set, followed by the metadata. On failure nothing is left at dst.
+func (c *Client) writeCopy(ctx context.Context, src, dst string, data map[string]any, opts CopyOptions) error {
+	var replayed []VersionInfo
+	if opts.WithHistory {
+		var err error
+		replayed, err = c.copyVersionHistory(ctx, src, dst)
+		if err != nil {
+			return c.discardCopy(ctx, dst, fmt.Errorf("failed to copy history of %s: %w", src, err))
+		}
+	} else if err := c.writeSecretCopy(ctx, dst, data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+
+	if opts.ResetMetadata && len(replayed) == 0 && c.provenance == nil {
+		return nil
+	}
+
+	metadata, err := c.GetMetadata(ctx, src)
+	if err == nil {
+		fields := copiedMetadataFields(metadata, replayed, opts.ResetMetadata)
+
+		// Versions without a recorded author were written by this copy
+		written := make([]int, max(len(replayed), 1))
+		for i := range written {
+			written[i] = i + 1
+		}
+		c.provenance.stamp(fields["custom_metadata"].(map[string]string), len(written), written...)
+
+		err = c.writeMetadata(ctx, dst, fields)
+	}
+	if err != nil {
+		return c.discardCopy(ctx, dst, fmt.Errorf("failed to copy metadata to %s: %w", dst, err))
+	}
+
+	return nil
+}
+
+// discardCopy removes a partially written copy at dst and returns err
+func (c *Client) discardCopy(ctx context.Context, dst string, err error) error {
+	if rollbackErr := c.purgeSecret(ctx, dst); rollbackErr != nil {
+		return fmt.Errorf("%w (cleanup of %s failed: %v)", err, dst, rollbackErr)
+	}
+	return err
+}
+
 // copySecrets copies secrets from src to dst for the given relative paths
-func (c *Client) copySecrets(ctx context.Context, src, dst string, relPaths []string) error {
+func (c *Client) copySecrets(ctx context.Context, src, dst string, relPaths []string, opts CopyOptions) error {
 	for _, relPath := range relPaths {
 		srcPath := src + "/" + relPath
 		dstPath := dst + "/" + relPath
@@ -240,16 +292,85 @@ func (c *Client) copySecrets(ctx context.Context, src, dst string, relPaths []st
 			return err
 		}
 
-		if err := c.WriteSecret(ctx, dstPath, srcData); err != nil {
-			return fmt.Errorf("failed to write %s: %w", dstPath, err)
+		if err := c.writeCopy(ctx, srcPath, dstPath, srcData, opts); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// Copy copies a single secret from src to dst.
+// copyVersionHistory replays every version of src onto dst, oldest first. Versions that
+// were deleted or destroyed are written as empty placeholders and deleted or destroyed
+// again, so dst keeps the version numbers of src. Returns the source versions that were
+// written, in order.
+func (c *Client) copyVersionHistory(ctx context.Context, src, dst string) ([]VersionInfo, error) {
+	versions, err := c.getAllVersions(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	mount, secretPath, _ := c.ResolveMountPath(ctx, dst)
+
+	var written []VersionInfo
+	var deleted, destroyed []int
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		var data map[string]any
+		if !v.Deleted && !v.Destroyed {
+			if data, err = c.ReadSecretVersion(ctx, src, v.Version); err != nil {
+				return written, err
+			}
+		}
+
+		removed := data == nil
+		if removed {
+			data = map[string]any{}
+		}
+		version, err := c.writeSecretData(ctx, mount, secretPath, data)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, v)
+
+		switch {
+		case v.Destroyed:
+			destroyed = append(destroyed, version)
+		case removed:
+			deleted = append(deleted, version)
+		}
+	}
+
+	if len(deleted) > 0 {
+		if err := c.removeVersions(ctx, dst, deleted, false); err != nil {
+			return written, err
+		}
+	}
+	if len(destroyed) > 0 {
+		if err := c.removeVersions(ctx, dst, destroyed, true); err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// writeSecretCopy writes a version of a copied secret. Provenance is recorded
+// with the copied metadata instead.
+func (c *Client) writeSecretCopy(ctx context.Context, dst string, data map[string]any) error {
+	mount, secretPath, _ := c.ResolveMountPath(ctx, dst)
+	_, err := c.writeSecretData(ctx, mount, secretPath, data)
+	return err
+}
+
+// Copy copies a single secret from src to dst, including its metadata.
 // Returns an error if the destination already exists.
 func (c *Client) Copy(ctx context.Context, src, dst string) error {
+	return c.CopyWithOptions(ctx, src, dst, CopyOptions{})
+}
+
+// CopyWithOptions copies a single secret from src to dst.
+// Returns an error if the destination already exists.
+func (c *Client) CopyWithOptions(ctx context.Context, src, dst string, opts CopyOptions) error {
 	srcData, err := c.readAndValidateSource(ctx, src)
 	if err != nil {
 		return err
@@ -259,12 +380,18 @@ func (c *Client) Copy(ctx context.Context, src, dst string) error {
 		return err
 	}
 
-	return c.WriteSecret(ctx, dst, srcData)
+	return c.writeCopy(ctx, src, dst, srcData, opts)
 }
 
-// CopyRecursive copies all secrets under src to dst.
+// CopyRecursive copies all secrets under src to dst, including their metadata.
 // Returns the number of secrets copied.
 func (c *Client) CopyRecursive(ctx context.Context, src, dst string) (int, error) {
+	return c.CopyRecursiveWithOptions(ctx, src, dst, CopyOptions{})
+}
+
+// CopyRecursiveWithOptions copies all secrets under src to dst.
+// Returns the number of secrets copied.
+func (c *Client) CopyRecursiveWithOptions(ctx context.Context, src, dst string, opts CopyOptions) (int, error) {
 	secretPaths, err := c.ListSecretPaths(ctx, src)
 	if err != nil {
 		return 0, err
@@ -272,7 +399,7 @@ func (c *Client) CopyRecursive(ctx context.Context, src, dst string) (int, error
 
 	if len(secretPaths) == 0 {
 		// Try as a single secret
-		if err := c.Copy(ctx, src, dst); err != nil {
+		if err := c.CopyWithOptions(ctx, src, dst, opts); err != nil {
 			return 0, err
 		}
 		return 1, nil
@@ -282,16 +409,22 @@ func (c *Client) CopyRecursive(ctx context.Context, src, dst string) (int, error
 		return 0, err
 	}
 
-	if err := c.copySecrets(ctx, src, dst, secretPaths); err != nil {
+	if err := c.copySecrets(ctx, src, dst, secretPaths, opts); err != nil {
 		return 0, err
 	}
 
 	return len(secretPaths), nil
 }
 
-// Move moves a single secret from src to dst.
+// Move moves a single secret from src to dst, including its metadata.
 // Returns an error if the destination already exists.
 func (c *Client) Move(ctx context.Context, src, dst string) error {
+	return c.MoveWithOptions(ctx, src, dst, CopyOptions{})
+}
+
+// MoveWithOptions moves a single secret from src to dst.
+// Returns an error if the destination already exists.
+func (c *Client) MoveWithOptions(ctx context.Context, src, dst string, opts CopyOptions) error {
 	srcData, err := c.readAndValidateSource(ctx, src)
 	if err != nil {
 		return err
@@ -301,24 +434,34 @@ func (c *Client) Move(ctx context.Context, src, dst string) error {
 		return err
 	}
 
-	if err := c.WriteSecret(ctx, dst, srcData); err != nil {
+	if err := c.writeCopy(ctx, src, dst, srcData, opts); err != nil {
 		return err
 	}
 
 	if err := c.DeleteSecret(ctx, src); err != nil {
 		// Try to clean up the destination we just created
-		if rollbackErr := c.DeleteSecret(ctx, dst); rollbackErr != nil {
+		if rollbackErr := c.purgeSecret(ctx, dst); rollbackErr != nil {
 			return fmt.Errorf("failed to delete source (%w) and rollback failed: %v", err, rollbackErr)
 		}
 		return fmt.Errorf("failed to delete source after copy: %w", err)
 	}
 
+	if err := c.recordMove(ctx, src, dst, opts); err != nil {
+		return fmt.Errorf("moved %s to %s but failed to record the move: %w", src, dst, err)
+	}
+
 	return nil
 }
 
-// MoveRecursive moves all secrets under src to dst.
+// MoveRecursive moves all secrets under src to dst, including their metadata.
 // Returns the number of secrets moved.
 func (c *Client) MoveRecursive(ctx context.Context, src, dst string) (int, error) {
+	return c.MoveRecursiveWithOptions(ctx, src, dst, CopyOptions{})
+}
+
+// MoveRecursiveWithOptions moves all secrets under src to dst.
+// Returns the number of secrets moved.
+func (c *Client) MoveRecursiveWithOptions(ctx context.Context, src, dst string, opts CopyOptions) (int, error) {
 	secretPaths, err := c.ListSecretPaths(ctx, src)
 	if err != nil {
 		return 0, err
@@ -343,18 +486,18 @@ func (c *Client) MoveRecursive(ctx context.Context, src, dst string) (int, error
 			return 0, err
 		}
 
-		if err := c.WriteSecret(ctx, dstPath, srcData); err != nil {
+		if err := c.writeCopy(ctx, srcPath, dstPath, srcData, opts); err != nil {
 			// Rollback: delete already copied secrets
 			var rollbackErrors []string
 			for _, copied := range copiedPaths {
-				if rollbackErr := c.DeleteSecret(ctx, copied); rollbackErr != nil {
+				if rollbackErr := c.purgeSecret(ctx, copied); rollbackErr != nil {
 					rollbackErrors = append(rollbackErrors, fmt.Sprintf("%s: %v", copied, rollbackErr))
 				}
 			}
 			if len(rollbackErrors) > 0 {
-				return 0, fmt.Errorf("failed to write %s (%w) and rollback failed for: %v", dstPath, err, rollbackErrors)
+				return 0, fmt.Errorf("%w (rollback failed for: %v)", err, rollbackErrors)
 			}
-			return 0, fmt.Errorf("failed to write %s: %w", dstPath, err)
+			return 0, err
 		}
 		copiedPaths = append(copiedPaths, dstPath)
 	}
@@ -362,14 +505,18 @@ func (c *Client) MoveRecursive(ctx context.Context, src, dst string) (int, error
 	// Delete source secrets
 	// Note: If deletion fails partway, copies at destination will remain.
 	// This is intentional - it's safer to have duplicates than data loss.
-	var deleteErrors []string
+	var deleteErrors, recordErrors []string
 	deletedCount := 0
 	for _, relPath := range secretPaths {
 		srcPath := src + "/" + relPath
 		if err := c.DeleteSecret(ctx, srcPath); err != nil {
 			deleteErrors = append(deleteErrors, fmt.Sprintf("%s: %v", srcPath, err))
-		} else {
-			deletedCount++
+			continue
+		}
+		deletedCount++
+
+		if err := c.recordMove(ctx, srcPath, dst+"/"+relPath, opts); err != nil {
+			recordErrors = append(recordErrors, fmt.Sprintf("%s: %v", srcPath, err))
 		}
 	}
 
@@ -378,6 +525,10 @@ func (c *Client) MoveRecursive(ctx context.Context, src, dst string) (int, error
 			deletedCount, len(secretPaths), deleteErrors)
 	}
 
+	if len(recordErrors) > 0 {
+		return deletedCount, fmt.Errorf("moved %d secrets but failed to record the move for: %v", deletedCount, recordErrors)
+	}
+
 	return len(secretPaths), nil
 }
 
@@ -0,0 +1,275 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeSpecLayouts are the absolute timestamp formats accepted by ParseTimeSpec.
+// Layouts without a zone are interpreted in local time.
+var timeSpecLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTimeSpec parses a point in time: an absolute timestamp (e.g. "2024-01-30T14:00:00Z",
+// "2024-01-30 14:00") or a relative one (e.g. "2h ago", "{30d ago}")
+func ParseTimeSpec(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "{"), "}")
+
+	if rel, ok := strings.CutSuffix(s, " ago"); ok {
+		d, err := ParseDuration(rel)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %w", s, err)
+		}
+		return time.Now().Add(-d), nil
+	}
+
+	for _, layout := range timeSpecLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q (use e.g. 2024-01-30T14:00:00Z or \"2h ago\")", s)
+}
+
+// versionAt returns the version that was current at time t: the latest version created
+// at or before t. Returns false if the secret did not exist yet or that version was
+// deleted by then. Versions must be sorted newest first and include deleted and
+// destroyed versions, as returned by getAllVersions.
+func versionAt(versions []VersionInfo, t time.Time) (VersionInfo, bool) {
+	for _, v := range versions {
+		if v.CreatedTime.After(t) {
+			continue
+		}
+		if !v.DeletedTime.IsZero() && !v.DeletedTime.After(t) {
+			return VersionInfo{}, false
+		}
+		return v, true
+	}
+	return VersionInfo{}, false
+}
+
+// unreadableAt returns an error if version v of a secret, current at the point described
+// by when, has been deleted or destroyed since. Reading an older version instead would
+// misreport the state.
+func unreadableAt(path string, v VersionInfo, when string) error {
+	switch {
+	case v.Destroyed:
+		return fmt.Errorf("%s was at version %d %s, which has since been destroyed", path, v.Version, when)
+	case v.Deleted:
+		return fmt.Errorf("%s was at version %d %s, which has since been deleted (undelete it to read it)", path, v.Version, when)
+	}
+	return nil
+}
+
+// VersionAt returns the version of a secret that was current at time t, which may have
+// been deleted or destroyed since. Returns false if the secret did not exist or was
+// deleted at that time.
+func (c *Client) VersionAt(ctx context.Context, path string, t time.Time) (VersionInfo, bool, error) {
+	versions, err := c.getAllVersions(ctx, path)
+	if err != nil {
+		return VersionInfo{}, false, err
+	}
+
+	v, ok := versionAt(versions, t)
+	return v, ok, nil
+}
+
+// ReadSecretAt reads a secret as it was at time t.
+// Returns nil if the secret did not exist at that time, and an error if the
+// version current at that time can't be read anymore.
+func (c *Client) ReadSecretAt(ctx context.Context, path string, t time.Time) (map[string]any, error) {
+	v, ok, err := c.VersionAt(ctx, path, t)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return c.readVersionAt(ctx, path, v, t)
+}
+
+// readVersionAt reads version v of a secret, which was current at time t
+func (c *Client) readVersionAt(ctx context.Context, path string, v VersionInfo, t time.Time) (map[string]any, error) {
+	when := "at " + t.Format(time.RFC3339)
+	if err := unreadableAt(path, v, when); err != nil {
+		return nil, err
+	}
+
+	data, err := c.ReadSecretVersion(ctx, path, v.Version)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%s was at version %d %s, which can't be read anymore", path, v.Version, when)
+	}
+	return data, nil
+}
+
+// GetValueAt retrieves a specific key from a secret as it was at time t
+func (c *Client) GetValueAt(ctx context.Context, path, key string, t time.Time) (any, error) {
+	data, err := c.ReadSecretAt(ctx, path, t)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("secret not found at %s at %s", path, t.Format(time.RFC3339))
+	}
+
+	value, ok := data[key]
+	if !ok {
+		return nil, fmt.Errorf("key %q not found in secret at %s", key, path)
+	}
+
+	return value, nil
+}
+
+// GetAt retrieves all secrets at a path recursively as they were at time t,
+// in the same nested format as Get.
+func (c *Client) GetAt(ctx context.Context, path string, t time.Time) (map[string]any, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secretPaths) == 0 {
+		data, err := c.ReadSecretAt(ctx, path, t)
+		if err != nil {
+			return nil, err
+		}
+		return expandSecrets(data), nil
+	}
+
+	// Build the same raw structure listRecursive returns, then expand it
+	raw := make(map[string]any)
+	for _, relPath := range secretPaths {
+		data, err := c.ReadSecretAt(ctx, path+"/"+relPath, t)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		nestSecret(raw, relPath, data)
+	}
+
+	return expandSecrets(raw), nil
+}
+
+// nestSecret places the data of a secret in raw under its relative path,
+// in the structure listRecursive returns
+func nestSecret(raw map[string]any, relPath string, data map[string]any) {
+	parts := strings.Split(relPath, "/")
+	current := raw
+	for _, dir := range parts[:len(parts)-1] {
+		next, ok := current[dir].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			current[dir] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = data
+}
+
+// GetTreeAt builds a tree of the secrets that existed under a path at time t.
+// Each leaf carries the metadata of the version that was current at that time.
+func (c *Client) GetTreeAt(ctx context.Context, path string, t time.Time) (*TreeNode, error) {
+	path = strings.TrimSuffix(path, "/")
+
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(path, "/")
+	root := &TreeNode{
+		Name:     parts[len(parts)-1] + "/",
+		FullPath: path,
+		IsDir:    true,
+		Children: make([]*TreeNode, 0),
+	}
+
+	versions := make(map[string]VersionInfo)
+	for _, relPath := range secretPaths {
+		v, ok, err := c.VersionAt(ctx, path+"/"+relPath, t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			addPathToTree(root, path, relPath)
+			versions[path+"/"+relPath] = v
+		}
+	}
+
+	sortTree(root)
+
+	root.Walk(func(node *TreeNode, depth int, isLast bool) {
+		if v, ok := versions[node.FullPath]; ok && !node.IsDir {
+			node.Metadata = &SecretMetadata{
+				CurrentVersion: v.Version,
+				UpdatedTime:    v.CreatedTime,
+			}
+		}
+	})
+
+	return root, nil
+}
+
+// CreateSnapshotAt creates a snapshot of all secrets under a path as they were at time t
+func (c *Client) CreateSnapshotAt(ctx context.Context, path string, t time.Time) (*Snapshot, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	snapshot := &Snapshot{
+		Manifest:  c.snapshotSource(ctx, path),
+		Format:    snapshotFormat,
+		Path:      path,
+		CreatedAt: time.Now(),
+		At:        t,
+		Secrets:   make(map[string]SnapshotSecret),
+	}
+
+	for _, relPath := range secretPaths {
+		fullPath := path + "/" + relPath
+
+		history, err := c.getAllVersions(ctx, fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get history for %s: %w", relPath, err)
+		}
+		v, ok := versionAt(history, t)
+		if !ok {
+			continue
+		}
+
+		data, err := c.readVersionAt(ctx, fullPath, v, t)
+		if err != nil {
+			return nil, err
+		}
+
+		secret := SnapshotSecret{
+			Data:    snapshotFields(data),
+			Version: v.Version,
+			Updated: v.CreatedTime,
+		}
+		if current := history[0].Version; current != v.Version {
+			secret.Current = current
+		}
+		snapshot.Secrets[relPath] = secret
+	}
+
+	if len(snapshot.Secrets) == 0 {
+		return nil, fmt.Errorf("no secrets existed at %s at %s", path, t.Format(time.RFC3339))
+	}
+
+	return snapshot, nil
+}
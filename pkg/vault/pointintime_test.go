@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeSpec(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"rfc3339", "2024-01-30T14:00:00Z", time.Date(2024, 1, 30, 14, 0, 0, 0, time.UTC), false},
+		{"minutes with zone", "2024-01-30T10:00Z", time.Date(2024, 1, 30, 10, 0, 0, 0, time.UTC), false},
+		{"local date", "2024-01-30", time.Date(2024, 1, 30, 0, 0, 0, 0, time.Local), false},
+		{"local time", "2024-01-30 14:00", time.Date(2024, 1, 30, 14, 0, 0, 0, time.Local), false},
+		{"garbage", "yesterday", time.Time{}, true},
+		{"bad relative", "{soon ago}", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimeSpec(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeSpec(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !got.Equal(tt.expected) {
+				t.Errorf("ParseTimeSpec(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTimeSpecRelative(t *testing.T) {
+	for _, input := range []string{"2h ago", "{2h ago}"} {
+		got, err := ParseTimeSpec(input)
+		if err != nil {
+			t.Fatalf("ParseTimeSpec(%q) error = %v", input, err)
+		}
+		if ago := time.Since(got); ago < 2*time.Hour || ago > 2*time.Hour+time.Minute {
+			t.Errorf("ParseTimeSpec(%q) = %v ago, want about 2h", input, ago)
+		}
+	}
+}
+
+func TestVersionAt(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	versions := []VersionInfo{
+		{Version: 4, CreatedTime: base.Add(5 * time.Hour)},
+		{Version: 3, CreatedTime: base.Add(2 * time.Hour), Deleted: true, DeletedTime: base.Add(3 * time.Hour)},
+		{Version: 2, CreatedTime: base.Add(time.Hour), Destroyed: true},
+		{Version: 1, CreatedTime: base},
+	}
+
+	tests := []struct {
+		name     string
+		at       time.Time
+		expected int
+		found    bool
+	}{
+		{"before creation", base.Add(-time.Minute), 0, false},
+		{"exactly at creation", base, 1, true},
+		{"destroyed since", base.Add(90 * time.Minute), 2, true},
+		{"deleted since", base.Add(150 * time.Minute), 3, true},
+		{"deleted at the time", base.Add(4 * time.Hour), 0, false},
+		{"exactly at deletion", base.Add(3 * time.Hour), 0, false},
+		{"after latest", base.Add(24 * time.Hour), 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, ok := versionAt(versions, tt.at)
+			if ok != tt.found || v.Version != tt.expected {
+				t.Errorf("versionAt() = v%d, %v, want v%d, %v", v.Version, ok, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestUnreadableAt(t *testing.T) {
+	at := "at 2024-01-30T12:00:00Z"
+
+	if err := unreadableAt("secret/a", VersionInfo{Version: 2}, at); err != nil {
+		t.Errorf("unreadableAt() = %v for a readable version", err)
+	}
+	if err := unreadableAt("secret/a", VersionInfo{Version: 2, Deleted: true}, at); err == nil {
+		t.Error("unreadableAt() = nil for a deleted version")
+	}
+	if err := unreadableAt("secret/a", VersionInfo{Version: 2, Destroyed: true}, at); err == nil {
+		t.Error("unreadableAt() = nil for a destroyed version")
+	}
+}
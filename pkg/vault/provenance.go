@@ -0,0 +1,186 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	// provenancePrefix prefixes custom metadata keys recording who wrote a version
+	// (e.g. "vlt_prov_v3")
+	provenancePrefix = "vlt_prov_v"
+
+	// provenanceVersions is how many of the latest versions keep their provenance.
+	// Vault allows at most 64 custom metadata keys per secret.
+	provenanceVersions = 20
+
+	// maxMetadataValue is the maximum length of a custom metadata value
+	maxMetadataValue = 512
+)
+
+// Provenance records who made a write, from where, with which command and why
+type Provenance struct {
+	Author  string `json:"by,omitempty"`     // Token display name
+	Entity  string `json:"entity,omitempty"` // Identity entity ID of the token
+	Host    string `json:"host,omitempty"`
+	Command string `json:"cmd,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// String formats the provenance for display, e.g. "alice on laptop via vlt update: rotate key"
+func (p *Provenance) String() string {
+	author := p.Author
+	if author == "" {
+		author = p.Entity
+	}
+	if author == "" {
+		author = "unknown"
+	}
+
+	s := "by " + author
+	if p.Host != "" {
+		s += " on " + p.Host
+	}
+	if p.Command != "" {
+		s += " via " + p.Command
+	}
+	if p.Reason != "" {
+		s += ": " + p.Reason
+	}
+	return s
+}
+
+// encode returns the provenance as a custom metadata value, shortening the
+// reason if needed to fit the value size limit
+func (p *Provenance) encode() string {
+	q := *p
+	for {
+		data, _ := json.Marshal(q)
+		if len(data) <= maxMetadataValue || q.Reason == "" {
+			return string(data)
+		}
+		r := []rune(q.Reason)
+		q.Reason = string(r[:len(r)*3/4])
+	}
+}
+
+// parseProvenance decodes a custom metadata value written by encode
+func parseProvenance(s string) *Provenance {
+	var p Provenance
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return nil
+	}
+	return &p
+}
+
+// provenanceKey returns the custom metadata key holding the provenance of a version
+func provenanceKey(version int) string {
+	return provenancePrefix + strconv.Itoa(version)
+}
+
+// stamp records p for the given versions in custom metadata, keeping existing
+// entries, and drops entries of versions older than the last provenanceVersions.
+// Does nothing if p is nil.
+func (p *Provenance) stamp(custom map[string]string, latest int, versions ...int) {
+	if p == nil {
+		return
+	}
+	for _, v := range versions {
+		if _, ok := custom[provenanceKey(v)]; !ok {
+			custom[provenanceKey(v)] = p.encode()
+		}
+	}
+	pruneProvenance(custom, latest)
+}
+
+// pruneProvenance removes provenance entries of versions older than the last provenanceVersions
+func pruneProvenance(custom map[string]string, latest int) {
+	pruneVersionKeys(custom, provenancePrefix, latest-provenanceVersions)
+}
+
+// pruneVersionKeys removes the custom metadata keys with a prefix that are recorded
+// for versions up to and including oldest
+func pruneVersionKeys(custom map[string]string, prefix string, oldest int) {
+	for k := range custom {
+		if v, ok := strings.CutPrefix(k, prefix); ok {
+			if n, err := strconv.Atoi(v); err == nil && n <= oldest {
+				delete(custom, k)
+			}
+		}
+	}
+}
+
+// LookupProvenance describes writes made by this client: the token's display name and
+// entity (from lookup-self), the hostname, the given command and reason.
+// Lookup failures leave the corresponding fields empty.
+func (c *Client) LookupProvenance(ctx context.Context, command, reason string) *Provenance {
+	p := &Provenance{Command: command, Reason: reason}
+
+	if secret, err := c.client.Auth().Token().LookupSelfWithContext(ctx); err == nil && secret != nil {
+		p.Author, _ = secret.Data["display_name"].(string)
+		p.Entity, _ = secret.Data["entity_id"].(string)
+	}
+
+	p.Host, _ = os.Hostname()
+
+	return p
+}
+
+// SetProvenance makes the client record p on every secret version it writes.
+// Pass nil to stop recording.
+func (c *Client) SetProvenance(p *Provenance) {
+	c.provenance = p
+}
+
+// SetProvenanceWarning sets a function to call when provenance can't be recorded on a
+// version that was written, e.g. because the token may write data but not metadata.
+// The write itself has succeeded by then, so it is not reported as failed.
+func (c *Client) SetProvenanceWarning(warn func(error)) {
+	c.provenanceWarn = warn
+}
+
+// recordProvenance stamps the client's provenance on a newly written version of a secret.
+// This is best-effort: the version is already written, so failures are passed to the
+// provenance warning, if set.
+func (c *Client) recordProvenance(ctx context.Context, mount, path string, version int) {
+	if err := c.stampProvenance(ctx, mount, path, version); err != nil && c.provenanceWarn != nil {
+		c.provenanceWarn(err)
+	}
+}
+
+// stampProvenance records the client's provenance on a version in the secret's metadata
+func (c *Client) stampProvenance(ctx context.Context, mount, path string, version int) error {
+	if c.provenance == nil || version == 0 {
+		return nil
+	}
+
+	metadataPath := fmt.Sprintf("%s/metadata/%s", mount, path)
+	secret, err := c.client.Logical().ReadWithContext(ctx, metadataPath)
+	if err != nil {
+		return fmt.Errorf("failed to read metadata at %s/%s: %w", mount, path, err)
+	}
+
+	custom := make(map[string]string)
+	if secret != nil && secret.Data != nil {
+		if existing, ok := secret.Data["custom_metadata"].(map[string]any); ok {
+			for k, v := range existing {
+				custom[k] = fmt.Sprintf("%v", v)
+			}
+		}
+	}
+
+	c.provenance.stamp(custom, version, version)
+
+	_, err = c.client.Logical().WriteWithContext(ctx, metadataPath, map[string]any{
+		"custom_metadata": custom,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record provenance at %s/%s: %w", mount, path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethanadams/vlt/pkg/config"
+)
+
+func TestProvenanceString(t *testing.T) {
+	tests := []struct {
+		name       string
+		provenance Provenance
+		expected   string
+	}{
+		{
+			name:       "full",
+			provenance: Provenance{Author: "alice", Host: "laptop", Command: "vlt update", Reason: "rotate key"},
+			expected:   "by alice on laptop via vlt update: rotate key",
+		},
+		{
+			name:       "entity only",
+			provenance: Provenance{Entity: "1234-abcd", Command: "vlt add"},
+			expected:   "by 1234-abcd via vlt add",
+		},
+		{
+			name:       "unknown author",
+			provenance: Provenance{Host: "ci"},
+			expected:   "by unknown on ci",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.provenance.String(); got != tt.expected {
+				t.Errorf("String() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProvenanceEncode(t *testing.T) {
+	p := &Provenance{Author: "alice", Host: "laptop", Command: "vlt update", Reason: "rotate key"}
+
+	got := parseProvenance(p.encode())
+	if !reflect.DeepEqual(got, p) {
+		t.Errorf("parseProvenance(encode()) = %+v, want %+v", got, p)
+	}
+
+	long := &Provenance{Author: "alice", Reason: strings.Repeat("é", 1000)}
+	encoded := long.encode()
+	if len(encoded) > maxMetadataValue {
+		t.Errorf("encode() length = %d, want at most %d", len(encoded), maxMetadataValue)
+	}
+	if decoded := parseProvenance(encoded); decoded == nil || decoded.Author != "alice" || decoded.Reason == "" {
+		t.Errorf("parseProvenance() = %+v, want shortened reason", decoded)
+	}
+
+	if parseProvenance("not json") != nil {
+		t.Error("parseProvenance() expected nil for invalid value")
+	}
+}
+
+func TestProvenanceStamp(t *testing.T) {
+	p := &Provenance{Author: "alice"}
+
+	custom := map[string]string{
+		"owner":        "team-a",
+		"vlt_prov_v1":  `{"by":"bob"}`,
+		"vlt_prov_v10": `{"by":"bob"}`,
+	}
+	p.stamp(custom, 25, 10, 25)
+
+	// Existing entries are kept, entries older than the last 20 versions are dropped
+	expected := map[string]string{
+		"owner":        "team-a",
+		"vlt_prov_v10": `{"by":"bob"}`,
+		"vlt_prov_v25": `{"by":"alice"}`,
+	}
+	if !reflect.DeepEqual(custom, expected) {
+		t.Errorf("stamp() = %v, want %v", custom, expected)
+	}
+
+	var disabled *Provenance
+	disabled.stamp(custom, 26, 26)
+	if _, ok := custom["vlt_prov_v26"]; ok {
+		t.Error("stamp() on nil provenance should not record anything")
+	}
+}
+
+func TestRecordProvenanceBestEffort(t *testing.T) {
+	// A token that may write data but not metadata
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/v1/secret/data/") {
+			_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"version": 3}})
+			return
+		}
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []string{"permission denied"}})
+	}))
+	defer server.Close()
+
+	client, err := NewClient(&config.Config{VaultAddr: server.URL, VaultToken: "token"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	client.SetProvenance(&Provenance{Author: "alice"})
+
+	var warnings []error
+	client.SetProvenanceWarning(func(err error) { warnings = append(warnings, err) })
+
+	if err := client.WriteSecretWithMount(context.Background(), "secret", "app", map[string]any{"a": "b"}); err != nil {
+		t.Errorf("WriteSecretWithMount() error = %v, want the write to succeed", err)
+	}
+	if len(warnings) != 1 {
+		t.Errorf("got %d provenance warnings, want 1", len(warnings))
+	}
+}
@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// PruneOptions describes a version retention policy.
+// A version is pruned only if it matches every policy that is set.
+// The current version is never pruned.
+type PruneOptions struct {
+	Keep      int           // Keep the N newest versions, 0 to not limit by count
+	OlderThan time.Duration // Only prune versions older than this, 0 to not limit by age
+	Destroy   bool          // Permanently destroy versions instead of soft-deleting them
+	DryRun    bool          // Only report which versions would be pruned
+}
+
+// PrunedSecret lists the versions pruned from a secret
+type PrunedSecret struct {
+	Path     string
+	Versions []int // Sorted ascending
+}
+
+// pruneCandidates returns the versions matching a retention policy, sorted ascending.
+// Versions must be sorted newest first and include deleted ones, as returned by
+// getAllVersions. Keep counts readable versions only. Already destroyed versions
+// are skipped, and deleted versions are only returned when destroying.
+func pruneCandidates(versions []VersionInfo, opts PruneOptions, now time.Time) []int {
+	var result []int
+	kept := 0
+
+	for i, v := range versions {
+		if v.Destroyed {
+			continue
+		}
+
+		// Never remove the current version
+		if i == 0 {
+			if !v.Deleted {
+				kept++
+			}
+			continue
+		}
+
+		if opts.Keep > 0 && !v.Deleted && kept < opts.Keep {
+			kept++
+			continue
+		}
+
+		if opts.OlderThan > 0 && now.Sub(v.WrittenTime()) <= opts.OlderThan {
+			if !v.Deleted {
+				kept++
+			}
+			continue
+		}
+
+		if v.Deleted && !opts.Destroy {
+			continue
+		}
+
+		result = append(result, v.Version)
+	}
+
+	sort.Ints(result)
+	return result
+}
+
+// PruneVersions deletes or destroys the versions of a secret matching a retention policy.
+// Returns the pruned versions, or the versions that would be pruned with DryRun.
+func (c *Client) PruneVersions(ctx context.Context, path string, opts PruneOptions) ([]int, error) {
+	if opts.Keep <= 0 && opts.OlderThan <= 0 {
+		return nil, fmt.Errorf("no retention policy: set a number of versions to keep or a minimum age")
+	}
+
+	versions, err := c.getAllVersions(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("secret does not exist: %s", path)
+	}
+
+	candidates := pruneCandidates(versions, opts, time.Now())
+	if len(candidates) == 0 || opts.DryRun {
+		return candidates, nil
+	}
+
+	if err := c.removeVersions(ctx, path, candidates, opts.Destroy); err != nil {
+		return nil, err
+	}
+
+	return candidates, nil
+}
+
+// removeVersions soft-deletes versions of a secret, or destroys them if destroy is set
+func (c *Client) removeVersions(ctx context.Context, path string, versions []int, destroy bool) error {
+	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
+
+	// KV v2 soft-deletes versions via the delete endpoint and destroys them via destroy
+	action := "delete"
+	if destroy {
+		action = "destroy"
+	}
+
+	_, err := c.client.Logical().WriteWithContext(ctx, fmt.Sprintf("%s/%s/%s", mount, action, secretPath), map[string]any{
+		"versions": versions,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to %s versions of %s: %w", action, path, err)
+	}
+	return nil
+}
+
+// PruneRecursive applies a retention policy to all secrets under a path.
+// If the path is a single secret, only that secret is pruned.
+// Returns the secrets that had versions pruned, sorted by path.
+func (c *Client) PruneRecursive(ctx context.Context, path string, opts PruneOptions) ([]PrunedSecret, error) {
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secretPaths) == 0 {
+		secretPaths = []string{""}
+	}
+	sort.Strings(secretPaths)
+
+	var result []PrunedSecret
+	for _, relPath := range secretPaths {
+		fullPath := path
+		if relPath != "" {
+			fullPath = path + "/" + relPath
+		}
+
+		versions, err := c.PruneVersions(ctx, fullPath, opts)
+		if err != nil {
+			return result, err
+		}
+		if len(versions) > 0 {
+			result = append(result, PrunedSecret{Path: fullPath, Versions: versions})
+		}
+	}
+
+	return result, nil
+}
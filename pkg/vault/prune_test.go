@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPruneCandidates(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+
+	// Newest first, as returned by getAllVersions
+	versions := []VersionInfo{
+		{Version: 8, CreatedTime: daysAgo(1)},
+		{Version: 7, CreatedTime: daysAgo(10)},
+		{Version: 6, CreatedTime: daysAgo(20), Deleted: true},
+		{Version: 5, CreatedTime: daysAgo(100)},
+		{Version: 4, CreatedTime: daysAgo(1), OriginalTime: daysAgo(200)},
+		{Version: 3, CreatedTime: daysAgo(300)},
+		{Version: 2, CreatedTime: daysAgo(400), Destroyed: true},
+		{Version: 1, CreatedTime: daysAgo(500), Deleted: true},
+	}
+
+	tests := []struct {
+		name     string
+		opts     PruneOptions
+		expected []int
+	}{
+		{
+			name:     "keep newest",
+			opts:     PruneOptions{Keep: 3},
+			expected: []int{3, 4},
+		},
+		{
+			name:     "keep newest and destroy deleted",
+			opts:     PruneOptions{Keep: 3, Destroy: true},
+			expected: []int{1, 3, 4, 6},
+		},
+		{
+			name:     "older than",
+			opts:     PruneOptions{OlderThan: 90 * 24 * time.Hour},
+			expected: []int{3, 4, 5},
+		},
+		{
+			name:     "keep and older than",
+			opts:     PruneOptions{Keep: 4, OlderThan: 90 * 24 * time.Hour},
+			expected: []int{3},
+		},
+		{
+			name:     "keep more than exist",
+			opts:     PruneOptions{Keep: 10},
+			expected: nil,
+		},
+		{
+			name:     "current version is never pruned",
+			opts:     PruneOptions{OlderThan: time.Hour},
+			expected: []int{3, 4, 5, 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := pruneCandidates(versions, tt.opts, now)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("pruneCandidates() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/ethanadams/vlt/pkg/config"
+)
+
+// s3Scheme prefixes snapshot locations in object storage
+const s3Scheme = "s3://"
+
+// S3Location is an object, or a prefix of objects, in a bucket: s3://bucket/key
+type S3Location struct {
+	Bucket string
+	Key    string
+}
+
+// IsS3URL returns true if s is an s3:// URL rather than a local path
+func IsS3URL(s string) bool {
+	return strings.HasPrefix(s, s3Scheme)
+}
+
+// ParseS3URL parses an s3://bucket/key URL. The key may be empty or a prefix.
+func ParseS3URL(s string) (S3Location, error) {
+	rest, ok := strings.CutPrefix(s, s3Scheme)
+	if !ok {
+		return S3Location{}, fmt.Errorf("invalid S3 URL %q: expected s3://bucket/key", s)
+	}
+	bucket, key, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return S3Location{}, fmt.Errorf("invalid S3 URL %q: missing bucket", s)
+	}
+	return S3Location{Bucket: bucket, Key: key}, nil
+}
+
+func (l S3Location) String() string {
+	return s3Scheme + l.Bucket + "/" + l.Key
+}
+
+// Resolve returns the location of ref, relative to the directory of l unless it is
+// an s3:// URL itself
+func (l S3Location) Resolve(ref string) (S3Location, error) {
+	if IsS3URL(ref) {
+		return ParseS3URL(ref)
+	}
+	return S3Location{Bucket: l.Bucket, Key: strings.TrimPrefix(path.Join(path.Dir(l.Key), ref), "/")}, nil
+}
+
+// RelativeTo returns l as a path relative to the directory of from, or as a full
+// URL if the two are in different buckets
+func (l S3Location) RelativeTo(from S3Location) string {
+	if l.Bucket != from.Bucket {
+		return l.String()
+	}
+
+	dir := strings.Split(path.Dir(from.Key), "/")
+	if path.Dir(from.Key) == "." {
+		dir = nil
+	}
+	target := strings.Split(l.Key, "/")
+
+	n := 0
+	for n < len(dir) && n < len(target)-1 && dir[n] == target[n] {
+		n++
+	}
+	parts := make([]string, 0, len(dir)-n+len(target)-n)
+	for range dir[n:] {
+		parts = append(parts, "..")
+	}
+	return path.Join(append(parts, target[n:]...)...)
+}
+
+// SnapshotObjectInfo describes a snapshot stored in object storage. It is kept in the
+// object's metadata, so backups can be listed without downloading or decrypting them.
+type SnapshotObjectInfo struct {
+	Path        string
+	CreatedAt   time.Time
+	Format      BackupFormat
+	Secrets     int
+	Versions    int
+	Cluster     string
+	Mount       string
+	VltVersion  string
+	Incremental bool
+	Encrypted   bool
+	Signed      bool
+}
+
+// NewSnapshotObjectInfo describes a snapshot about to be written
+func NewSnapshotObjectInfo(snapshot *Snapshot, format BackupFormat, encrypted, signed bool, vltVersion string) *SnapshotObjectInfo {
+	info := &SnapshotObjectInfo{
+		Path:        snapshot.Path,
+		CreatedAt:   snapshot.CreatedAt,
+		Format:      format,
+		Secrets:     len(snapshot.Secrets),
+		VltVersion:  vltVersion,
+		Incremental: snapshot.Base != nil,
+		Encrypted:   encrypted,
+		Signed:      signed,
+	}
+	for _, secret := range snapshot.Secrets {
+		info.Versions += len(secret.Versions)
+	}
+	if snapshot.Manifest != nil {
+		info.Cluster = snapshot.Manifest.Cluster
+		info.Mount = snapshot.Manifest.Mount
+	}
+	return info
+}
+
+// Object metadata keys, stored as x-amz-meta-vlt-*
+const (
+	s3MetaPath        = "vlt-path"
+	s3MetaCreated     = "vlt-created"
+	s3MetaFormat      = "vlt-format"
+	s3MetaSecrets     = "vlt-secrets"
+	s3MetaVersions    = "vlt-versions"
+	s3MetaCluster     = "vlt-cluster"
+	s3MetaMount       = "vlt-mount"
+	s3MetaVltVersion  = "vlt-version"
+	s3MetaIncremental = "vlt-incremental"
+	s3MetaEncrypted   = "vlt-encrypted"
+	s3MetaSigned      = "vlt-signed"
+)
+
+// metadata encodes the info as object metadata
+func (i *SnapshotObjectInfo) metadata() map[string]string {
+	m := map[string]string{
+		s3MetaPath:        i.Path,
+		s3MetaCreated:     i.CreatedAt.UTC().Format(time.RFC3339),
+		s3MetaFormat:      string(i.Format),
+		s3MetaSecrets:     strconv.Itoa(i.Secrets),
+		s3MetaVersions:    strconv.Itoa(i.Versions),
+		s3MetaIncremental: strconv.FormatBool(i.Incremental),
+		s3MetaEncrypted:   strconv.FormatBool(i.Encrypted),
+		s3MetaSigned:      strconv.FormatBool(i.Signed),
+	}
+	if i.Cluster != "" {
+		m[s3MetaCluster] = i.Cluster
+	}
+	if i.Mount != "" {
+		m[s3MetaMount] = i.Mount
+	}
+	if i.VltVersion != "" {
+		m[s3MetaVltVersion] = i.VltVersion
+	}
+	return m
+}
+
+// parseSnapshotObjectInfo decodes object metadata, nil if the object was not written by vlt
+func parseSnapshotObjectInfo(m map[string]string) *SnapshotObjectInfo {
+	// Metadata keys come back lowercased, whatever case they were written in
+	lower := make(map[string]string, len(m))
+	for k, v := range m {
+		lower[strings.ToLower(k)] = v
+	}
+	if _, ok := lower[s3MetaCreated]; !ok {
+		return nil
+	}
+
+	info := &SnapshotObjectInfo{
+		Path:       lower[s3MetaPath],
+		Format:     BackupFormat(lower[s3MetaFormat]),
+		Cluster:    lower[s3MetaCluster],
+		Mount:      lower[s3MetaMount],
+		VltVersion: lower[s3MetaVltVersion],
+	}
+	info.CreatedAt, _ = time.Parse(time.RFC3339, lower[s3MetaCreated])
+	info.Secrets, _ = strconv.Atoi(lower[s3MetaSecrets])
+	info.Versions, _ = strconv.Atoi(lower[s3MetaVersions])
+	info.Incremental, _ = strconv.ParseBool(lower[s3MetaIncremental])
+	info.Encrypted, _ = strconv.ParseBool(lower[s3MetaEncrypted])
+	info.Signed, _ = strconv.ParseBool(lower[s3MetaSigned])
+	return info
+}
+
+// S3Store reads and writes snapshot files in S3-compatible object storage
+type S3Store struct {
+	client *s3.Client
+	cfg    *config.S3Config
+}
+
+// NewS3Store returns a store using the usual AWS credential sources
+func NewS3Store(ctx context.Context, cfg *config.S3Config) (*S3Store, error) {
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	switch {
+	case cfg.Region != "":
+		loadOpts = append(loadOpts, awsconfig.WithRegion(cfg.Region))
+	case cfg.Endpoint != "":
+		// Self-hosted stores rarely care about the region, but signing needs one
+		loadOpts = append(loadOpts, awsconfig.WithDefaultRegion("us-east-1"))
+	}
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.UsePathStyle = cfg.PathStyle
+	})
+
+	return &S3Store{client: client, cfg: cfg}, nil
+}
+
+// Get downloads an object
+func (s *S3Store) Get(ctx context.Context, loc S3Location) ([]byte, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(loc.Bucket),
+		Key:    aws.String(loc.Key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", loc, err)
+	}
+	defer out.Body.Close()
+
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", loc, err)
+	}
+	return data, nil
+}
+
+// S3PutOptions configures how a snapshot is uploaded
+type S3PutOptions struct {
+	Info *SnapshotObjectInfo // Stored as object metadata, nil for none
+
+	// RetainUntil locks the object against deletion and overwrites until this time,
+	// zero for no lock. The bucket must have object lock enabled.
+	RetainUntil time.Time
+
+	// RetentionMode is the object lock mode: "GOVERNANCE" (default), which privileged
+	// users can bypass, or "COMPLIANCE", which nobody can
+	RetentionMode string
+}
+
+// Put uploads an object, encrypted server-side as configured
+func (s *S3Store) Put(ctx context.Context, loc S3Location, data []byte, opts S3PutOptions) error {
+	if loc.Key == "" || strings.HasSuffix(loc.Key, "/") {
+		return fmt.Errorf("%s is not an object key", loc)
+	}
+
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(loc.Bucket),
+		Key:           aws.String(loc.Key),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	}
+	if opts.Info != nil {
+		input.Metadata = opts.Info.metadata()
+	}
+	if s.cfg.SSE != "" {
+		input.ServerSideEncryption = s3types.ServerSideEncryption(s.cfg.SSE)
+	}
+	if s.cfg.SSEKMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(s.cfg.SSEKMSKeyID)
+	}
+	if !opts.RetainUntil.IsZero() {
+		mode, err := parseRetentionMode(opts.RetentionMode)
+		if err != nil {
+			return err
+		}
+		input.ObjectLockMode = mode
+		input.ObjectLockRetainUntilDate = aws.Time(opts.RetainUntil)
+	}
+
+	if _, err := s.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", loc, err)
+	}
+	return nil
+}
+
+// parseRetentionMode parses an object lock mode, case-insensitively
+func parseRetentionMode(mode string) (s3types.ObjectLockMode, error) {
+	switch strings.ToUpper(mode) {
+	case "", "GOVERNANCE":
+		return s3types.ObjectLockModeGovernance, nil
+	case "COMPLIANCE":
+		return s3types.ObjectLockModeCompliance, nil
+	}
+	return "", fmt.Errorf("invalid retention mode %q: expected governance or compliance", mode)
+}
+
+// Delete removes an object. Objects under an object lock retention can't be deleted
+// until it expires.
+func (s *S3Store) Delete(ctx context.Context, loc S3Location) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(loc.Bucket),
+		Key:    aws.String(loc.Key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", loc, err)
+	}
+	return nil
+}
+
+// S3Object is an object found under a prefix
+type S3Object struct {
+	Location     S3Location
+	Size         int64
+	LastModified time.Time
+	Info         *SnapshotObjectInfo // Nil if the object was not written by vlt
+}
+
+// List returns the objects under a prefix with their snapshot info, oldest first
+func (s *S3Store) List(ctx context.Context, prefix S3Location) ([]S3Object, error) {
+	var objects []S3Object
+
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(prefix.Bucket),
+		Prefix: aws.String(prefix.Key),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+
+		for _, obj := range page.Contents {
+			loc := S3Location{Bucket: prefix.Bucket, Key: aws.ToString(obj.Key)}
+			head, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(loc.Bucket),
+				Key:    aws.String(loc.Key),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read metadata of %s: %w", loc, err)
+			}
+
+			objects = append(objects, S3Object{
+				Location:     loc,
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+				Info:         parseSnapshotObjectInfo(head.Metadata),
+			})
+		}
+	}
+
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].LastModified.Before(objects[j].LastModified)
+	})
+	return objects, nil
+}
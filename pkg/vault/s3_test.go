@@ -0,0 +1,269 @@
+package vault
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethanadams/vlt/pkg/config"
+)
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected S3Location
+		wantErr  bool
+	}{
+		{url: "s3://bucket/a/b.yaml", expected: S3Location{Bucket: "bucket", Key: "a/b.yaml"}},
+		{url: "s3://bucket/prefix/", expected: S3Location{Bucket: "bucket", Key: "prefix/"}},
+		{url: "s3://bucket", expected: S3Location{Bucket: "bucket"}},
+		{url: "s3:///key", wantErr: true},
+		{url: "backup.yaml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			loc, err := ParseS3URL(tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseS3URL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if loc != tt.expected {
+				t.Errorf("ParseS3URL() = %+v, want %+v", loc, tt.expected)
+			}
+		})
+	}
+}
+
+func TestS3LocationResolve(t *testing.T) {
+	from := S3Location{Bucket: "b", Key: "app/daily/mon.yaml"}
+	tests := []struct {
+		ref      string
+		expected string
+	}{
+		{"sun.yaml", "s3://b/app/daily/sun.yaml"},
+		{"../weekly/w1.yaml", "s3://b/app/weekly/w1.yaml"},
+		{"s3://other/full.yaml", "s3://other/full.yaml"},
+	}
+
+	for _, tt := range tests {
+		loc, err := from.Resolve(tt.ref)
+		if err != nil {
+			t.Fatalf("Resolve(%q) failed: %v", tt.ref, err)
+		}
+		if loc.String() != tt.expected {
+			t.Errorf("Resolve(%q) = %s, want %s", tt.ref, loc, tt.expected)
+		}
+	}
+}
+
+func TestS3LocationRelativeTo(t *testing.T) {
+	tests := []struct {
+		target   S3Location
+		from     S3Location
+		expected string
+	}{
+		{S3Location{"b", "app/sun.yaml"}, S3Location{"b", "app/mon.yaml"}, "sun.yaml"},
+		{S3Location{"b", "app/weekly/w1.yaml"}, S3Location{"b", "app/daily/mon.yaml"}, "../weekly/w1.yaml"},
+		{S3Location{"b", "full.yaml"}, S3Location{"b", "app/mon.yaml"}, "../full.yaml"},
+		{S3Location{"b", "app/full.yaml"}, S3Location{"b", "mon.yaml"}, "app/full.yaml"},
+		{S3Location{"other", "full.yaml"}, S3Location{"b", "mon.yaml"}, "s3://other/full.yaml"},
+	}
+
+	for _, tt := range tests {
+		rel := tt.target.RelativeTo(tt.from)
+		if rel != tt.expected {
+			t.Errorf("%s relative to %s = %q, want %q", tt.target, tt.from, rel, tt.expected)
+		}
+		if resolved, _ := tt.from.Resolve(rel); resolved != tt.target {
+			t.Errorf("resolving %q from %s = %s, want %s", rel, tt.from, resolved, tt.target)
+		}
+	}
+}
+
+func TestSnapshotObjectInfoMetadata(t *testing.T) {
+	snapshot := &Snapshot{
+		Path:      "secret/app",
+		CreatedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Manifest:  &SnapshotManifest{Cluster: "https://vault.example.com", Mount: "secret"},
+		Base:      &SnapshotBase{File: "base.yaml"},
+		Secrets: map[string]SnapshotSecret{
+			"a": {Versions: []SnapshotVersion{{Version: 1}, {Version: 2}}},
+			"b": {},
+		},
+	}
+
+	info := NewSnapshotObjectInfo(snapshot, BackupFormatVlt, true, false, "1.2.3")
+	metadata := info.metadata()
+
+	// Stores return metadata keys in their own case
+	returned := make(map[string]string)
+	for k, v := range metadata {
+		returned[strings.ToUpper(k[:1])+k[1:]] = v
+	}
+
+	parsed := parseSnapshotObjectInfo(returned)
+	if !reflect.DeepEqual(parsed, info) {
+		t.Errorf("parsed info = %+v, want %+v", parsed, info)
+	}
+	if parsed.Secrets != 2 || parsed.Versions != 2 || !parsed.Incremental || !parsed.Encrypted || parsed.Signed {
+		t.Errorf("unexpected info %+v", parsed)
+	}
+
+	if parseSnapshotObjectInfo(map[string]string{"other": "x"}) != nil {
+		t.Error("expected nil info for objects not written by vlt")
+	}
+}
+
+func TestParseRetentionMode(t *testing.T) {
+	for _, mode := range []string{"", "governance", "GOVERNANCE", "compliance"} {
+		if _, err := parseRetentionMode(mode); err != nil {
+			t.Errorf("parseRetentionMode(%q) failed: %v", mode, err)
+		}
+	}
+	if _, err := parseRetentionMode("forever"); err == nil {
+		t.Error("expected error for unknown mode")
+	}
+}
+
+// fakeS3 is a minimal path-style S3 endpoint holding objects in memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string]fakeS3Object // Keyed by bucket/key
+}
+
+type fakeS3Object struct {
+	data    []byte
+	headers http.Header
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	switch {
+	case r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[bucket+"/"+key] = fakeS3Object{data: data, headers: r.Header.Clone()}
+
+	case r.Method == http.MethodGet && key == "" && r.URL.Query().Get("list-type") == "2":
+		type content struct {
+			Key          string
+			Size         int
+			LastModified string
+		}
+		var result struct {
+			XMLName  xml.Name  `xml:"ListBucketResult"`
+			Name     string    `xml:"Name"`
+			Contents []content `xml:"Contents"`
+		}
+		result.Name = bucket
+		for k, obj := range f.objects {
+			b, objKey, _ := strings.Cut(k, "/")
+			if b == bucket && strings.HasPrefix(objKey, r.URL.Query().Get("prefix")) {
+				result.Contents = append(result.Contents, content{Key: objKey, Size: len(obj.data), LastModified: "2024-01-01T00:00:00.000Z"})
+			}
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_ = xml.NewEncoder(w).Encode(result)
+
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		obj, ok := f.objects[bucket+"/"+key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, values := range obj.headers {
+			if strings.HasPrefix(strings.ToLower(name), "x-amz-meta-") {
+				w.Header()[name] = values
+			}
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(obj.data)
+		}
+
+	case r.Method == http.MethodDelete:
+		delete(f.objects, bucket+"/"+key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	fake := &fakeS3{objects: make(map[string]fakeS3Object)}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	t.Setenv("AWS_CONFIG_FILE", "/dev/null")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", "/dev/null")
+
+	ctx := context.Background()
+	store, err := NewS3Store(ctx, &config.S3Config{Endpoint: server.URL, PathStyle: true, SSE: "AES256"})
+	if err != nil {
+		t.Fatalf("NewS3Store failed: %v", err)
+	}
+
+	loc := S3Location{Bucket: "backups", Key: "app/mon.yaml"}
+	info := &SnapshotObjectInfo{Path: "secret/app", CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Format: BackupFormatVlt, Secrets: 3}
+	retainUntil := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := store.Put(ctx, loc, []byte("secrets: {}\n"), S3PutOptions{Info: info, RetainUntil: retainUntil, RetentionMode: "compliance"}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	stored := fake.objects["backups/app/mon.yaml"]
+	if got := stored.headers.Get("X-Amz-Server-Side-Encryption"); got != "AES256" {
+		t.Errorf("server-side encryption = %q, want AES256", got)
+	}
+	if got := stored.headers.Get("X-Amz-Object-Lock-Mode"); got != "COMPLIANCE" {
+		t.Errorf("object lock mode = %q, want COMPLIANCE", got)
+	}
+	if got := stored.headers.Get("X-Amz-Object-Lock-Retain-Until-Date"); !strings.HasPrefix(got, "2030-01-01") {
+		t.Errorf("retain until = %q, want 2030-01-01", got)
+	}
+
+	data, err := store.Get(ctx, loc)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if string(data) != "secrets: {}\n" {
+		t.Errorf("Get = %q", data)
+	}
+
+	if _, err := store.Get(ctx, S3Location{Bucket: "backups", Key: "missing.yaml"}); err == nil {
+		t.Error("expected error for missing object")
+	}
+
+	objects, err := store.List(ctx, S3Location{Bucket: "backups", Key: "app/"})
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Location != loc {
+		t.Fatalf("List = %+v", objects)
+	}
+	if !reflect.DeepEqual(objects[0].Info, info) {
+		t.Errorf("listed info = %+v, want %+v", objects[0].Info, info)
+	}
+
+	if err := store.Put(ctx, S3Location{Bucket: "backups", Key: "app/"}, nil, S3PutOptions{}); err == nil {
+		t.Error("expected error writing to a prefix")
+	}
+
+	if err := store.Delete(ctx, loc); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, ok := fake.objects["backups/app/mon.yaml"]; ok {
+		t.Error("object still stored after Delete")
+	}
+}
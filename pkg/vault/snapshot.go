@@ -2,45 +2,185 @@ package vault
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
+	"sort"
 	"time"
 )
 
+// snapshotFormat is the version of the snapshot schema written by vlt. Snapshots
+// without a format stored values unwrapped from {"value": ...}; format 2 stores the
+// exact fields of each secret along with its settings and custom metadata.
+const snapshotFormat = 2
+
 // Snapshot represents a point-in-time backup of secrets
 type Snapshot struct {
+	// Manifest for integrity checks, written first so a truncated file can't lose it
+	Manifest *SnapshotManifest `yaml:"manifest,omitempty"`
+
 	// Metadata
+	Format    int       `yaml:"format,omitempty"` // Schema version, see snapshotFormat
 	Path      string    `yaml:"path"`
 	CreatedAt time.Time `yaml:"created_at"`
+	At        time.Time `yaml:"at,omitempty"` // Point in time the secrets were read at, if not current
+
+	// Base is set on incremental snapshots, which only hold the secrets that
+	// changed since the base snapshot and list the ones that were removed
+	Base    *SnapshotBase `yaml:"base,omitempty"`
+	Removed []string      `yaml:"removed,omitempty"`
 
 	// Secrets maps relative paths to their data
 	Secrets map[string]SnapshotSecret `yaml:"secrets"`
 }
 
+// SnapshotBase references the snapshot an incremental snapshot was taken against
+type SnapshotBase struct {
+	File      string    `yaml:"file"` // Path of the base snapshot file, relative to the incremental one
+	CreatedAt time.Time `yaml:"created_at"`
+	Digest    string    `yaml:"digest"` // SHA-256 of the base snapshot file
+}
+
 // SnapshotSecret represents a single secret in a snapshot
 type SnapshotSecret struct {
-	Value   any       `yaml:"value"`
+	// Data holds the exact fields of the secret. Snapshots before format 2 hold
+	// Value instead, unwrapped from {"value": ...} for single-value secrets.
+	Data  map[string]any `yaml:"data"`
+	Value any            `yaml:"value,omitempty"`
+
 	Version int       `yaml:"version"`
 	Updated time.Time `yaml:"updated"`
+
+	// Current is the version that was current in Vault when the snapshot was
+	// taken, if Value comes from an older version (snapshots from history)
+	Current int `yaml:"current,omitempty"`
+
+	// Metadata holds the settings and custom metadata of the secret, nil if they
+	// were not captured (older snapshots and snapshots from history)
+	Metadata *SnapshotMetadata `yaml:"metadata,omitempty"`
+
+	// Versions is the full version history, oldest first, if the snapshot was
+	// taken with AllVersions. Destroyed versions are left out.
+	Versions []SnapshotVersion `yaml:"versions,omitempty"`
+}
+
+// SnapshotVersion is one version of a secret in a full-history snapshot
+type SnapshotVersion struct {
+	Version int            `yaml:"version"`
+	Data    map[string]any `yaml:"data"`            // Nil for deleted versions, whose data is not readable
+	Value   any            `yaml:"value,omitempty"` // Before format 2, see SnapshotSecret
+	Created time.Time      `yaml:"created"`
+	Deleted bool           `yaml:"deleted,omitempty"`
+}
+
+// SnapshotMetadata holds the KV settings and custom metadata of a secret. Custom
+// metadata vlt records for specific versions is left out, as restored secrets get
+// new version numbers.
+type SnapshotMetadata struct {
+	MaxVersions        int               `yaml:"max_versions"`
+	CASRequired        bool              `yaml:"cas_required"`
+	DeleteVersionAfter time.Duration     `yaml:"delete_version_after"`
+	Custom             map[string]string `yaml:"custom,omitempty"`
+}
+
+// SnapshotOptions configures what a snapshot captures
+type SnapshotOptions struct {
+	AllVersions bool // Capture every version that was not destroyed, not only the current one
+}
+
+// ExpectedVersion returns the version a secret must still be at for a restore not to
+// conflict with it: the version that was current when the snapshot was taken
+func (s SnapshotSecret) ExpectedVersion() int {
+	if s.Current != 0 {
+		return s.Current
+	}
+	return s.Version
+}
+
+// SecretData returns the fields of the secret, as stored in Vault
+func (s SnapshotSecret) SecretData() map[string]any {
+	if s.Data != nil {
+		return s.Data
+	}
+	return snapshotData(s.Value)
+}
+
+// SecretData returns the fields of the version, as stored in Vault
+func (v SnapshotVersion) SecretData() map[string]any {
+	if v.Data != nil {
+		return v.Data
+	}
+	return snapshotData(v.Value)
+}
+
+// snapshotMetadata captures the settings and custom metadata of a secret
+func snapshotMetadata(m *SecretMetadata) *SnapshotMetadata {
+	if m == nil {
+		return nil
+	}
+
+	result := &SnapshotMetadata{
+		MaxVersions:        m.MaxVersions,
+		CASRequired:        m.CASRequired,
+		DeleteVersionAfter: m.DeleteVersionAfter,
+	}
+	for k, v := range m.CustomMetadata {
+		if isVersionMetadataKey(k) {
+			continue
+		}
+		if result.Custom == nil {
+			result.Custom = make(map[string]string)
+		}
+		result.Custom[k] = v
+	}
+	return result
+}
+
+// matches returns true if a secret already has these settings and custom metadata
+func (m *SnapshotMetadata) matches(current *SecretMetadata) bool {
+	c := snapshotMetadata(current)
+	if c == nil {
+		return false
+	}
+	return m.MaxVersions == c.MaxVersions && m.CASRequired == c.CASRequired &&
+		m.DeleteVersionAfter == c.DeleteVersionAfter && maps.Equal(m.Custom, c.Custom)
 }
 
 // RestoreOptions configures how a restore operation behaves
 type RestoreOptions struct {
-	DryRun       bool // Preview changes without applying
-	Verify       bool // Only restore if versions match
-	DeleteExtra  bool // Delete secrets not in snapshot (default true)
+	DryRun      bool // Preview changes without applying
+	DeleteExtra bool // Delete secrets not in snapshot (default true)
+	LatestOnly  bool // Write only the current value of secrets with a version history
+	KeepPartial bool // On failure, leave applied changes in place instead of rolling back
+
+	// Selection restricts the restore to some secrets of the snapshot and renames them.
+	// Secrets at the target are only deleted if the selection covers them.
+	Selection SnapshotSelection
+
+	// OnConflict decides what happens to secrets modified after the snapshot recorded
+	// them; the default overwrites them. With ConflictPrompt, Prompt is called for each
+	// conflict before anything is changed, and returns true to restore the secret.
+	OnConflict ConflictPolicy
+	Prompt     func(conflict RestoreConflict) (bool, error)
 }
 
 // RestoreResult contains the results of a restore operation
 type RestoreResult struct {
-	Added    []string // Secrets that were added
-	Updated  []string // Secrets that were updated
-	Deleted  []string // Secrets that were deleted
+	Added     []string // Secrets that were added
+	Updated   []string // Secrets that were updated
+	Deleted   []string // Secrets that were deleted
 	Unchanged []string // Secrets that were unchanged
-	Skipped  []string // Secrets skipped due to verification failure
+	Skipped   []string // Conflicting secrets that were kept as they are in Vault
+	Conflicts []string // Secrets modified since the snapshot recorded them, restored or not
 }
 
 // CreateSnapshot creates a snapshot of all secrets under a path
 func (c *Client) CreateSnapshot(ctx context.Context, path string) (*Snapshot, error) {
+	return c.CreateSnapshotWithOptions(ctx, path, SnapshotOptions{})
+}
+
+// CreateSnapshotWithOptions creates a snapshot of all secrets under a path
+func (c *Client) CreateSnapshotWithOptions(ctx context.Context, path string, opts SnapshotOptions) (*Snapshot, error) {
 	// Get all secret paths
 	secretPaths, err := c.ListSecretPaths(ctx, path)
 	if err != nil {
@@ -52,6 +192,8 @@ func (c *Client) CreateSnapshot(ctx context.Context, path string) (*Snapshot, er
 	}
 
 	snapshot := &Snapshot{
+		Manifest:  c.snapshotSource(ctx, path),
+		Format:    snapshotFormat,
 		Path:      path,
 		CreatedAt: time.Now(),
 		Secrets:   make(map[string]SnapshotSecret),
@@ -60,35 +202,345 @@ func (c *Client) CreateSnapshot(ctx context.Context, path string) (*Snapshot, er
 	for _, relPath := range secretPaths {
 		fullPath := path + "/" + relPath
 
-		// Read the secret data
-		data, err := c.ReadSecretRaw(ctx, fullPath)
+		// Get metadata for version info
+		metadata, err := c.GetMetadata(ctx, fullPath)
 		if err != nil {
+			return nil, fmt.Errorf("failed to get metadata for %s: %w", relPath, err)
+		}
+
+		if snapshot.Secrets[relPath], err = c.snapshotSecret(ctx, fullPath, metadata, opts); err != nil {
 			return nil, fmt.Errorf("failed to read secret %s: %w", relPath, err)
 		}
+	}
+
+	return snapshot, nil
+}
+
+// CreateIncrementalSnapshot creates a snapshot of the secrets under a path that changed
+// since base, which must be resolved to its full state (see ApplyIncremental). Only
+// secrets whose current version differs from base are read; secrets that no longer
+// exist are listed as removed.
+func (c *Client) CreateIncrementalSnapshot(ctx context.Context, path string, base *Snapshot, opts SnapshotOptions) (*Snapshot, error) {
+	if base.Path != path {
+		return nil, fmt.Errorf("base snapshot was taken of %s, not %s", base.Path, path)
+	}
+
+	secretPaths, err := c.ListSecretPaths(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list secrets: %w", err)
+	}
+
+	snapshot := &Snapshot{
+		Manifest:  c.snapshotSource(ctx, path),
+		Format:    snapshotFormat,
+		Path:      path,
+		CreatedAt: time.Now(),
+		Base:      &SnapshotBase{CreatedAt: base.CreatedAt},
+		Secrets:   make(map[string]SnapshotSecret),
+	}
+
+	current := make(map[string]bool, len(secretPaths))
+	for _, relPath := range secretPaths {
+		current[relPath] = true
+		fullPath := path + "/" + relPath
 
-		// Get metadata for version info
 		metadata, err := c.GetMetadata(ctx, fullPath)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get metadata for %s: %w", relPath, err)
 		}
+		// Comparing versions alone would miss a secret deleted and recreated up to the
+		// same version number, so the update time is compared too
+		if baseSecret, ok := base.Secrets[relPath]; ok && !baseSecret.modifiedSince(metadata) {
+			continue
+		}
 
-		// Extract value - secrets are stored as {"value": ...}
-		var value any = data
-		if v, ok := data["value"]; ok && len(data) == 1 {
-			value = v
+		if snapshot.Secrets[relPath], err = c.snapshotSecret(ctx, fullPath, metadata, opts); err != nil {
+			return nil, fmt.Errorf("failed to read secret %s: %w", relPath, err)
 		}
+	}
 
-		snapshot.Secrets[relPath] = SnapshotSecret{
-			Value:   value,
-			Version: metadata.CurrentVersion,
-			Updated: metadata.UpdatedTime,
+	for relPath := range base.Secrets {
+		if !current[relPath] {
+			snapshot.Removed = append(snapshot.Removed, relPath)
 		}
 	}
+	sort.Strings(snapshot.Removed)
 
 	return snapshot, nil
 }
 
-// RestoreSnapshot restores secrets from a snapshot
+// snapshotSecret reads the current value of a secret for a snapshot, and its history
+// if opts.AllVersions is set
+func (c *Client) snapshotSecret(ctx context.Context, path string, metadata *SecretMetadata, opts SnapshotOptions) (SnapshotSecret, error) {
+	data, err := c.ReadSecretRaw(ctx, path)
+	if err != nil {
+		return SnapshotSecret{}, err
+	}
+
+	secret := SnapshotSecret{
+		Data:     snapshotFields(data),
+		Version:  metadata.CurrentVersion,
+		Updated:  metadata.UpdatedTime,
+		Metadata: snapshotMetadata(metadata),
+	}
+	if opts.AllVersions {
+		if secret.Versions, err = c.snapshotVersions(ctx, path); err != nil {
+			return SnapshotSecret{}, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+	return secret, nil
+}
+
+// ApplyIncremental returns the full state of an incremental snapshot, given the full
+// state of its base
+func ApplyIncremental(base, incremental *Snapshot) *Snapshot {
+	merged := &Snapshot{
+		Manifest:  incremental.Manifest,
+		Format:    incremental.Format,
+		Path:      incremental.Path,
+		CreatedAt: incremental.CreatedAt,
+		At:        incremental.At,
+		Secrets:   make(map[string]SnapshotSecret, len(base.Secrets)+len(incremental.Secrets)),
+	}
+
+	for relPath, secret := range base.Secrets {
+		merged.Secrets[relPath] = secret
+	}
+	for _, relPath := range incremental.Removed {
+		delete(merged.Secrets, relPath)
+	}
+	for relPath, secret := range incremental.Secrets {
+		merged.Secrets[relPath] = secret
+	}
+
+	return merged
+}
+
+// snapshotVersions reads every version of a secret that was not destroyed, oldest first
+func (c *Client) snapshotVersions(ctx context.Context, path string) ([]SnapshotVersion, error) {
+	versions, err := c.getAllVersions(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []SnapshotVersion
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if v.Destroyed {
+			continue
+		}
+
+		sv := SnapshotVersion{
+			Version: v.Version,
+			Created: v.WrittenTime(),
+			Deleted: v.Deleted,
+		}
+		if !v.Deleted {
+			data, err := c.ReadSecretVersion(ctx, path, v.Version)
+			if err != nil {
+				return nil, err
+			}
+			if data == nil {
+				sv.Deleted = true
+			} else {
+				sv.Data = snapshotFields(data)
+			}
+		}
+		result = append(result, sv)
+	}
+
+	return result, nil
+}
+
+// Data returns the secrets of the snapshot as a nested map, in the same form as Get
+func (s *Snapshot) Data() map[string]any {
+	raw := make(map[string]any)
+	for relPath, secret := range s.Secrets {
+		nestSecret(raw, relPath, secret.SecretData())
+	}
+	return expandSecrets(raw)
+}
+
+// SecretVersions returns the version each secret was at when the snapshot was taken,
+// keyed by relative path
+func (s *Snapshot) SecretVersions() map[string]int {
+	versions := make(map[string]int, len(s.Secrets))
+	for relPath, secret := range s.Secrets {
+		versions[relPath] = secret.ExpectedVersion()
+	}
+	return versions
+}
+
+// snapshotFields returns secret data as stored in a snapshot. JSON numbers read from
+// Vault are converted to numbers, so they are not written as strings.
+func snapshotFields(data map[string]any) map[string]any {
+	if data == nil {
+		return map[string]any{}
+	}
+	return normalizeValue(data).(map[string]any)
+}
+
+// normalizeValue converts the json.Number values in data read from Vault to int64
+// or float64, recursively
+func normalizeValue(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for k, x := range v {
+			result[k] = normalizeValue(x)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, x := range v {
+			result[i] = normalizeValue(x)
+		}
+		return result
+	}
+	return v
+}
+
+// canonicalJSON encodes secret data with sorted keys and normalised numbers, so
+// equal data always has the same encoding
+func canonicalJSON(data map[string]any) (string, error) {
+	b, err := json.Marshal(normalizeValue(data))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// sameData returns true if two secrets hold the same fields with the same values and types
+func sameData(a, b map[string]any) bool {
+	ca, err := canonicalJSON(a)
+	if err != nil {
+		return false
+	}
+	cb, err := canonicalJSON(b)
+	if err != nil {
+		return false
+	}
+	return ca == cb
+}
+
+// snapshotData converts a value from a snapshot before format 2 back to secret data.
+// Simple values are wrapped in {"value": ...}.
+func snapshotData(value any) map[string]any {
+	if data, ok := value.(map[string]any); ok {
+		return data
+	}
+	return map[string]any{"value": value}
+}
+
+// replayVersions writes the versions of a full-history snapshot to a secret in order.
+// Deleted versions are written empty and soft-deleted again. The original creation
+// time of each of the last historyCreatedVersions versions is recorded like for a copy
+// with history.
+func (c *Client) replayVersions(ctx context.Context, path string, versions []SnapshotVersion) error {
+	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
+
+	custom := make(map[string]string)
+	var written, deleted []int
+	for _, v := range versions {
+		data := map[string]any{}
+		if !v.Deleted {
+			data = v.SecretData()
+		}
+
+		version, err := c.writeSecretData(ctx, mount, secretPath, data)
+		if err != nil {
+			return fmt.Errorf("failed to write version %d: %w", v.Version, err)
+		}
+		custom[historyCreatedKey(version)] = v.Created.UTC().Format(time.RFC3339)
+		written = append(written, version)
+		if v.Deleted {
+			deleted = append(deleted, version)
+		}
+	}
+
+	if len(deleted) > 0 {
+		if err := c.removeVersions(ctx, path, deleted, false); err != nil {
+			return err
+		}
+	}
+
+	metadata, err := c.GetMetadata(ctx, path)
+	if err != nil {
+		return err
+	}
+	if metadata != nil {
+		for k, v := range metadata.CustomMetadata {
+			if _, ok := custom[k]; !ok {
+				custom[k] = v
+			}
+		}
+	}
+
+	// Keep within Vault's limit on custom metadata keys, however many versions were replayed
+	latest := written[len(written)-1]
+	pruneHistoryCreated(custom, latest)
+	c.provenance.stamp(custom, latest, written...)
+	pruneProvenance(custom, latest)
+	return c.writeMetadata(ctx, path, map[string]any{"custom_metadata": custom})
+}
+
+// writeRestoredData writes the data of a restored secret, recording provenance.
+// Secrets that require check-and-set are written against their current version.
+func (c *Client) writeRestoredData(ctx context.Context, path string, data map[string]any, current *SecretMetadata) error {
+	cas := -1
+	if current != nil && current.CASRequired {
+		cas = current.CurrentVersion
+	}
+
+	mount, secretPath, _ := c.ResolveMountPath(ctx, path)
+	version, err := c.writeSecretDataCAS(ctx, mount, secretPath, data, cas)
+	if err != nil {
+		return err
+	}
+	c.recordProvenance(ctx, mount, secretPath, version)
+	return nil
+}
+
+// restoreMetadata sets the settings and custom metadata of a secret. Custom metadata
+// vlt recorded for versions of the secret is kept.
+func (c *Client) restoreMetadata(ctx context.Context, path string, m *SnapshotMetadata) error {
+	current, err := c.GetMetadata(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	custom := make(map[string]string, len(m.Custom))
+	for k, v := range m.Custom {
+		custom[k] = v
+	}
+	if current != nil {
+		for k, v := range current.CustomMetadata {
+			if isVersionMetadataKey(k) {
+				custom[k] = v
+			}
+		}
+	}
+
+	return c.writeMetadata(ctx, path, map[string]any{
+		"max_versions":         m.MaxVersions,
+		"cas_required":         m.CASRequired,
+		"delete_version_after": m.DeleteVersionAfter.String(),
+		"custom_metadata":      custom,
+	})
+}
+
+// RestoreSnapshot restores secrets from a snapshot.
+// The prior state of every secret is journaled before it is changed. If the restore
+// fails or ctx is cancelled, changes already made are rolled back, unless
+// opts.KeepPartial is set; the returned *RestoreError lists them either way.
 func (c *Client) RestoreSnapshot(ctx context.Context, snapshot *Snapshot, targetPath string, opts RestoreOptions) (*RestoreResult, error) {
 	result := &RestoreResult{
 		Added:     make([]string, 0),
@@ -96,6 +548,25 @@ func (c *Client) RestoreSnapshot(ctx context.Context, snapshot *Snapshot, target
 		Deleted:   make([]string, 0),
 		Unchanged: make([]string, 0),
 		Skipped:   make([]string, 0),
+		Conflicts: make([]string, 0),
+	}
+	journal := &restoreJournal{}
+
+	if opts.OnConflict != "" {
+		if _, err := ParseConflictPolicy(string(opts.OnConflict)); err != nil {
+			return nil, err
+		}
+	}
+	if opts.OnConflict == ConflictPrompt && opts.Prompt == nil {
+		return nil, fmt.Errorf("conflict policy %s requires a Prompt function", ConflictPrompt)
+	}
+
+	if !opts.Selection.IsEmpty() {
+		selected, err := opts.Selection.Apply(snapshot)
+		if err != nil {
+			return nil, err
+		}
+		snapshot = selected
 	}
 
 	// Get current secrets at target path
@@ -110,40 +581,78 @@ func (c *Client) RestoreSnapshot(ctx context.Context, snapshot *Snapshot, target
 		currentSet[p] = true
 	}
 
+	// Conflicts are settled before anything is changed, so failing leaves Vault untouched
+	// and prompts don't hold a half-applied restore open while waiting for answers
+	var decisions map[string]bool
+	if opts.OnConflict == ConflictFail || (opts.OnConflict == ConflictPrompt && !opts.DryRun) {
+		conflicts, err := c.restoreConflicts(ctx, snapshot, targetPath, currentSet)
+		if err != nil {
+			return nil, err
+		}
+		if opts.OnConflict == ConflictFail && len(conflicts) > 0 {
+			return nil, &ConflictError{Conflicts: conflicts}
+		}
+		if opts.OnConflict == ConflictPrompt {
+			decisions = make(map[string]bool, len(conflicts))
+			for _, conflict := range conflicts {
+				overwrite, err := opts.Prompt(conflict)
+				if err != nil {
+					return nil, err
+				}
+				decisions[conflict.Path] = overwrite
+			}
+		}
+	}
+
 	// Process secrets from snapshot
 	for relPath, snapshotSecret := range snapshot.Secrets {
+		if err := ctx.Err(); err != nil {
+			return nil, c.failRestore(ctx, journal, fmt.Errorf("restore interrupted: %w", err), opts.KeepPartial)
+		}
+
 		fullPath := targetPath + "/" + relPath
 
 		exists := currentSet[relPath]
 		delete(currentSet, relPath) // Remove from set to track what's left
 
-		if opts.Verify && exists {
-			// Check if current version matches snapshot version
-			metadata, err := c.GetMetadata(ctx, fullPath)
-			if err == nil && metadata.CurrentVersion != snapshotSecret.Version {
-				result.Skipped = append(result.Skipped, relPath)
-				continue
+		// Read the current state to compare, and to roll back to
+		var currentData map[string]any
+		var currentMetadata *SecretMetadata
+		var readErr error
+		if exists {
+			currentMetadata, readErr = c.GetMetadata(ctx, fullPath)
+			if readErr == nil {
+				currentData, readErr = c.ReadSecretRaw(ctx, fullPath)
 			}
 		}
 
-		// Check if secret needs to be updated
-		if exists {
-			// Read current value to compare
-			currentData, err := c.ReadSecretRaw(ctx, fullPath)
-			if err == nil {
-				// Compare values
-				var snapshotValue any = snapshotSecret.Value
-				if sv, ok := snapshotValue.(map[string]any); ok {
-					if v, ok := sv["value"]; ok && len(sv) == 1 {
-						snapshotValue = v
-					}
+		if exists && readErr == nil {
+			if conflict := restoreConflict(relPath, snapshotSecret, currentData, currentMetadata); conflict != nil {
+				result.Conflicts = append(result.Conflicts, relPath)
+				if opts.DryRun && opts.OnConflict == ConflictPrompt {
+					// Decided when the restore runs for real
+					continue
 				}
-				var currentValue any = currentData
-				if v, ok := currentData["value"]; ok && len(currentData) == 1 {
-					currentValue = v
+				overwrite, err := opts.overwrites(conflict, decisions)
+				if err != nil {
+					return nil, c.failRestore(ctx, journal, err, opts.KeepPartial)
 				}
+				if !overwrite {
+					result.Skipped = append(result.Skipped, relPath)
+					continue
+				}
+			}
+		}
 
-				if fmt.Sprintf("%v", currentValue) == fmt.Sprintf("%v", snapshotValue) {
+		// Check if secret needs to be updated
+		data := snapshotSecret.SecretData()
+		writeData := true
+		writeMetadata := snapshotSecret.Metadata != nil
+		if exists {
+			if readErr == nil {
+				writeData = !sameData(currentData, data)
+				writeMetadata = writeMetadata && !snapshotSecret.Metadata.matches(currentMetadata)
+				if !writeData && !writeMetadata {
 					result.Unchanged = append(result.Unchanged, relPath)
 					continue
 				}
@@ -154,30 +663,59 @@ func (c *Client) RestoreSnapshot(ctx context.Context, snapshot *Snapshot, target
 		}
 
 		if !opts.DryRun {
-			// Write the secret
-			data := snapshotSecret.Value
-			if dataMap, ok := data.(map[string]any); ok {
-				if err := c.WriteSecret(ctx, fullPath, dataMap); err != nil {
-					return nil, fmt.Errorf("failed to write secret %s: %w", relPath, err)
+			change := RestoreChange{Path: relPath, Action: RestoreAdded}
+			if exists {
+				change.Action = RestoreUpdated
+				if readErr != nil {
+					// The prior state is needed to roll back
+					return nil, c.failRestore(ctx, journal, readErr, opts.KeepPartial)
 				}
-			} else {
-				// Simple value - wrap in {"value": ...}
-				if err := c.WriteSecret(ctx, fullPath, map[string]any{"value": data}); err != nil {
-					return nil, fmt.Errorf("failed to write secret %s: %w", relPath, err)
+			}
+			entry, err := c.journalChange(ctx, journal, change, fullPath, currentData, currentMetadata)
+			if err != nil {
+				return nil, c.failRestore(ctx, journal, err, opts.KeepPartial)
+			}
+
+			if writeData {
+				// New secrets get their full history, if the snapshot has it
+				if !exists && !opts.LatestOnly && len(snapshotSecret.Versions) > 0 {
+					if err := c.replayVersions(ctx, fullPath, snapshotSecret.Versions); err != nil {
+						return nil, c.failRestore(ctx, journal, fmt.Errorf("failed to replay history of %s: %w", relPath, err), opts.KeepPartial)
+					}
+				} else if err := c.writeRestoredData(ctx, fullPath, data, currentMetadata); err != nil {
+					return nil, c.failRestore(ctx, journal, fmt.Errorf("failed to write secret %s: %w", relPath, err), opts.KeepPartial)
+				}
+			}
+			if writeMetadata {
+				if err := c.restoreMetadata(ctx, fullPath, snapshotSecret.Metadata); err != nil {
+					return nil, c.failRestore(ctx, journal, fmt.Errorf("failed to restore metadata of %s: %w", relPath, err), opts.KeepPartial)
 				}
 			}
+			entry.done = true
 		}
 	}
 
 	// Handle secrets that exist in Vault but not in snapshot (delete them)
 	if opts.DeleteExtra {
 		for relPath := range currentSet {
+			if !opts.Selection.covers(relPath) {
+				continue
+			}
 			result.Deleted = append(result.Deleted, relPath)
 			if !opts.DryRun {
+				if err := ctx.Err(); err != nil {
+					return nil, c.failRestore(ctx, journal, fmt.Errorf("restore interrupted: %w", err), opts.KeepPartial)
+				}
+
 				fullPath := targetPath + "/" + relPath
+				entry, err := c.journalChange(ctx, journal, RestoreChange{Path: relPath, Action: RestoreDeleted}, fullPath, nil, nil)
+				if err != nil {
+					return nil, c.failRestore(ctx, journal, err, opts.KeepPartial)
+				}
 				if err := c.DeleteSecret(ctx, fullPath); err != nil {
-					return nil, fmt.Errorf("failed to delete secret %s: %w", relPath, err)
+					return nil, c.failRestore(ctx, journal, fmt.Errorf("failed to delete secret %s: %w", relPath, err), opts.KeepPartial)
 				}
+				entry.done = true
 			}
 		}
 	}
@@ -1,7 +1,10 @@
 package vault
 
 import (
+	"encoding/json"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestRestoreResultHasChanges(t *testing.T) {
@@ -99,3 +102,277 @@ func TestRestoreResultTotalChanges(t *testing.T) {
 		})
 	}
 }
+
+func TestSnapshotSecretExpectedVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   SnapshotSecret
+		expected int
+	}{
+		{
+			name:     "snapshot of current state",
+			secret:   SnapshotSecret{Version: 3},
+			expected: 3,
+		},
+		{
+			name:     "snapshot from history",
+			secret:   SnapshotSecret{Version: 2, Current: 5},
+			expected: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.secret.ExpectedVersion(); got != tt.expected {
+				t.Errorf("ExpectedVersion() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotSecretData(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   SnapshotSecret
+		expected map[string]any
+	}{
+		{
+			name:     "legacy simple value",
+			secret:   SnapshotSecret{Value: "secret"},
+			expected: map[string]any{"value": "secret"},
+		},
+		{
+			name:     "legacy map",
+			secret:   SnapshotSecret{Value: map[string]any{"user": "admin", "port": 5432}},
+			expected: map[string]any{"user": "admin", "port": 5432},
+		},
+		{
+			name:     "exact fields",
+			secret:   SnapshotSecret{Data: map[string]any{"value": map[string]any{"nested": true}}},
+			expected: map[string]any{"value": map[string]any{"nested": true}},
+		},
+		{
+			name:     "no fields",
+			secret:   SnapshotSecret{Data: map[string]any{}},
+			expected: map[string]any{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.secret.SecretData(); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("SecretData() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotFields(t *testing.T) {
+	data := map[string]any{
+		"port":  json.Number("5432"),
+		"ratio": json.Number("0.5"),
+		"tags":  []any{"a", json.Number("2")},
+		"db":    map[string]any{"replicas": json.Number("3")},
+		"pin":   "0123",
+	}
+	expected := map[string]any{
+		"port":  int64(5432),
+		"ratio": 0.5,
+		"tags":  []any{"a", int64(2)},
+		"db":    map[string]any{"replicas": int64(3)},
+		"pin":   "0123",
+	}
+	if got := snapshotFields(data); !reflect.DeepEqual(got, expected) {
+		t.Errorf("snapshotFields() = %v, want %v", got, expected)
+	}
+	if got := snapshotFields(nil); got == nil || len(got) != 0 {
+		t.Errorf("snapshotFields(nil) = %v, want empty map", got)
+	}
+}
+
+func TestSameData(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     map[string]any
+		expected bool
+	}{
+		{"equal numbers", map[string]any{"port": json.Number("5432")}, map[string]any{"port": 5432}, true},
+		{"number and string", map[string]any{"port": 5432}, map[string]any{"port": "5432"}, false},
+		{"value field and bare value", map[string]any{"value": map[string]any{"a": "b"}}, map[string]any{"a": "b"}, false},
+		{"key order", map[string]any{"a": 1, "b": 2}, map[string]any{"b": 2, "a": 1}, true},
+		{"bool and string", map[string]any{"on": true}, map[string]any{"on": "true"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameData(tt.a, tt.b); got != tt.expected {
+				t.Errorf("sameData() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotMetadata(t *testing.T) {
+	current := &SecretMetadata{
+		CurrentVersion: 4,
+		MaxVersions:    10,
+		CASRequired:    true,
+		CustomMetadata: map[string]string{
+			"owner":                "team-a",
+			historyCreatedKey(2):   "2024-01-30T10:00:00Z",
+			provenancePrefix + "3": "alice",
+		},
+	}
+
+	m := snapshotMetadata(current)
+	expected := &SnapshotMetadata{MaxVersions: 10, CASRequired: true, Custom: map[string]string{"owner": "team-a"}}
+	if !reflect.DeepEqual(m, expected) {
+		t.Errorf("snapshotMetadata() = %+v, want %+v", m, expected)
+	}
+	if !m.matches(current) {
+		t.Error("matches() = false for the metadata it was captured from")
+	}
+
+	changed := *current
+	changed.CASRequired = false
+	if m.matches(&changed) {
+		t.Error("matches() = true after cas_required changed")
+	}
+	if m.matches(nil) {
+		t.Error("matches() = true for a missing secret")
+	}
+}
+
+func TestSnapshotFidelityRoundTrip(t *testing.T) {
+	created := time.Date(2024, 1, 30, 10, 0, 0, 0, time.UTC)
+	snapshot := &Snapshot{
+		Format:    snapshotFormat,
+		Path:      "secret/myapp",
+		CreatedAt: created,
+		Secrets: map[string]SnapshotSecret{
+			"wrapped": {
+				Data:    snapshotFields(map[string]any{"value": map[string]any{"a": "b"}}),
+				Version: 1,
+				Updated: created,
+			},
+			"typed": {
+				Data: snapshotFields(map[string]any{
+					"port": json.Number("5432"), "port_str": "5432", "on": true, "on_str": "true",
+					"none": nil, "when": "2024-01-30T10:00:00Z", "ratio": json.Number("1.5"),
+				}),
+				Version:  2,
+				Updated:  created,
+				Metadata: &SnapshotMetadata{MaxVersions: 5, CASRequired: true, DeleteVersionAfter: time.Hour, Custom: map[string]string{"owner": "team-a"}},
+			},
+			"empty": {Data: map[string]any{}, Version: 1, Updated: created},
+		},
+	}
+
+	data, err := MarshalSnapshot(snapshot, SnapshotFileOptions{})
+	if err != nil {
+		t.Fatalf("MarshalSnapshot() error = %v", err)
+	}
+	got, err := UnmarshalSnapshot(data, SnapshotLoadOptions{AllowPlaintext: true})
+	if err != nil {
+		t.Fatalf("UnmarshalSnapshot() error = %v", err)
+	}
+
+	for relPath, secret := range snapshot.Secrets {
+		if !sameData(got.Secrets[relPath].SecretData(), secret.SecretData()) {
+			t.Errorf("%s: data = %v, want %v", relPath, got.Secrets[relPath].SecretData(), secret.SecretData())
+		}
+	}
+	if m := got.Secrets["typed"].Metadata; !reflect.DeepEqual(m, snapshot.Secrets["typed"].Metadata) {
+		t.Errorf("metadata = %+v, want %+v", m, snapshot.Secrets["typed"].Metadata)
+	}
+
+	newer := []byte("format: 99\npath: secret/myapp\ncreated_at: 2024-01-30T10:00:00Z\nsecrets: {}\n")
+	if _, err := UnmarshalSnapshot(newer, SnapshotLoadOptions{AllowPlaintext: true}); err == nil {
+		t.Error("expected an error loading a snapshot format newer than supported")
+	}
+}
+
+func TestParseSnapshot(t *testing.T) {
+	data := []byte(`path: secret/myapp
+created_at: 2024-01-30T10:15:23Z
+secrets:
+  config:
+    value: some-value
+    version: 3
+    updated: 2024-01-30T10:15:23Z
+  database/password:
+    value:
+      user: admin
+      password: db-secret
+    version: 1
+    updated: 2024-01-28T09:00:00Z
+    current: 2
+`)
+
+	snapshot, ok := ParseSnapshot(data)
+	if !ok {
+		t.Fatal("ParseSnapshot() did not recognise a snapshot")
+	}
+
+	expectedData := map[string]any{
+		"config": "some-value",
+		"database": map[string]any{
+			"password": map[string]any{"user": "admin", "password": "db-secret"},
+		},
+	}
+	if got := snapshot.Data(); !reflect.DeepEqual(got, expectedData) {
+		t.Errorf("Data() = %v, want %v", got, expectedData)
+	}
+
+	expectedVersions := map[string]int{"config": 3, "database/password": 2}
+	if got := snapshot.SecretVersions(); !reflect.DeepEqual(got, expectedVersions) {
+		t.Errorf("SecretVersions() = %v, want %v", got, expectedVersions)
+	}
+
+	if _, ok := ParseSnapshot([]byte("database:\n  password: secret\n")); ok {
+		t.Error("ParseSnapshot() recognised plain YAML as a snapshot")
+	}
+}
+
+func TestApplyIncremental(t *testing.T) {
+	baseTime := time.Date(2024, 1, 28, 0, 0, 0, 0, time.UTC)
+	incTime := time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC)
+
+	base := &Snapshot{
+		Path:      "secret/myapp",
+		CreatedAt: baseTime,
+		Secrets: map[string]SnapshotSecret{
+			"config":   {Value: "v1", Version: 1, Updated: baseTime},
+			"database": {Value: "db", Version: 4, Updated: baseTime},
+			"old":      {Value: "gone", Version: 2, Updated: baseTime},
+		},
+	}
+	incremental := &Snapshot{
+		Path:      "secret/myapp",
+		CreatedAt: incTime,
+		Base:      &SnapshotBase{File: "base.yaml", CreatedAt: baseTime},
+		Removed:   []string{"old"},
+		Secrets: map[string]SnapshotSecret{
+			"config": {Value: "v2", Version: 2, Updated: incTime},
+			"new":    {Value: "added", Version: 1, Updated: incTime},
+		},
+	}
+
+	got := ApplyIncremental(base, incremental)
+
+	expected := &Snapshot{
+		Path:      "secret/myapp",
+		CreatedAt: incTime,
+		Secrets: map[string]SnapshotSecret{
+			"config":   {Value: "v2", Version: 2, Updated: incTime},
+			"database": {Value: "db", Version: 4, Updated: baseTime},
+			"new":      {Value: "added", Version: 1, Updated: incTime},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ApplyIncremental() = %+v, want %+v", got, expected)
+	}
+	if len(base.Secrets) != 3 || base.Secrets["config"].Value != "v1" {
+		t.Error("ApplyIncremental() modified the base snapshot")
+	}
+}
@@ -0,0 +1,267 @@
+package vault
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/getsops/sops/v3"
+	"github.com/getsops/sops/v3/aes"
+	"github.com/getsops/sops/v3/age"
+	sopsconfig "github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/decrypt"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/getsops/sops/v3/kms"
+	"github.com/getsops/sops/v3/pgp"
+	sopsyaml "github.com/getsops/sops/v3/stores/yaml"
+	"github.com/getsops/sops/v3/version"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPlaintextSnapshot is returned when loading an unencrypted snapshot without allowing it
+var ErrPlaintextSnapshot = errors.New("snapshot is not encrypted")
+
+// SnapshotEncryption lists the SOPS keys a snapshot is encrypted for.
+// The snapshot can be decrypted with any one of them.
+type SnapshotEncryption struct {
+	Age []string // age recipients
+	PGP []string // PGP key fingerprints
+	KMS []string // AWS KMS key ARNs
+}
+
+// IsEmpty returns true if no key is set
+func (e SnapshotEncryption) IsEmpty() bool {
+	return len(e.Age) == 0 && len(e.PGP) == 0 && len(e.KMS) == 0
+}
+
+// keyGroup builds the SOPS master keys for the configured recipients
+func (e SnapshotEncryption) keyGroup() (sops.KeyGroup, error) {
+	var group sops.KeyGroup
+
+	if len(e.Age) > 0 {
+		ageKeys, err := age.MasterKeysFromRecipients(strings.Join(e.Age, ","))
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient: %w", err)
+		}
+		for _, k := range ageKeys {
+			group = append(group, k)
+		}
+	}
+	for _, fp := range e.PGP {
+		for _, k := range pgp.MasterKeysFromFingerprintString(fp) {
+			group = append(group, k)
+		}
+	}
+	for _, arn := range e.KMS {
+		for _, k := range kms.MasterKeysFromArnString(arn, nil, "") {
+			group = append(group, k)
+		}
+	}
+
+	if len(group) == 0 {
+		return nil, fmt.Errorf("no encryption keys given")
+	}
+	return group, nil
+}
+
+// SnapshotFileOptions configures how a snapshot is written to a file
+type SnapshotFileOptions struct {
+	Encryption *SnapshotEncryption // Encrypt all values with SOPS for these keys, nil for plaintext
+	Gzip       bool                // Compress the file
+	SigningKey ed25519.PrivateKey  // Sign the manifest, nil to not sign
+	VltVersion string              // Recorded in the manifest
+}
+
+// SnapshotLoadOptions configures how a snapshot file is read
+type SnapshotLoadOptions struct {
+	AllowPlaintext bool              // Accept snapshots that are not encrypted
+	VerifyKey      ed25519.PublicKey // Require a valid signature by this key, nil to not require one
+}
+
+// MarshalSnapshot encodes a snapshot as YAML, with a manifest of its content.
+// The file is encrypted, signed and compressed as set in opts.
+func MarshalSnapshot(snapshot *Snapshot, opts SnapshotFileOptions) ([]byte, error) {
+	// Digests must match the snapshot as it will be read back, so they are
+	// computed on the decoded YAML (e.g. numbers read from Vault become strings)
+	content := *snapshot
+	content.Manifest = nil
+	data, err := yaml.Marshal(&content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	var decoded Snapshot
+	if err := yaml.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	decoded.Manifest, err = buildManifest(&decoded, snapshot.Manifest, opts.VltVersion, opts.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err = yaml.Marshal(&decoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if opts.Encryption != nil {
+		if data, err = encryptSnapshotData(data, *opts.Encryption); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress snapshot: %w", err)
+		}
+		data = buf.Bytes()
+	}
+
+	return data, nil
+}
+
+// encryptSnapshotData encrypts a plaintext YAML snapshot the way `sops --encrypt` would
+func encryptSnapshotData(data []byte, enc SnapshotEncryption) ([]byte, error) {
+	group, err := enc.keyGroup()
+	if err != nil {
+		return nil, err
+	}
+
+	store := sopsyaml.NewStore(&sopsconfig.YAMLStoreConfig{})
+	branches, err := store.LoadPlainFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot for encryption: %w", err)
+	}
+
+	tree := sops.Tree{
+		Branches: branches,
+		Metadata: sops.Metadata{
+			KeyGroups: []sops.KeyGroup{group},
+			Version:   version.Version,
+		},
+	}
+
+	dataKey, errs := tree.GenerateDataKeyWithKeyServices([]keyservice.KeyServiceClient{keyservice.NewLocalClient()})
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to encrypt snapshot data key: %v", errs)
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Encrypt(dataKey, cipher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt snapshot: %w", err)
+	}
+	tree.Metadata.LastModified = time.Now().UTC()
+	tree.Metadata.MessageAuthenticationCode, err = cipher.Encrypt(mac, dataKey, tree.Metadata.LastModified.Format(time.RFC3339))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt snapshot MAC: %w", err)
+	}
+
+	encrypted, err := store.EmitEncryptedFile(tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode encrypted snapshot: %w", err)
+	}
+	return encrypted, nil
+}
+
+// IsEncryptedSnapshot returns true if data is a SOPS-encrypted YAML document
+func IsEncryptedSnapshot(data []byte) bool {
+	var doc struct {
+		Sops any `yaml:"sops"`
+	}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	return doc.Sops != nil
+}
+
+// ParseSnapshot decodes plaintext YAML in the snapshot format.
+// Returns false if data is not a snapshot.
+func ParseSnapshot(data []byte) (*Snapshot, bool) {
+	var snapshot Snapshot
+	if err := yaml.Unmarshal(data, &snapshot); err != nil {
+		return nil, false
+	}
+	if snapshot.CreatedAt.IsZero() || snapshot.Secrets == nil {
+		return nil, false
+	}
+	return &snapshot, true
+}
+
+// DecodeSnapshotFile returns the plaintext YAML of a snapshot file, decompressing and
+// decrypting it as needed. Also reports whether the file was encrypted.
+func DecodeSnapshotFile(data []byte) ([]byte, bool, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decompress snapshot: %w", err)
+		}
+		if data, err = io.ReadAll(zr); err != nil {
+			return nil, false, fmt.Errorf("failed to decompress snapshot: %w", err)
+		}
+	}
+
+	if !IsEncryptedSnapshot(data) {
+		return data, false, nil
+	}
+	cleartext, err := decrypt.Data(data, "yaml")
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decrypt snapshot: %w", err)
+	}
+	return cleartext, true, nil
+}
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// UnmarshalSnapshot decodes a snapshot file, decompressing and decrypting it as needed,
+// and checks it against its manifest. Plaintext snapshots are refused unless
+// opts.AllowPlaintext is set. Medusa and vault-json backups are detected and converted.
+func UnmarshalSnapshot(data []byte, opts SnapshotLoadOptions) (*Snapshot, error) {
+	data, encrypted, err := DecodeSnapshotFile(data)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted && !opts.AllowPlaintext {
+		return nil, ErrPlaintextSnapshot
+	}
+
+	// Backups from other tools are converted, they have no manifest to check
+	format, err := DetectBackupFormat(data)
+	if err != nil {
+		return nil, err
+	}
+	if format != BackupFormatVlt {
+		snapshot, err := UnmarshalBackup(data, format)
+		if err != nil {
+			return nil, err
+		}
+		if err := snapshot.VerifyManifest(opts.VerifyKey); err != nil {
+			return nil, err
+		}
+		return snapshot, nil
+	}
+
+	var snapshot Snapshot
+	if err := yaml.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("failed to parse snapshot: %w", err)
+	}
+	if snapshot.Format > snapshotFormat {
+		return nil, fmt.Errorf("snapshot format %d is newer than supported (%d), upgrade vlt", snapshot.Format, snapshotFormat)
+	}
+
+	if err := snapshot.VerifyManifest(opts.VerifyKey); err != nil {
+		return nil, err
+	}
+
+	return &snapshot, nil
+}
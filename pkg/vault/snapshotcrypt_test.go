@@ -0,0 +1,94 @@
+package vault
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+)
+
+func TestSnapshotEncryptionRoundTrip(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SOPS_AGE_KEY", identity.String())
+
+	created := time.Date(2024, 1, 30, 14, 0, 0, 0, time.UTC)
+	snapshot := &Snapshot{
+		Format:    snapshotFormat,
+		Path:      "secret/myapp",
+		CreatedAt: created,
+		Secrets: map[string]SnapshotSecret{
+			"db": {
+				Data:    map[string]any{"password": "hunter2", "port": 5432, "enabled": true},
+				Version: 3,
+				Updated: created,
+			},
+		},
+	}
+
+	data, err := MarshalSnapshot(snapshot, SnapshotFileOptions{
+		Encryption: &SnapshotEncryption{Age: []string{identity.Recipient().String()}},
+		Gzip:       true,
+	})
+	if err != nil {
+		t.Fatalf("MarshalSnapshot() error = %v", err)
+	}
+	plain, encrypted, err := DecodeSnapshotFile(data)
+	if err != nil {
+		t.Fatalf("DecodeSnapshotFile() error = %v", err)
+	}
+	if !encrypted {
+		t.Fatal("DecodeSnapshotFile() did not detect an encrypted snapshot")
+	}
+	if !strings.Contains(string(plain), "hunter2") {
+		t.Fatal("decoded snapshot is missing a value")
+	}
+
+	got, err := UnmarshalSnapshot(data, SnapshotLoadOptions{})
+	if err != nil {
+		t.Fatalf("UnmarshalSnapshot() error = %v", err)
+	}
+	if got.Manifest == nil || got.Manifest.Secrets != 1 {
+		t.Errorf("unexpected manifest %+v", got.Manifest)
+	}
+	got.Manifest = nil
+	if !reflect.DeepEqual(got, snapshot) {
+		t.Errorf("UnmarshalSnapshot() = %+v, want %+v", got, snapshot)
+	}
+}
+
+func TestUnmarshalSnapshotPlaintext(t *testing.T) {
+	data, err := MarshalSnapshot(&Snapshot{Path: "secret/myapp"}, SnapshotFileOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsEncryptedSnapshot(data) {
+		t.Fatal("IsEncryptedSnapshot() = true for a plaintext snapshot")
+	}
+
+	if _, err := UnmarshalSnapshot(data, SnapshotLoadOptions{}); !errors.Is(err, ErrPlaintextSnapshot) {
+		t.Errorf("UnmarshalSnapshot() error = %v, want %v", err, ErrPlaintextSnapshot)
+	}
+
+	got, err := UnmarshalSnapshot(data, SnapshotLoadOptions{AllowPlaintext: true})
+	if err != nil {
+		t.Fatalf("UnmarshalSnapshot() error = %v", err)
+	}
+	if got.Path != "secret/myapp" {
+		t.Errorf("Path = %q, want secret/myapp", got.Path)
+	}
+}
+
+func TestSnapshotEncryptionNoKeys(t *testing.T) {
+	if !(SnapshotEncryption{}).IsEmpty() {
+		t.Error("zero encryption should be empty")
+	}
+	if _, err := MarshalSnapshot(&Snapshot{}, SnapshotFileOptions{Encryption: &SnapshotEncryption{}}); err == nil {
+		t.Error("expected an error without encryption keys")
+	}
+}
@@ -0,0 +1,140 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SnapshotSelection selects and renames the secrets of a snapshot before a restore.
+// The zero value selects every secret under its own path.
+type SnapshotSelection struct {
+	Include []string      // Glob patterns of relative paths to restore; a pattern matching a directory selects every secret below it
+	Exclude []string      // Glob patterns of relative paths to leave out, applied after Include
+	Map     []PathMapping // Prefix rewrites of selected paths, the first matching one applies
+}
+
+// PathMapping rewrites relative paths starting with From to start with To instead.
+// Prefixes match whole path segments; an empty From matches every path.
+type PathMapping struct {
+	From string
+	To   string
+}
+
+// ParsePathMapping parses a mapping written as "old/prefix=new/prefix"
+func ParsePathMapping(s string) (PathMapping, error) {
+	from, to, ok := strings.Cut(s, "=")
+	if !ok {
+		return PathMapping{}, fmt.Errorf("invalid path mapping %q: expected old/prefix=new/prefix", s)
+	}
+	return PathMapping{From: strings.Trim(from, "/"), To: strings.Trim(to, "/")}, nil
+}
+
+// IsEmpty returns true if the selection keeps every secret unchanged
+func (s SnapshotSelection) IsEmpty() bool {
+	return len(s.Include) == 0 && len(s.Exclude) == 0 && len(s.Map) == 0
+}
+
+// Validate checks that the include and exclude patterns are well-formed
+func (s SnapshotSelection) Validate() error {
+	if err := validatePathPatterns(s.Include, "include pattern"); err != nil {
+		return err
+	}
+	return validatePathPatterns(s.Exclude, "exclude pattern")
+}
+
+// selects returns true if a snapshot path passes the include and exclude patterns
+func (s SnapshotSelection) selects(relPath string) bool {
+	if len(s.Include) > 0 && !matchPathPatterns(s.Include, relPath) {
+		return false
+	}
+	return !matchPathPatterns(s.Exclude, relPath)
+}
+
+// mapPath returns the path a snapshot path is restored to
+func (s SnapshotSelection) mapPath(relPath string) string {
+	for _, m := range s.Map {
+		if rest, ok := cutPathPrefix(relPath, m.From); ok {
+			return joinRelPath(m.To, rest)
+		}
+	}
+	return relPath
+}
+
+// covers returns true if a path at the restore target corresponds to a selected
+// snapshot path, i.e. if restoring the selection is responsible for it
+func (s SnapshotSelection) covers(targetPath string) bool {
+	candidates := []string{targetPath}
+	for _, m := range s.Map {
+		if rest, ok := cutPathPrefix(targetPath, m.To); ok {
+			candidates = append(candidates, joinRelPath(m.From, rest))
+		}
+	}
+
+	for _, relPath := range candidates {
+		if s.mapPath(relPath) == targetPath && s.selects(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns a snapshot holding the selected secrets under their mapped paths.
+// The result has no manifest, as it no longer matches the snapshot file.
+func (s SnapshotSelection) Apply(snapshot *Snapshot) (*Snapshot, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	selected := &Snapshot{
+		Format:    snapshot.Format,
+		Path:      snapshot.Path,
+		CreatedAt: snapshot.CreatedAt,
+		At:        snapshot.At,
+		Secrets:   make(map[string]SnapshotSecret),
+	}
+
+	sources := make(map[string]string)
+	relPaths := make([]string, 0, len(snapshot.Secrets))
+	for relPath := range snapshot.Secrets {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	for _, relPath := range relPaths {
+		if !s.selects(relPath) {
+			continue
+		}
+
+		mapped := s.mapPath(relPath)
+		if mapped == "" {
+			return nil, fmt.Errorf("%s is mapped to an empty path", relPath)
+		}
+		if other, ok := sources[mapped]; ok {
+			return nil, fmt.Errorf("%s and %s are both mapped to %s", other, relPath, mapped)
+		}
+		sources[mapped] = relPath
+		selected.Secrets[mapped] = snapshot.Secrets[relPath]
+	}
+
+	return selected, nil
+}
+
+// cutPathPrefix removes a prefix of whole path segments from a relative path
+func cutPathPrefix(relPath, prefix string) (string, bool) {
+	if prefix == "" {
+		return relPath, true
+	}
+	if relPath == prefix {
+		return "", true
+	}
+	return strings.CutPrefix(relPath, prefix+"/")
+}
+
+// joinRelPath joins relative path parts, either of which may be empty
+func joinRelPath(prefix, rest string) string {
+	if prefix == "" || rest == "" {
+		return prefix + rest
+	}
+	return prefix + "/" + rest
+}
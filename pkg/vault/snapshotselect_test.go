@@ -0,0 +1,140 @@
+package vault
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSnapshotSelectionApply(t *testing.T) {
+	snapshot := &Snapshot{
+		Path: "secret/myapp",
+		Secrets: map[string]SnapshotSecret{
+			"config":            {Value: "c"},
+			"database/password": {Value: "p"},
+			"database/user":     {Value: "u"},
+			"cache/redis":       {Value: "r"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		selection SnapshotSelection
+		expected  map[string]string // restored path -> value
+		wantErr   bool
+	}{
+		{
+			name:      "everything",
+			selection: SnapshotSelection{},
+			expected:  map[string]string{"config": "c", "database/password": "p", "database/user": "u", "cache/redis": "r"},
+		},
+		{
+			name:      "include glob",
+			selection: SnapshotSelection{Include: []string{"database/*"}},
+			expected:  map[string]string{"database/password": "p", "database/user": "u"},
+		},
+		{
+			name:      "include directory",
+			selection: SnapshotSelection{Include: []string{"database"}},
+			expected:  map[string]string{"database/password": "p", "database/user": "u"},
+		},
+		{
+			name:      "exclude",
+			selection: SnapshotSelection{Exclude: []string{"cache", "database/user"}},
+			expected:  map[string]string{"config": "c", "database/password": "p"},
+		},
+		{
+			name:      "map prefix",
+			selection: SnapshotSelection{Include: []string{"database"}, Map: []PathMapping{{From: "database", To: "db/old"}}},
+			expected:  map[string]string{"db/old/password": "p", "db/old/user": "u"},
+		},
+		{
+			name:      "single secret to another name",
+			selection: SnapshotSelection{Include: []string{"database/password"}, Map: []PathMapping{{From: "database/password", To: "db-pw"}}},
+			expected:  map[string]string{"db-pw": "p"},
+		},
+		{
+			name:      "prefix matches whole segments",
+			selection: SnapshotSelection{Map: []PathMapping{{From: "cache/red", To: "x"}}},
+			expected:  map[string]string{"config": "c", "database/password": "p", "database/user": "u", "cache/redis": "r"},
+		},
+		{
+			name:      "collision",
+			selection: SnapshotSelection{Map: []PathMapping{{From: "cache/redis", To: "config"}}},
+			wantErr:   true,
+		},
+		{
+			name:      "empty path",
+			selection: SnapshotSelection{Map: []PathMapping{{From: "config", To: ""}}},
+			wantErr:   true,
+		},
+		{
+			name:      "malformed pattern",
+			selection: SnapshotSelection{Include: []string{"db/["}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.selection.Apply(snapshot)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Apply() expected error, got %v", got.Secrets)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			values := make(map[string]string)
+			for relPath, secret := range got.Secrets {
+				values[relPath] = secret.Value.(string)
+			}
+			if !reflect.DeepEqual(values, tt.expected) {
+				t.Errorf("Apply() = %v, want %v", values, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSnapshotSelectionCovers(t *testing.T) {
+	selection := SnapshotSelection{
+		Include: []string{"database"},
+		Exclude: []string{"database/legacy"},
+		Map:     []PathMapping{{From: "database", To: "db"}},
+	}
+	target := []string{"db/password", "db/legacy", "database/password", "config", "db"}
+
+	var covered []string
+	for _, p := range target {
+		if selection.covers(p) {
+			covered = append(covered, p)
+		}
+	}
+	sort.Strings(covered)
+
+	// database/password would be restored to db/password, so the original path is not covered
+	expected := []string{"db", "db/password"}
+	if !reflect.DeepEqual(covered, expected) {
+		t.Errorf("covers() matched %v, want %v", covered, expected)
+	}
+
+	if !(SnapshotSelection{}).covers("anything/at/all") {
+		t.Error("empty selection should cover every path")
+	}
+}
+
+func TestParsePathMapping(t *testing.T) {
+	m, err := ParsePathMapping("/database/old/=db/new")
+	if err != nil {
+		t.Fatalf("ParsePathMapping() error = %v", err)
+	}
+	if m != (PathMapping{From: "database/old", To: "db/new"}) {
+		t.Errorf("ParsePathMapping() = %+v", m)
+	}
+	if _, err := ParsePathMapping("database"); err == nil {
+		t.Error("ParsePathMapping() expected error without '='")
+	}
+}
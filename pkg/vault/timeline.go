@@ -3,7 +3,9 @@ package vault
 import (
 	"context"
 	"fmt"
+	"path"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -13,7 +15,9 @@ type TimelineEntry struct {
 	SecretPath string // Relative path within the directory
 	FullPath   string // Full path to the secret
 	Version    int
-	IsCreation bool // True if this is version 1
+	IsCreation bool        // True if this is version 1
+	Provenance *Provenance // Who wrote the version and why, if recorded
+	FormerPath string      // Path the secret had when the version was written, if moved since
 }
 
 // GetTimeline returns a chronological timeline of all changes under a path
@@ -44,6 +48,7 @@ func (c *Client) GetTimeline(ctx context.Context, path string) ([]TimelineEntry,
 				FullPath:   fullPath,
 				Version:    v.Version,
 				IsCreation: v.Version == 1,
+				Provenance: v.Provenance,
 			})
 		}
 	}
@@ -60,6 +65,160 @@ func (c *Client) GetTimeline(ctx context.Context, path string) ([]TimelineEntry,
 	return timeline, nil
 }
 
+// TimelineFilter selects entries of a timeline. Zero fields match everything.
+type TimelineFilter struct {
+	Since time.Time // Only entries at or after this time
+	Until time.Time // Only entries at or before this time
+	Paths []string  // Glob patterns matched against the relative secret path or its parent directories
+}
+
+// InWindow returns true if t is within the Since/Until window
+func (f TimelineFilter) InWindow(t time.Time) bool {
+	if !f.Since.IsZero() && t.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && t.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// MatchPath returns true if a relative secret path matches one of the path patterns.
+// A pattern matching a directory matches every secret below it.
+func (f TimelineFilter) MatchPath(secretPath string) bool {
+	if len(f.Paths) == 0 {
+		return true
+	}
+	return matchPathPatterns(f.Paths, secretPath)
+}
+
+// Validate checks that the path patterns are well-formed
+func (f TimelineFilter) Validate() error {
+	return validatePathPatterns(f.Paths, "path filter")
+}
+
+// matchPathPatterns returns true if a relative secret path or one of its parent
+// directories matches one of the glob patterns
+func matchPathPatterns(patterns []string, secretPath string) bool {
+	parts := strings.Split(secretPath, "/")
+	for _, pattern := range patterns {
+		for i := len(parts); i > 0; i-- {
+			if ok, _ := path.Match(pattern, strings.Join(parts[:i], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validatePathPatterns checks that glob patterns are well-formed
+func validatePathPatterns(patterns []string, kind string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid %s %q: %w", kind, pattern, err)
+		}
+	}
+	return nil
+}
+
+// FilterTimeline returns the entries of a timeline matching a filter, keeping their order
+func FilterTimeline(timeline []TimelineEntry, filter TimelineFilter) []TimelineEntry {
+	var result []TimelineEntry
+	for _, entry := range timeline {
+		if filter.InWindow(entry.Time) && filter.MatchPath(entry.SecretPath) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// GetEntryChanges returns the keys added, modified or deleted by a timeline entry.
+// For a creation, every key of the first version is reported as added.
+func (c *Client) GetEntryChanges(ctx context.Context, entry TimelineEntry) ([]VersionChange, error) {
+	if !entry.IsCreation {
+		return c.CompareVersions(ctx, entry.FullPath, entry.Version-1, entry.Version)
+	}
+
+	data, err := c.ReadSecretVersion(ctx, entry.FullPath, entry.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []VersionChange
+	for key, val := range data {
+		valStr := fmt.Sprintf("%v", val)
+		changes = append(changes, VersionChange{
+			Key:       key,
+			Type:      ChangeAdded,
+			NewValue:  valStr,
+			NewLength: len(valStr),
+		})
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+
+	return changes, nil
+}
+
+// WindowChange describes how a secret changed within a time window
+type WindowChange struct {
+	SecretPath  string // Relative path within the directory
+	FromVersion int    // Version current at the start of the window, 0 if created within it
+	ToVersion   int    // Version current at the end of the window
+	Versions    int    // Number of versions created within the window
+}
+
+// GetChangesBetween returns the secrets under a path that changed after start and
+// at or before end, based on the directory timeline. Sorted by secret path.
+func (c *Client) GetChangesBetween(ctx context.Context, path string, start, end time.Time) ([]WindowChange, error) {
+	timeline, err := c.GetTimeline(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return changesBetween(timeline, start, end), nil
+}
+
+// changesBetween summarizes a timeline (newest first) into per-secret changes within a window
+func changesBetween(timeline []TimelineEntry, start, end time.Time) []WindowChange {
+	bySecret := make(map[string]*WindowChange)
+
+	// Walk oldest first so versions accumulate in order
+	for i := len(timeline) - 1; i >= 0; i-- {
+		entry := timeline[i]
+		if entry.Time.After(end) {
+			continue
+		}
+
+		change, ok := bySecret[entry.SecretPath]
+		if !ok {
+			change = &WindowChange{SecretPath: entry.SecretPath}
+			bySecret[entry.SecretPath] = change
+		}
+
+		if !entry.Time.After(start) {
+			change.FromVersion = entry.Version
+		} else {
+			change.Versions++
+		}
+		change.ToVersion = entry.Version
+	}
+
+	var changes []WindowChange
+	for _, change := range bySecret {
+		if change.Versions > 0 {
+			changes = append(changes, *change)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].SecretPath < changes[j].SecretPath
+	})
+
+	return changes
+}
+
 // GetPrevVersions retrieves the previous version of each secret under a path
 // Returns a flattened map suitable for comparison
 func (c *Client) GetPrevVersions(ctx context.Context, basePath string) (map[string]any, error) {
@@ -115,18 +274,133 @@ func (c *Client) GetPrevVersions(ctx context.Context, basePath string) (map[stri
 
 // GetStateAtChangesAgo retrieves the state of a directory N changes ago
 // It builds a timeline of all version changes across all secrets, then
-// computes what version each secret was at N changes ago
+// computes what version each secret was at N changes ago. Every version counts
+// as a change, including the first, so secrets created since are left out.
+// Fails if a version a secret was at can't be read anymore.
 func (c *Client) GetStateAtChangesAgo(ctx context.Context, basePath string, changesAgo int) (map[string]any, error) {
+	versions, _, err := c.versionsAtChangesAgo(ctx, basePath, changesAgo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Read each secret at the computed version
+	result := make(map[string]any)
+	for relPath, v := range versions {
+		secrets, err := c.readVersionAtChangesAgo(ctx, basePath+"/"+relPath, v, changesAgo)
+		if err != nil {
+			return nil, err
+		}
+
+		flattened := FlattenAndExtractValues(secrets, true)
+		for k, v := range flattened {
+			if k == "" {
+				result[relPath] = v
+			} else {
+				result[relPath+"."+k] = v
+			}
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no secrets found at %d changes ago", changesAgo)
+	}
+
+	return result, nil
+}
+
+// CreateSnapshotAtChangesAgo creates a snapshot of a directory as it was N changes ago,
+// using the same timeline as GetStateAtChangesAgo. Secrets created since are left out.
+func (c *Client) CreateSnapshotAtChangesAgo(ctx context.Context, basePath string, changesAgo int) (*Snapshot, error) {
+	versions, histories, err := c.versionsAtChangesAgo(ctx, basePath, changesAgo)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{
+		Format:    snapshotFormat,
+		Path:      basePath,
+		CreatedAt: time.Now(),
+		Secrets:   make(map[string]SnapshotSecret),
+	}
+
+	for relPath, v := range versions {
+		data, err := c.readVersionAtChangesAgo(ctx, basePath+"/"+relPath, v, changesAgo)
+		if err != nil {
+			return nil, err
+		}
+
+		secret := SnapshotSecret{
+			Data:    snapshotFields(data),
+			Version: v.Version,
+			Updated: v.CreatedTime,
+		}
+		if current := histories[relPath][0].Version; current != v.Version {
+			secret.Current = current
+		}
+		snapshot.Secrets[relPath] = secret
+	}
+
+	if len(snapshot.Secrets) == 0 {
+		return nil, fmt.Errorf("no secrets existed under %s %d changes ago", basePath, changesAgo)
+	}
+
+	return snapshot, nil
+}
+
+// readVersionAtChangesAgo reads version v of a secret, which was current N changes ago
+func (c *Client) readVersionAtChangesAgo(ctx context.Context, path string, v VersionInfo, changesAgo int) (map[string]any, error) {
+	when := fmt.Sprintf("%d changes ago", changesAgo)
+	if err := unreadableAt(path, v, when); err != nil {
+		return nil, err
+	}
+
+	data, err := c.ReadSecretVersion(ctx, path, v.Version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read secret %s: %w", path, err)
+	}
+	if data == nil {
+		return nil, fmt.Errorf("%s was at version %d %s, which can't be read anymore", path, v.Version, when)
+	}
+	return data, nil
+}
+
+// versionsAtChangesAgo computes the version of each secret under basePath N changes ago.
+// Secrets that did not exist at that point are left out. Also returns the versions of
+// each secret, newest first, including deleted and destroyed ones.
+func (c *Client) versionsAtChangesAgo(ctx context.Context, basePath string, changesAgo int) (map[string]VersionInfo, map[string][]VersionInfo, error) {
 	secretPaths, err := c.ListSecretPaths(ctx, basePath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list secrets under %s: %w", basePath, err)
+		return nil, nil, fmt.Errorf("failed to list secrets under %s: %w", basePath, err)
 	}
 
 	if len(secretPaths) == 0 {
-		return nil, fmt.Errorf("no secrets found under %s", basePath)
+		return nil, nil, fmt.Errorf("no secrets found under %s", basePath)
+	}
+
+	histories := make(map[string][]VersionInfo)
+	for _, relPath := range secretPaths {
+		versions, err := c.getAllVersions(ctx, basePath+"/"+relPath)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(versions) > 0 {
+			histories[relPath] = versions
+		}
 	}
 
-	// Build timeline of all changes (version > 1 only, since v1 is creation not change)
+	versions, err := stateAtChangesAgo(histories, changesAgo)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot go back %d changes under %s: %w", changesAgo, basePath, err)
+	}
+	return versions, histories, nil
+}
+
+// stateAtChangesAgo undoes the last N changes to a set of secrets, given the versions
+// of each secret newest first, and returns the version each secret was at. Every
+// version written counts as a change, including the first. Secrets created within
+// the last N changes are left out, and so are secrets whose version at that point was
+// deleted before the oldest undone change.
+func stateAtChangesAgo(histories map[string][]VersionInfo, changesAgo int) (map[string]VersionInfo, error) {
 	type changeEvent struct {
 		secretPath  string
 		version     int
@@ -134,80 +408,66 @@ func (c *Client) GetStateAtChangesAgo(ctx context.Context, basePath string, chan
 	}
 
 	var allChanges []changeEvent
-	secretCurrentVersions := make(map[string]int)
-
-	for _, relPath := range secretPaths {
-		fullPath := basePath + "/" + relPath
-		history, err := c.GetVersionHistory(ctx, fullPath)
-		if err != nil || len(history) == 0 {
-			continue
-		}
-
-		secretCurrentVersions[relPath] = history[0].Version
-
-		for _, v := range history {
-			if v.Version > 1 {
-				allChanges = append(allChanges, changeEvent{
-					secretPath:  relPath,
-					version:     v.Version,
-					createdTime: v.CreatedTime,
-				})
-			}
+	for relPath, versions := range histories {
+		for _, v := range versions {
+			allChanges = append(allChanges, changeEvent{
+				secretPath:  relPath,
+				version:     v.Version,
+				createdTime: v.CreatedTime,
+			})
 		}
 	}
 
 	if len(allChanges) == 0 {
-		return nil, fmt.Errorf("no changes found under %s (all secrets are at version 1)", basePath)
+		return nil, fmt.Errorf("no changes found")
+	}
+	if changesAgo > len(allChanges) {
+		return nil, fmt.Errorf("only %d changes exist", len(allChanges))
 	}
 
-	// Sort by time descending (most recent first)
+	// Sort by time descending (most recent first), later versions first on ties
 	sort.Slice(allChanges, func(i, j int) bool {
-		return allChanges[i].createdTime.After(allChanges[j].createdTime)
+		if !allChanges[i].createdTime.Equal(allChanges[j].createdTime) {
+			return allChanges[i].createdTime.After(allChanges[j].createdTime)
+		}
+		return allChanges[i].version > allChanges[j].version
 	})
 
-	if changesAgo > len(allChanges) {
-		return nil, fmt.Errorf("only %d changes exist under %s, cannot go back %d changes", len(allChanges), basePath, changesAgo)
-	}
-
-	// Compute the version of each secret N changes ago
 	// Start with current versions and "undo" the last N changes
-	secretVersionsAtPoint := make(map[string]int)
-	for path, ver := range secretCurrentVersions {
-		secretVersionsAtPoint[path] = ver
+	pointVersions := make(map[string]int)
+	for path, versions := range histories {
+		pointVersions[path] = versions[0].Version
 	}
-
-	for i := 0; i < changesAgo && i < len(allChanges); i++ {
-		change := allChanges[i]
-		if currentVer, ok := secretVersionsAtPoint[change.secretPath]; ok && currentVer == change.version {
-			secretVersionsAtPoint[change.secretPath] = change.version - 1
+	for _, change := range allChanges[:changesAgo] {
+		if pointVersions[change.secretPath] == change.version {
+			pointVersions[change.secretPath] = change.version - 1
 		}
 	}
 
-	// Read each secret at the computed version
-	result := make(map[string]any)
-	for relPath, version := range secretVersionsAtPoint {
-		if version < 1 {
-			continue
-		}
+	// The point lies just before the oldest undone change
+	point := time.Now()
+	if changesAgo > 0 {
+		point = allChanges[changesAgo-1].createdTime
+	}
 
-		fullPath := basePath + "/" + relPath
-		secrets, err := c.ReadSecretVersion(ctx, fullPath, version)
-		if err != nil || secrets == nil {
+	result := make(map[string]VersionInfo)
+	for path, versions := range histories {
+		version := pointVersions[path]
+		if version < 1 {
 			continue
 		}
 
-		flattened := FlattenAndExtractValues(secrets, true)
-		for k, v := range flattened {
-			if k == "" {
-				result[relPath] = v
-			} else {
-				result[relPath+"."+k] = v
+		// Versions dropped from the metadata (beyond max_versions) are gone for good
+		v := VersionInfo{Version: version, Destroyed: true}
+		for _, known := range versions {
+			if known.Version == version {
+				v = known
 			}
 		}
-	}
-
-	if len(result) == 0 {
-		return nil, fmt.Errorf("no secrets found at %d changes ago", changesAgo)
+		if !v.DeletedTime.IsZero() && v.DeletedTime.Before(point) {
+			continue
+		}
+		result[path] = v
 	}
 
 	return result, nil
@@ -0,0 +1,257 @@
+package vault
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChangesBetween(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+	// Newest first, as returned by GetTimeline
+	timeline := []TimelineEntry{
+		{Time: at(10), SecretPath: "config", Version: 4},
+		{Time: at(6), SecretPath: "api/key", Version: 1},
+		{Time: at(5), SecretPath: "config", Version: 3},
+		{Time: at(4), SecretPath: "config", Version: 2},
+		{Time: at(1), SecretPath: "database", Version: 2},
+		{Time: at(0), SecretPath: "config", Version: 1},
+		{Time: at(0), SecretPath: "database", Version: 1},
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		expected   []WindowChange
+	}{
+		{
+			name:  "window with update and creation",
+			start: at(3),
+			end:   at(8),
+			expected: []WindowChange{
+				{SecretPath: "api/key", FromVersion: 0, ToVersion: 1, Versions: 1},
+				{SecretPath: "config", FromVersion: 1, ToVersion: 3, Versions: 2},
+			},
+		},
+		{
+			name:  "start is exclusive, end is inclusive",
+			start: at(1),
+			end:   at(4),
+			expected: []WindowChange{
+				{SecretPath: "config", FromVersion: 1, ToVersion: 2, Versions: 1},
+			},
+		},
+		{
+			name:     "quiet window",
+			start:    at(7),
+			end:      at(9),
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := changesBetween(timeline, tt.start, tt.end)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("changesBetween() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFilterTimeline(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+	timeline := []TimelineEntry{
+		{Time: at(10), SecretPath: "config", Version: 2},
+		{Time: at(6), SecretPath: "api/key", Version: 1},
+		{Time: at(5), SecretPath: "db/prod/password", Version: 3},
+		{Time: at(1), SecretPath: "db/dev/password", Version: 2},
+	}
+
+	paths := func(entries []TimelineEntry) []string {
+		var result []string
+		for _, e := range entries {
+			result = append(result, e.SecretPath)
+		}
+		return result
+	}
+
+	tests := []struct {
+		name     string
+		filter   TimelineFilter
+		expected []string
+	}{
+		{
+			name:     "no filter",
+			filter:   TimelineFilter{},
+			expected: []string{"config", "api/key", "db/prod/password", "db/dev/password"},
+		},
+		{
+			name:     "since and until inclusive",
+			filter:   TimelineFilter{Since: at(5), Until: at(6)},
+			expected: []string{"api/key", "db/prod/password"},
+		},
+		{
+			name:     "directory pattern matches secrets below it",
+			filter:   TimelineFilter{Paths: []string{"db"}},
+			expected: []string{"db/prod/password", "db/dev/password"},
+		},
+		{
+			name:     "glob",
+			filter:   TimelineFilter{Paths: []string{"db/*/password", "conf*"}},
+			expected: []string{"config", "db/prod/password", "db/dev/password"},
+		},
+		{
+			name:     "glob and window",
+			filter:   TimelineFilter{Since: at(2), Paths: []string{"db/*"}},
+			expected: []string{"db/prod/password"},
+		},
+		{
+			name:     "no match",
+			filter:   TimelineFilter{Paths: []string{"cache"}},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := paths(FilterTimeline(timeline, tt.filter))
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("FilterTimeline() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTimelineFilterValidate(t *testing.T) {
+	if err := (TimelineFilter{Paths: []string{"db/*"}}).Validate(); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	if err := (TimelineFilter{Paths: []string{"db/["}}).Validate(); err == nil {
+		t.Error("Validate() expected error for malformed pattern")
+	}
+}
+
+func TestStateAtChangesAgo(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+	// Changes, oldest first: a@1 (h0), a@2 (h1), b@1 (h2), a@3 (h3), c@1 (h4)
+	histories := map[string][]VersionInfo{
+		"a": {
+			{Version: 3, CreatedTime: at(3)},
+			{Version: 2, CreatedTime: at(1), Deleted: true, DeletedTime: at(5)},
+			{Version: 1, CreatedTime: at(0)},
+		},
+		"b": {
+			{Version: 1, CreatedTime: at(2)},
+		},
+		"c": {
+			{Version: 1, CreatedTime: at(4), Deleted: true, DeletedTime: at(4)},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		changesAgo int
+		expected   map[string]int
+	}{
+		{"current", 0, map[string]int{"a": 3, "b": 1}},
+		{"creation undone", 1, map[string]int{"a": 3, "b": 1}},
+		{"deleted version kept", 2, map[string]int{"a": 2, "b": 1}},
+		{"secret created since", 3, map[string]int{"a": 2}},
+		{"first version", 4, map[string]int{"a": 1}},
+		{"before everything", 5, map[string]int{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := stateAtChangesAgo(histories, tt.changesAgo)
+			if err != nil {
+				t.Fatalf("stateAtChangesAgo() error = %v", err)
+			}
+			got := make(map[string]int)
+			for path, v := range state {
+				got[path] = v.Version
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("stateAtChangesAgo(%d) = %v, want %v", tt.changesAgo, got, tt.expected)
+			}
+		})
+	}
+
+	// The deleted version is returned as such, for readers to report
+	state, _ := stateAtChangesAgo(histories, 2)
+	if !state["a"].Deleted {
+		t.Error("stateAtChangesAgo() lost the deletion of a@2")
+	}
+
+	if _, err := stateAtChangesAgo(histories, 6); err == nil {
+		t.Error("stateAtChangesAgo() expected error going back more changes than exist")
+	}
+}
+
+func TestStateAtChangesAgoPurgedVersion(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+
+	// Versions before 11 were dropped by max_versions
+	histories := map[string][]VersionInfo{
+		"a": {
+			{Version: 12, CreatedTime: base.Add(time.Hour)},
+			{Version: 11, CreatedTime: base},
+		},
+	}
+
+	state, err := stateAtChangesAgo(histories, 2)
+	if err != nil {
+		t.Fatalf("stateAtChangesAgo() error = %v", err)
+	}
+	if v, ok := state["a"]; !ok || v.Version != 10 || !v.Destroyed {
+		t.Errorf("stateAtChangesAgo() = %+v, want version 10 reported as destroyed", state)
+	}
+}
+
+func TestGetStateAtChangesAgo(t *testing.T) {
+	base := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+
+	// Changes, oldest first: key1 created, key1 modified, key2 created
+	client := newFakeKV(t, map[string][]fakeVersion{
+		"app/key1": {
+			{at(0), map[string]any{"value": "original"}},
+			{at(1), map[string]any{"value": "modified"}},
+		},
+		"app/key2": {
+			{at(2), map[string]any{"value": "new"}},
+		},
+	}, nil)
+
+	// What diff path@-N compares against: the creation of key2 is the last change
+	tests := []struct {
+		changesAgo int
+		expected   map[string]any
+	}{
+		{1, map[string]any{"key1": "modified"}},
+		{2, map[string]any{"key1": "original"}},
+	}
+
+	for _, tt := range tests {
+		got, err := client.GetStateAtChangesAgo(context.Background(), "secret/app", tt.changesAgo)
+		if err != nil {
+			t.Fatalf("GetStateAtChangesAgo(%d) error = %v", tt.changesAgo, err)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("GetStateAtChangesAgo(%d) = %v, want %v", tt.changesAgo, got, tt.expected)
+		}
+	}
+
+	// Before key1 was created, nothing existed
+	if got, err := client.GetStateAtChangesAgo(context.Background(), "secret/app", 3); err == nil {
+		t.Errorf("GetStateAtChangesAgo(3) = %v, want an error", got)
+	}
+}
@@ -0,0 +1,274 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Custom metadata keys recorded on secrets in the trash
+const (
+	trashOriginKey    = "vlt_trash_origin"
+	trashTimestampKey = "vlt_trashed_at"
+)
+
+// trashIDFormat is the layout of trash batch IDs (UTC timestamps)
+const trashIDFormat = "20060102T150405.000Z"
+
+// TrashEntry represents one batch of deleted secrets in the trash
+type TrashEntry struct {
+	ID        string
+	TrashedAt time.Time
+	Secrets   []TrashedSecret
+}
+
+// TrashedSecret represents a single secret kept in the trash
+type TrashedSecret struct {
+	Origin    string // Path the secret was deleted from
+	TrashPath string // Path of the copy in the trash
+	Versions  int    // Number of versions preserved
+}
+
+// TrashEnabled returns true if deleted secrets are moved to the trash
+func (c *Client) TrashEnabled() bool {
+	return c.trashPath != ""
+}
+
+// TrashID returns the trash batch used by this client, or "" if nothing was trashed yet
+func (c *Client) TrashID() string {
+	return c.trashID
+}
+
+// isTrashPath returns true if the path is inside the trash area
+func (c *Client) isTrashPath(path string) bool {
+	return path == c.trashPath || strings.HasPrefix(path, c.trashPath+"/")
+}
+
+// hiddenTrashDir returns the path of the trash relative to a listed path, or "" if the
+// listing doesn't reach it. The trash lives inside the mount, but is only listed when
+// asked for explicitly, so recursive operations on the mount leave it alone.
+func (c *Client) hiddenTrashDir(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	if !c.TrashEnabled() || c.isTrashPath(path) {
+		return ""
+	}
+	rel, _ := strings.CutPrefix(c.trashPath, path+"/")
+	if rel == c.trashPath {
+		return ""
+	}
+	return rel
+}
+
+// moveToTrash copies the version history of a secret into the current trash batch
+func (c *Client) moveToTrash(ctx context.Context, path string) error {
+	now := time.Now().UTC()
+	if c.trashID == "" {
+		c.trashID = now.Format(trashIDFormat)
+	}
+	dst := c.trashedPath(path)
+
+	if err := c.copyToTrash(ctx, path, dst, now); err != nil {
+		// The secret is kept, so don't leave a partial copy of it in the trash
+		if purgeErr := c.purgeSecret(ctx, dst); purgeErr != nil {
+			return fmt.Errorf("failed to move %s to trash: %w (partial copy left at %s: %v)", path, err, dst, purgeErr)
+		}
+		return fmt.Errorf("failed to move %s to trash: %w", path, err)
+	}
+	return nil
+}
+
+// copyToTrash copies the version history and metadata of a secret to dst
+func (c *Client) copyToTrash(ctx context.Context, path, dst string, now time.Time) error {
+	replayed, err := c.copyVersionHistory(ctx, path, dst)
+	if err != nil {
+		return err
+	}
+	if len(replayed) == 0 {
+		// The secret has no versions left to preserve
+		return nil
+	}
+
+	metadata, err := c.GetMetadata(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	// Keep the original metadata alongside the trash bookkeeping keys
+	fields := copiedMetadataFields(metadata, replayed, false)
+	custom := fields["custom_metadata"].(map[string]string)
+	custom[trashOriginKey] = path
+	custom[trashTimestampKey] = now.Format(time.RFC3339)
+
+	return c.writeMetadata(ctx, dst, fields)
+}
+
+// trashedPath returns the path of the copy of a secret in the current trash batch
+func (c *Client) trashedPath(path string) string {
+	return c.trashPath + "/" + c.trashID + "/" + path
+}
+
+// ListTrash returns all batches in the trash, oldest first
+func (c *Client) ListTrash(ctx context.Context) ([]TrashEntry, error) {
+	if !c.TrashEnabled() {
+		return nil, errTrashDisabled
+	}
+
+	ids, _, err := c.ListDirectories(ctx, c.trashPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []TrashEntry
+	for _, id := range ids {
+		entry, err := c.getTrashEntry(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].TrashedAt.Before(entries[j].TrashedAt)
+	})
+
+	return entries, nil
+}
+
+// getTrashEntry reads the secrets stored in a single trash batch
+func (c *Client) getTrashEntry(ctx context.Context, id string) (*TrashEntry, error) {
+	base := c.trashPath + "/" + id
+	paths, err := c.ListSecretPaths(ctx, base)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("trash entry not found: %s", id)
+	}
+
+	entry := &TrashEntry{ID: id}
+	if t, err := time.Parse(trashIDFormat, id); err == nil {
+		entry.TrashedAt = t
+	}
+
+	for _, relPath := range paths {
+		trashPath := base + "/" + relPath
+		secret := TrashedSecret{Origin: relPath, TrashPath: trashPath}
+
+		metadata, err := c.GetMetadata(ctx, trashPath)
+		if err != nil {
+			return nil, err
+		}
+		if metadata != nil {
+			secret.Versions = metadata.CurrentVersion
+			if origin := metadata.CustomMetadata[trashOriginKey]; origin != "" {
+				secret.Origin = origin
+			}
+			if t, err := time.Parse(time.RFC3339, metadata.CustomMetadata[trashTimestampKey]); err == nil && entry.TrashedAt.IsZero() {
+				entry.TrashedAt = t
+			}
+		}
+
+		entry.Secrets = append(entry.Secrets, secret)
+	}
+
+	return entry, nil
+}
+
+// RestoreTrash moves all secrets in a trash batch back to their original paths,
+// including their version history. Fails without changes if any original path exists.
+// Returns the restored paths.
+func (c *Client) RestoreTrash(ctx context.Context, id string) ([]string, error) {
+	if !c.TrashEnabled() {
+		return nil, errTrashDisabled
+	}
+
+	entry, err := c.getTrashEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range entry.Secrets {
+		if err := c.checkDestinationNotExists(ctx, secret.Origin); err != nil {
+			return nil, err
+		}
+	}
+
+	var restored []string
+	for _, secret := range entry.Secrets {
+		replayed, err := c.copyVersionHistory(ctx, secret.TrashPath, secret.Origin)
+		if err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", secret.Origin, err)
+		}
+		if err := c.restoreTrashMetadata(ctx, secret, replayed); err != nil {
+			return restored, err
+		}
+		if err := c.purgeSecret(ctx, secret.TrashPath); err != nil {
+			return restored, err
+		}
+		restored = append(restored, secret.Origin)
+	}
+
+	return restored, nil
+}
+
+// restoreTrashMetadata copies the original metadata of a trashed secret back to its origin
+func (c *Client) restoreTrashMetadata(ctx context.Context, secret TrashedSecret, replayed []VersionInfo) error {
+	metadata, err := c.GetMetadata(ctx, secret.TrashPath)
+	if err != nil {
+		return err
+	}
+
+	fields := copiedMetadataFields(metadata, replayed, false)
+	custom := fields["custom_metadata"].(map[string]string)
+	delete(custom, trashOriginKey)
+	delete(custom, trashTimestampKey)
+
+	return c.writeMetadata(ctx, secret.Origin, fields)
+}
+
+// PurgeTrash permanently deletes a single trash batch
+func (c *Client) PurgeTrash(ctx context.Context, id string) error {
+	if !c.TrashEnabled() {
+		return errTrashDisabled
+	}
+
+	entry, err := c.getTrashEntry(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range entry.Secrets {
+		if err := c.purgeSecret(ctx, secret.TrashPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PurgeTrashOlderThan permanently deletes all trash batches older than the given age.
+// Returns the IDs of the purged batches.
+func (c *Client) PurgeTrashOlderThan(ctx context.Context, age time.Duration) ([]string, error) {
+	entries, err := c.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-age)
+	var purged []string
+	for _, entry := range entries {
+		if entry.TrashedAt.IsZero() || !entry.TrashedAt.Before(cutoff) {
+			continue
+		}
+		if err := c.PurgeTrash(ctx, entry.ID); err != nil {
+			return purged, err
+		}
+		purged = append(purged, entry.ID)
+	}
+
+	return purged, nil
+}
+
+var errTrashDisabled = fmt.Errorf("trash is not enabled (set VLT_TRASH_PATH, e.g. secret/.vlt-trash)")
@@ -0,0 +1,77 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHiddenTrashDir(t *testing.T) {
+	tests := []struct {
+		name      string
+		trashPath string
+		path      string
+		expected  string
+	}{
+		{"mount root", "secret/.vlt-trash", "secret", ".vlt-trash"},
+		{"trailing slash", "secret/.vlt-trash", "secret/", ".vlt-trash"},
+		{"nested trash", "secret/ops/.vlt-trash", "secret", "ops/.vlt-trash"},
+		{"parent of trash", "secret/ops/.vlt-trash", "secret/ops", ".vlt-trash"},
+		{"sibling directory", "secret/.vlt-trash", "secret/myapp", ""},
+		{"other mount", "secret/.vlt-trash", "kv", ""},
+		{"similar prefix", "secret/.vlt-trash", "secre", ""},
+		{"trash itself", "secret/.vlt-trash", "secret/.vlt-trash", ""},
+		{"inside trash", "secret/.vlt-trash", "secret/.vlt-trash/20240130T140000.000Z", ""},
+		{"trash disabled", "", "secret", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{trashPath: tt.trashPath}
+			if got := c.hiddenTrashDir(tt.path); got != tt.expected {
+				t.Errorf("hiddenTrashDir(%q) = %q, want %q", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrashHiddenFromReads(t *testing.T) {
+	at := time.Date(2024, 1, 30, 12, 0, 0, 0, time.UTC)
+	client := newFakeKV(t, map[string][]fakeVersion{
+		"keep":                                 {{at, map[string]any{"value": "k"}}},
+		"app/db":                               {{at, map[string]any{"password": "p"}}},
+		".vlt-trash/20240130T140000.000Z/gone": {{at, map[string]any{"value": "deleted"}}},
+		".vlt-trash/20240130T140000.000Z/app/token": {{at, map[string]any{"value": "deleted"}}},
+	}, nil)
+	ctx := context.Background()
+
+	reads := map[string]func(context.Context, string) (map[string]any, error){
+		"Export": client.Export,
+		"Get":    client.Get,
+	}
+	for name, read := range reads {
+		t.Run(name, func(t *testing.T) {
+			secrets, err := read(ctx, "secret")
+			if err != nil {
+				t.Fatalf("%s() error = %v", name, err)
+			}
+			if data, _ := json.Marshal(secrets); strings.Contains(string(data), "deleted") {
+				t.Errorf("%s() returned trashed secrets: %s", name, data)
+			}
+			if secrets["keep"] != "k" || secrets["app"] == nil {
+				t.Errorf("%s() = %v, want keep and app", name, secrets)
+			}
+		})
+	}
+
+	// Reading the trash itself still works
+	trashed, err := client.Export(ctx, "secret/.vlt-trash")
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if len(trashed) != 1 {
+		t.Errorf("Export() of the trash = %v, want its batch", trashed)
+	}
+}
@@ -142,9 +142,9 @@ fi
 
 # snapshot -o and restore --dry-run
 ./vlt add secret/e2e/snap/key "original" 2>/dev/null
-./vlt snapshot secret/e2e/snap -o "$TMPDIR/snap.yaml" 2>/dev/null
+./vlt snapshot secret/e2e/snap -o "$TMPDIR/snap.yaml" --allow-plaintext 2>/dev/null
 ./vlt update secret/e2e/snap/key "modified" 2>/dev/null
-output=$(./vlt restore --dry-run "$TMPDIR/snap.yaml" secret/e2e/snap 2>&1)
+output=$(./vlt restore --dry-run "$TMPDIR/snap.yaml" secret/e2e/snap --allow-plaintext 2>&1)
 if [[ "$output" == *"dry-run"* ]] && [[ "$output" == *"Updated"* ]]; then
     pass "restore --dry-run: shows preview"
 else
@@ -153,7 +153,7 @@ fi
 
 # restore --no-delete
 ./vlt add secret/e2e/snap/extra "extra" 2>/dev/null
-if ./vlt restore --no-delete "$TMPDIR/snap.yaml" secret/e2e/snap 2>/dev/null; then
+if ./vlt restore --no-delete "$TMPDIR/snap.yaml" secret/e2e/snap --allow-plaintext 2>/dev/null; then
     if ./vlt get secret/e2e/snap/extra value 2>/dev/null | grep -q "extra"; then
         pass "restore --no-delete: preserves extras"
     else
@@ -163,16 +163,21 @@ else
     fail "restore --no-delete: failed"
 fi
 
-# restore --verify
+# restore --on-conflict
 ./vlt add secret/e2e/verify/test "v1" 2>/dev/null
-./vlt snapshot secret/e2e/verify -o "$TMPDIR/verify.yaml" 2>/dev/null
+./vlt snapshot secret/e2e/verify -o "$TMPDIR/verify.yaml" --allow-plaintext 2>/dev/null
 ./vlt update secret/e2e/verify/test "v2" 2>/dev/null
 ./vlt update secret/e2e/verify/test "v3" 2>/dev/null
-output=$(./vlt restore --verify "$TMPDIR/verify.yaml" secret/e2e/verify 2>&1)
-if [[ "$output" == *"Skipped"* ]]; then
-    pass "restore --verify: skips version mismatch"
+output=$(./vlt restore --on-conflict skip "$TMPDIR/verify.yaml" secret/e2e/verify --allow-plaintext 2>&1)
+if [[ "$output" == *"test (kept)"* ]]; then
+    pass "restore --on-conflict skip: keeps modified secret"
 else
-    fail "restore --verify (got: $output)"
+    fail "restore --on-conflict skip (got: $output)"
+fi
+if ./vlt restore --on-conflict fail "$TMPDIR/verify.yaml" secret/e2e/verify --allow-plaintext >/dev/null 2>&1; then
+    fail "restore --on-conflict fail: restored modified secret"
+else
+    pass "restore --on-conflict fail: refuses"
 fi
 
 # diff --quiet
@@ -511,9 +516,9 @@ fi
 
 # Snapshot/restore round-trip with special chars
 ./vlt add secret/e2e/snap-special/unicode "Hello 世界" 2>/dev/null
-./vlt snapshot secret/e2e/snap-special -o "$TMPDIR/special.yaml" 2>/dev/null
+./vlt snapshot secret/e2e/snap-special -o "$TMPDIR/special.yaml" --allow-plaintext 2>/dev/null
 ./vlt rm -r secret/e2e/snap-special 2>/dev/null
-./vlt restore "$TMPDIR/special.yaml" secret/e2e/snap-special 2>/dev/null
+./vlt restore "$TMPDIR/special.yaml" secret/e2e/snap-special --allow-plaintext 2>/dev/null
 output=$(./vlt get secret/e2e/snap-special/unicode value 2>/dev/null)
 if [[ "$output" == *"世界"* ]]; then
     pass "snapshot/restore: special chars preserved"
@@ -542,9 +547,9 @@ log "Testing workflow scenarios..."
 # Disaster recovery
 ./vlt add secret/e2e/dr/config "config" 2>/dev/null
 ./vlt add secret/e2e/dr/db/password "secret" 2>/dev/null
-./vlt snapshot secret/e2e/dr -o "$TMPDIR/dr-backup.yaml" 2>/dev/null
+./vlt snapshot secret/e2e/dr -o "$TMPDIR/dr-backup.yaml" --allow-plaintext 2>/dev/null
 ./vlt rm -r secret/e2e/dr 2>/dev/null
-if ./vlt restore "$TMPDIR/dr-backup.yaml" secret/e2e/dr 2>/dev/null; then
+if ./vlt restore "$TMPDIR/dr-backup.yaml" secret/e2e/dr --allow-plaintext 2>/dev/null; then
     config=$(./vlt get secret/e2e/dr/config value 2>/dev/null)
     dbpass=$(./vlt get secret/e2e/dr/db/password value 2>/dev/null)
     if [[ "$config" == "config" ]] && [[ "$dbpass" == "secret" ]]; then
@@ -558,9 +563,9 @@ fi
 
 # Environment promotion
 ./vlt add secret/e2e/staging/app/key "staging-key" 2>/dev/null
-./vlt snapshot secret/e2e/staging -o "$TMPDIR/staging.yaml" 2>/dev/null
+./vlt snapshot secret/e2e/staging -o "$TMPDIR/staging.yaml" --allow-plaintext 2>/dev/null
 ./vlt rm -r secret/e2e/prod 2>/dev/null || true
-if ./vlt restore "$TMPDIR/staging.yaml" secret/e2e/prod 2>/dev/null; then
+if ./vlt restore "$TMPDIR/staging.yaml" secret/e2e/prod --allow-plaintext 2>/dev/null; then
     prod_key=$(./vlt get secret/e2e/prod/app/key value 2>/dev/null)
     if [[ "$prod_key" == "staging-key" ]]; then
         pass "workflow: environment promotion"
@@ -573,10 +578,10 @@ fi
 
 # Rollback
 ./vlt add secret/e2e/rollback/config "v1" 2>/dev/null
-./vlt snapshot secret/e2e/rollback -o "$TMPDIR/v1.yaml" 2>/dev/null
+./vlt snapshot secret/e2e/rollback -o "$TMPDIR/v1.yaml" --allow-plaintext 2>/dev/null
 ./vlt update secret/e2e/rollback/config "v2-broken" 2>/dev/null
 ./vlt add secret/e2e/rollback/bad "oops" 2>/dev/null
-if ./vlt restore "$TMPDIR/v1.yaml" secret/e2e/rollback 2>/dev/null; then
+if ./vlt restore "$TMPDIR/v1.yaml" secret/e2e/rollback --allow-plaintext 2>/dev/null; then
     config=$(./vlt get secret/e2e/rollback/config value 2>/dev/null)
     bad=$(./vlt get secret/e2e/rollback/bad value 2>/dev/null) || bad=""
     if [[ "$config" == "v1" ]] && [[ -z "$bad" ]]; then